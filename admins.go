@@ -0,0 +1,236 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	adminRoleOwner     = "owner"
+	adminRoleAdmin     = "admin"
+	adminRoleModerator = "moderator"
+	adminRoleSupport   = "support"
+)
+
+const (
+	permManageRequests  = "manage_requests"
+	permModerateContent = "moderate_content"
+	permRemoveServers   = "remove_servers"
+	permAdjustVotes     = "adjust_votes"
+	permManageAPIKeys   = "manage_api_keys"
+	permManageAdmins    = "manage_admins"
+)
+
+// adminRoles lists the roles from most to least privileged. Every role can
+// read the admin queues; support can do nothing else.
+var adminRoles = []string{adminRoleOwner, adminRoleAdmin, adminRoleModerator, adminRoleSupport}
+
+var adminRolePermissions = map[string][]string{
+	adminRoleOwner: {
+		permManageRequests, permModerateContent, permRemoveServers,
+		permAdjustVotes, permManageAPIKeys, permManageAdmins,
+	},
+	adminRoleAdmin: {
+		permManageRequests, permModerateContent, permRemoveServers,
+		permAdjustVotes, permManageAPIKeys,
+	},
+	adminRoleModerator: {permManageRequests, permModerateContent},
+	adminRoleSupport:   {},
+}
+
+// adminRole returns the admin role of a Discord account, or "" when it has
+// none. MOSS_ADMIN_IDS accounts are always owners so a fresh install can
+// bootstrap its first admins.
+func adminRole(discordID string) (string, error) {
+	if discordID == "" {
+		return "", nil
+	}
+	if containsString(parseAdminEnvIDs(), discordID) {
+		return adminRoleOwner, nil
+	}
+
+	var role string
+	err := Database.QueryRow(`SELECT role FROM admin_users WHERE discord_id = ?`, discordID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return role, nil
+}
+
+func roleHasPermission(role, perm string) bool {
+	return containsString(adminRolePermissions[role], perm)
+}
+
+// requirePermission only lets admins whose role grants perm through.
+func requirePermission(perm string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		u, ok := getSessionUser(c)
+		if !ok {
+			return fiber.NewError(fiber.StatusUnauthorized, "unauthorized")
+		}
+		role, err := adminRole(u.DiscordID)
+		if err != nil {
+			log.Println("requirePermission:", err)
+			return fiber.NewError(fiber.StatusInternalServerError, "internal error")
+		}
+		if role == "" {
+			return fiber.NewError(fiber.StatusForbidden, "forbidden")
+		}
+		if !roleHasPermission(role, perm) {
+			return fiber.NewError(fiber.StatusForbidden, "the "+role+" role is missing the "+perm+" permission")
+		}
+		return c.Next()
+	}
+}
+
+func getAdminUsersHandler(c fiber.Ctx) error {
+	admin, err := requireAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	admins := make([]AdminUser, 0, 8)
+	for _, id := range parseAdminEnvIDs() {
+		admins = append(admins, AdminUser{
+			DiscordID:   id,
+			Role:        adminRoleOwner,
+			Permissions: adminRolePermissions[adminRoleOwner],
+			Bootstrap:   true,
+		})
+	}
+
+	rows, err := Database.Query(`
+		SELECT discord_id,
+		       role,
+		       COALESCE(added_by, ''),
+		       COALESCE(strftime('%Y-%m-%dT%H:%M:%SZ', created_at), '')
+		FROM admin_users
+		ORDER BY created_at, discord_id
+	`)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load admins")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a AdminUser
+		if err := rows.Scan(&a.DiscordID, &a.Role, &a.AddedBy, &a.CreatedAt); err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("failed to scan admin")
+		}
+		// Env admins are listed once, as bootstrap owners.
+		if containsString(parseAdminEnvIDs(), a.DiscordID) {
+			continue
+		}
+		a.Permissions = adminRolePermissions[a.Role]
+		admins = append(admins, a)
+	}
+	if err := rows.Err(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to read admins")
+	}
+
+	role, err := adminRole(admin.DiscordID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load your role")
+	}
+
+	return c.JSON(fiber.Map{
+		"admins":      admins,
+		"roles":       adminRoles,
+		"permissions": adminRolePermissions,
+		"me": AdminUser{
+			DiscordID:   admin.DiscordID,
+			Role:        role,
+			Permissions: adminRolePermissions[role],
+		},
+	})
+}
+
+// postAdminSetUserHandler grants an account a role, or changes the role it
+// already has.
+func postAdminSetUserHandler(c fiber.Ctx) error {
+	admin, err := requireAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	var body struct {
+		DiscordID string `json:"discord_id"`
+		Role      string `json:"role"`
+	}
+	if err := c.Bind().Body(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid request body")
+	}
+
+	discordID := strings.TrimSpace(body.DiscordID)
+	if !validDiscordID(discordID) {
+		return c.Status(fiber.StatusBadRequest).SendString("discord_id must be a Discord user ID")
+	}
+	if _, ok := adminRolePermissions[body.Role]; !ok {
+		return c.Status(fiber.StatusBadRequest).SendString("role must be one of " + strings.Join(adminRoles, ", "))
+	}
+	if discordID == admin.DiscordID {
+		return c.Status(fiber.StatusBadRequest).SendString("You can't change your own role.")
+	}
+	if containsString(parseAdminEnvIDs(), discordID) {
+		return c.Status(fiber.StatusConflict).SendString("This admin is set through MOSS_ADMIN_IDS.")
+	}
+
+	var before any
+	if role, err := adminRole(discordID); err == nil && role != "" {
+		before = fiber.Map{"role": role}
+	}
+
+	_, err = Database.Exec(`
+		INSERT INTO admin_users (discord_id, role, can_manage_requests, added_by, created_at)
+		VALUES (?, ?, ?, ?, datetime('now'))
+		ON CONFLICT(discord_id) DO UPDATE SET
+			role                = excluded.role,
+			can_manage_requests = excluded.can_manage_requests
+	`, discordID, body.Role, roleHasPermission(body.Role, permManageRequests), admin.DiscordID)
+	if err != nil {
+		log.Println("postAdminSetUserHandler:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to save admin")
+	}
+
+	recordAdminAction(c, "admin.set_role", "admin", discordID, before, fiber.Map{"role": body.Role})
+
+	return c.JSON(fiber.Map{"ok": true})
+}
+
+func postAdminRemoveUserHandler(c fiber.Ctx) error {
+	admin, err := requireAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	discordID := c.Params("discordId")
+	if discordID == admin.DiscordID {
+		return c.Status(fiber.StatusBadRequest).SendString("You can't remove yourself.")
+	}
+	if containsString(parseAdminEnvIDs(), discordID) {
+		return c.Status(fiber.StatusConflict).SendString("This admin is set through MOSS_ADMIN_IDS.")
+	}
+
+	role, err := adminRole(discordID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load admin")
+	}
+
+	res, err := Database.Exec(`DELETE FROM admin_users WHERE discord_id = ?`, discordID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to remove admin")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return c.Status(fiber.StatusNotFound).SendString("admin not found")
+	}
+
+	recordAdminAction(c, "admin.remove", "admin", discordID, fiber.Map{"role": role}, nil)
+
+	return c.JSON(fiber.Map{"ok": true})
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	maxAnnouncementTitle  = 120
+	maxAnnouncementBody   = 2000
+	announcementsPerDay   = 3
+	announcementsOnPage   = 10
+	maxAnnouncementAhead  = 365 * 24 * time.Hour
+	defaultEventsLimit    = 20
+	maxEventsLimit        = 50
+	announcementTimestamp = "2006-01-02 15:04:05"
+)
+
+// announcementLimiter caps how often a server's team can post, keyed by
+// server id rather than user so adding managers does not raise the cap.
+var announcementLimiter = newRateLimiter(24 * time.Hour)
+
+const announcementSelect = `
+	SELECT a.id,
+	       a.server_id,
+	       COALESCE(s.server_name, ''),
+	       a.title,
+	       a.body,
+	       COALESCE(strftime('%Y-%m-%dT%H:%M:%SZ', a.starts_at), ''),
+	       COALESCE(strftime('%Y-%m-%dT%H:%M:%SZ', a.ends_at), ''),
+	       a.author_name,
+	       a.created_at,
+	       a.hidden_at IS NOT NULL
+	FROM server_announcements a
+	LEFT JOIN servers s
+	  ON s.id = a.server_id
+`
+
+func scanAnnouncement(row rowScanner) (Announcement, error) {
+	var a Announcement
+	err := row.Scan(
+		&a.ID,
+		&a.ServerID,
+		&a.ServerName,
+		&a.Title,
+		&a.Body,
+		&a.StartsAt,
+		&a.EndsAt,
+		&a.AuthorName,
+		&a.CreatedAt,
+		&a.Hidden,
+	)
+	return a, err
+}
+
+func queryAnnouncements(where string, args ...any) ([]Announcement, error) {
+	rows, err := Database.Query(announcementSelect+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Announcement, 0, announcementsOnPage)
+	for rows.Next() {
+		a, err := scanAnnouncement(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// publicAnnouncements returns a server's visible announcements, newest
+// first.
+func publicAnnouncements(serverID int) ([]Announcement, error) {
+	return queryAnnouncements(`
+		WHERE a.server_id = ? AND a.hidden_at IS NULL
+		ORDER BY a.created_at DESC, a.id DESC
+		LIMIT ?
+	`, serverID, announcementsOnPage)
+}
+
+// parseAnnouncementTime reads an optional RFC 3339 time and returns it in
+// the form SQLite's datetime() compares against.
+func parseAnnouncementTime(field, raw string) (string, time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("%s must be an RFC 3339 time", field)
+	}
+	if t.After(time.Now().Add(maxAnnouncementAhead)) {
+		return "", time.Time{}, fmt.Errorf("%s is too far in the future", field)
+	}
+	t = t.UTC()
+	return t.Format(announcementTimestamp), t, nil
+}
+
+// postOwnerAnnouncementHandler publishes an announcement on a server's page.
+// Announcements go live straight away; admins can hide them afterwards.
+func postOwnerAnnouncementHandler(c fiber.Ctx) error {
+	u, id, err := requireServerRole(c, roleOwner, roleManager)
+	if err != nil {
+		return err
+	}
+
+	type announcementPayload struct {
+		Title    string `json:"title"`
+		Body     string `json:"body"`
+		StartsAt string `json:"starts_at"`
+		EndsAt   string `json:"ends_at"`
+	}
+
+	var payload announcementPayload
+	if err := c.Bind().Body(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid request body")
+	}
+
+	payload.Title = strings.TrimSpace(payload.Title)
+	payload.Body = strings.TrimSpace(payload.Body)
+	if payload.Title == "" {
+		return c.Status(fiber.StatusBadRequest).SendString("title is required")
+	}
+	if utf8.RuneCountInString(payload.Title) > maxAnnouncementTitle {
+		return c.Status(fiber.StatusBadRequest).SendString(
+			fmt.Sprintf("title must be at most %d characters", maxAnnouncementTitle),
+		)
+	}
+	if payload.Body == "" {
+		return c.Status(fiber.StatusBadRequest).SendString("body is required")
+	}
+	if utf8.RuneCountInString(payload.Body) > maxAnnouncementBody {
+		return c.Status(fiber.StatusBadRequest).SendString(
+			fmt.Sprintf("body must be at most %d characters", maxAnnouncementBody),
+		)
+	}
+
+	startsAt, start, err := parseAnnouncementTime("starts_at", payload.StartsAt)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	endsAt, end, err := parseAnnouncementTime("ends_at", payload.EndsAt)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	if endsAt != "" && startsAt == "" {
+		return c.Status(fiber.StatusBadRequest).SendString("an end time needs a start time")
+	}
+	if endsAt != "" && end.Before(start) {
+		return c.Status(fiber.StatusBadRequest).SendString("ends_at must not be before starts_at")
+	}
+
+	if allowed, _, _ := announcementLimiter.allow(strconv.Itoa(id), announcementsPerDay); !allowed {
+		return c.Status(fiber.StatusTooManyRequests).SendString(
+			fmt.Sprintf("A server can post at most %d announcements a day.", announcementsPerDay),
+		)
+	}
+
+	res, err := Database.Exec(`
+		INSERT INTO server_announcements (
+			server_id, title, body, starts_at, ends_at, author_id, author_name, created_at
+		)
+		VALUES (?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), ?, ?, datetime('now'))
+	`, id, payload.Title, payload.Body, startsAt, endsAt, u.DiscordID, u.Username)
+	if err != nil {
+		log.Println("insert server_announcement:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to save announcement")
+	}
+
+	annID, err := res.LastInsertId()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to get announcement id")
+	}
+
+	a, err := scanAnnouncement(Database.QueryRow(announcementSelect+`WHERE a.id = ?`, annID))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load announcement")
+	}
+
+	notifyNewAnnouncement(&a)
+
+	return c.Status(fiber.StatusCreated).JSON(a)
+}
+
+func postOwnerAnnouncementRemoveHandler(c fiber.Ctx) error {
+	_, id, err := requireServerRole(c, roleOwner, roleManager)
+	if err != nil {
+		return err
+	}
+
+	res, err := Database.Exec(`
+		DELETE FROM server_announcements WHERE id = ? AND server_id = ?
+	`, c.Params("annId"), id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to remove announcement")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return c.Status(fiber.StatusNotFound).SendString("announcement not found")
+	}
+
+	return c.JSON(fiber.Map{"ok": true})
+}
+
+// getEventsHandler is the site-wide feed of announcements. With
+// upcoming=true it lists scheduled events that have not ended yet, soonest
+// first; otherwise the newest posts come first.
+func getEventsHandler(c fiber.Ctx) error {
+	limit := defaultEventsLimit
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return c.Status(fiber.StatusBadRequest).SendString("limit must be a positive number")
+		}
+		limit = min(n, maxEventsLimit)
+	}
+
+	where := `WHERE a.hidden_at IS NULL AND s.id IS NOT NULL`
+	order := ` ORDER BY a.created_at DESC, a.id DESC`
+	if raw := strings.TrimSpace(c.Query("upcoming")); raw != "" {
+		upcoming, err := strconv.ParseBool(raw)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString("upcoming must be true or false")
+		}
+		if upcoming {
+			where += ` AND a.starts_at IS NOT NULL
+			  AND COALESCE(a.ends_at, a.starts_at) >= datetime('now')`
+			order = ` ORDER BY a.starts_at, a.id`
+		}
+	}
+
+	events, err := queryAnnouncements(where+order+` LIMIT ?`, limit)
+	if err != nil {
+		log.Println("getEventsHandler:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load events")
+	}
+
+	return c.JSON(events)
+}
+
+// getAdminAnnouncementsHandler lists recent announcements, hidden ones
+// included.
+func getAdminAnnouncementsHandler(c fiber.Ctx) error {
+	if _, err := requireAdmin(c); err != nil {
+		return err
+	}
+
+	announcements, err := queryAnnouncements(`
+		ORDER BY a.created_at DESC, a.id DESC
+		LIMIT 100
+	`)
+	if err != nil {
+		log.Println("getAdminAnnouncementsHandler:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load announcements")
+	}
+
+	return c.JSON(fiber.Map{"announcements": announcements})
+}
+
+func setAnnouncementHidden(c fiber.Ctx, hidden bool) error {
+	admin, err := requireAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	before := auditRow(queryAnnouncements, `WHERE a.id = ?`, c.Params("id"))
+
+	var res sql.Result
+	if hidden {
+		res, err = Database.Exec(`
+			UPDATE server_announcements
+			SET hidden_at = datetime('now'), hidden_by = ?
+			WHERE id = ? AND hidden_at IS NULL
+		`, admin.DiscordID, c.Params("id"))
+	} else {
+		res, err = Database.Exec(`
+			UPDATE server_announcements
+			SET hidden_at = NULL, hidden_by = NULL
+			WHERE id = ? AND hidden_at IS NOT NULL
+		`, c.Params("id"))
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to update announcement")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return c.Status(fiber.StatusNotFound).SendString("announcement not found or already updated")
+	}
+
+	action := "announcement.unhide"
+	if hidden {
+		action = "announcement.hide"
+	}
+	recordAdminAction(c, action, "announcement", c.Params("id"), before, auditRow(queryAnnouncements, `WHERE a.id = ?`, c.Params("id")))
+
+	return c.JSON(fiber.Map{"ok": true})
+}
+
+func postAdminHideAnnouncementHandler(c fiber.Ctx) error {
+	return setAnnouncementHidden(c, true)
+}
+
+func postAdminUnhideAnnouncementHandler(c fiber.Ctx) error {
+	return setAnnouncementHidden(c, false)
+}
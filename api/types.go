@@ -0,0 +1,354 @@
+// Package api holds the JSON types served by mossai. They are shared by the
+// server and the Go client so both sides agree on the wire format.
+package api
+
+import "encoding/json"
+
+type ServerResult struct {
+	ID          int    `json:"id"`
+	ServerName  string `json:"server_name"`
+	URL         string `json:"url"`
+	Description string `json:"description"`
+	// LongDescription is the Markdown shown on the listing's own page.
+	LongDescription string `json:"long_description,omitempty"`
+	Tags            string `json:"tags"`
+	// Features describes what the server supports.
+	Features ServerFeatures `json:"features"`
+	// Links are the server's community pages, at most one per type.
+	Links   []ServerLink `json:"links,omitempty"`
+	LogoURL string       `json:"logo_url"`
+	// Logo is the copy of LogoURL served from mossai itself, empty until a
+	// remote logo has been fetched.
+	Logo       string `json:"logo"`
+	Status     string `json:"status"`
+	Online     int    `json:"online"`
+	Registered int    `json:"registered"`
+	Votes      int    `json:"votes"`
+	// Rating is the average of the visible reviews' stars, 0 when there
+	// are none.
+	Rating      float64 `json:"rating"`
+	RatingCount int     `json:"rating_count"`
+	Added       string  `json:"added"`
+	// Owner lists the usernames of the server's owners, comma separated.
+	Owner string `json:"owner"`
+	// VerifiedDomain is set while the owners prove they control the
+	// listing's domain.
+	VerifiedDomain string `json:"verified_domain,omitempty"`
+	// Screenshots is the listing's gallery, only filled in when a single
+	// server is requested.
+	Screenshots []Screenshot `json:"screenshots,omitempty"`
+	// Discord is what the listing's Discord invite last resolved to.
+	Discord *DiscordCommunity `json:"discord,omitempty"`
+	// Announcements are the team's latest posts, only filled in when a
+	// single server is requested.
+	Announcements []Announcement `json:"announcements,omitempty"`
+	// Reviews are the newest visible reviews, only filled in when a single
+	// server is requested.
+	Reviews []Review `json:"reviews,omitempty"`
+}
+
+// Review is a user's 1–5 star rating of a server, with an optional text
+// and one reply from the server's team. Moderation fields are only set for
+// admins.
+type Review struct {
+	ID         int    `json:"id"`
+	ServerID   int    `json:"server_id"`
+	ServerName string `json:"server_name,omitempty"`
+	Username   string `json:"username"`
+	Rating     int    `json:"rating"`
+	Body       string `json:"body"`
+	CreatedAt  string `json:"created_at"`
+	UpdatedAt  string `json:"updated_at,omitempty"`
+	Reply      string `json:"reply,omitempty"`
+	ReplyBy    string `json:"reply_by,omitempty"`
+	RepliedAt  string `json:"replied_at,omitempty"`
+	Hidden     bool   `json:"hidden,omitempty"`
+	// Reports counts open reports; ReportReasons holds their reasons.
+	Reports       int      `json:"reports,omitempty"`
+	ReportReasons []string `json:"report_reasons,omitempty"`
+}
+
+// Announcement is a post by a server's team, such as a tournament or a map
+// pack release. StartsAt and EndsAt are set for scheduled events.
+type Announcement struct {
+	ID         int    `json:"id"`
+	ServerID   int    `json:"server_id"`
+	ServerName string `json:"server_name"`
+	Title      string `json:"title"`
+	// Body is Markdown.
+	Body       string `json:"body"`
+	StartsAt   string `json:"starts_at,omitempty"`
+	EndsAt     string `json:"ends_at,omitempty"`
+	AuthorName string `json:"author_name"`
+	CreatedAt  string `json:"created_at"`
+	// Hidden is set when an admin took the post down. Hidden posts are
+	// only shown to the server's team and admins.
+	Hidden bool `json:"hidden,omitempty"`
+}
+
+// DiscordCommunity describes the guild behind a listing's Discord invite.
+// The counts are Discord's approximations as of CheckedAt.
+type DiscordCommunity struct {
+	GuildName     string `json:"guild_name,omitempty"`
+	IconURL       string `json:"icon_url,omitempty"`
+	MemberCount   int    `json:"member_count"`
+	PresenceCount int    `json:"presence_count"`
+	// Expired is set once Discord stops recognising the invite.
+	Expired   bool   `json:"expired,omitempty"`
+	CheckedAt string `json:"checked_at"`
+}
+
+// ExpiredDiscordInvite is a listing whose Discord invite no longer works.
+type ExpiredDiscordInvite struct {
+	ServerID   int    `json:"server_id"`
+	ServerName string `json:"server_name"`
+	InviteCode string `json:"invite_code"`
+	ExpiredAt  string `json:"expired_at"`
+	LastError  string `json:"last_error,omitempty"`
+}
+
+// Screenshot is one image in a listing's gallery. The moderation fields are
+// only set for the listing's team and admins.
+type Screenshot struct {
+	ID         int    `json:"id"`
+	ServerID   int    `json:"server_id,omitempty"`
+	ServerName string `json:"server_name,omitempty"`
+	ImageURL   string `json:"image_url"`
+	ThumbURL   string `json:"thumb_url"`
+	Caption    string `json:"caption"`
+	Position   int    `json:"position"`
+	// Status is "pending", "approved" or "rejected". PendingCaption holds a
+	// caption change awaiting review while CaptionPending is set.
+	Status         string `json:"status,omitempty"`
+	PendingCaption string `json:"pending_caption,omitempty"`
+	CaptionPending bool   `json:"caption_pending,omitempty"`
+	UploadedBy     string `json:"uploaded_by,omitempty"`
+	CreatedAt      string `json:"created_at,omitempty"`
+}
+
+type ServerRequest struct {
+	ID              int            `json:"id"`
+	ServerName      string         `json:"server_name"`
+	URL             string         `json:"url"`
+	Description     string         `json:"description"`
+	LongDescription string         `json:"long_description,omitempty"`
+	Tags            string         `json:"tags"`
+	Features        ServerFeatures `json:"features"`
+	Links           []ServerLink   `json:"links,omitempty"`
+	OwnerName       string         `json:"owner_name"`
+	OwnerDiscord    string         `json:"owner_discord"`
+	Status          string         `json:"status"`
+	CreatedAt       string         `json:"created_at"`
+	LogoURL         string         `json:"logo_url"`
+	// Kind is "new" for a listing submission or "edit" for a change an owner
+	// proposed to the live listing ServerID.
+	Kind     string `json:"kind"`
+	ServerID int    `json:"server_id,omitempty"`
+	// Changes lists, for edit requests, the fields that differ from the
+	// live listing.
+	Changes []FieldChange `json:"changes,omitempty"`
+	// DecisionCode is the canned reason picked when rejecting the request
+	// and DecisionLabel its text. DecisionReason is the admin's own note.
+	DecisionCode   string `json:"decision_code,omitempty"`
+	DecisionLabel  string `json:"decision_label,omitempty"`
+	DecisionReason string `json:"decision_reason,omitempty"`
+	// Revision counts the stored versions of the request. SeenRevision is
+	// the one the admin viewing the queue last marked as seen (0 if never)
+	// and UnseenChanges what changed since.
+	Revision      int           `json:"revision,omitempty"`
+	SeenRevision  int           `json:"seen_revision,omitempty"`
+	UnseenChanges []FieldChange `json:"unseen_changes,omitempty"`
+}
+
+// RejectionReason is a canned reason for rejecting a listing request.
+type RejectionReason struct {
+	Code  string `json:"code"`
+	Label string `json:"label"`
+}
+
+// ServerReport is a user's abuse report against a listing, as seen in the
+// admin queue. Status is "open", "investigating", "resolved" or
+// "dismissed".
+type ServerReport struct {
+	ID           int    `json:"id"`
+	ServerID     int    `json:"server_id"`
+	ServerName   string `json:"server_name"`
+	ReporterID   string `json:"reporter_id"`
+	ReporterName string `json:"reporter_name"`
+	Category     string `json:"category"`
+	Details      string `json:"details"`
+	Status       string `json:"status"`
+	AdminNote    string `json:"admin_note,omitempty"`
+	CreatedAt    string `json:"created_at"`
+	UpdatedAt    string `json:"updated_at,omitempty"`
+	// OpenReports counts the listing's open and investigating reports.
+	OpenReports int `json:"open_reports"`
+}
+
+// AdminUser is an account with access to the admin tools.
+type AdminUser struct {
+	DiscordID   string   `json:"discord_id"`
+	Role        string   `json:"role"`
+	Permissions []string `json:"permissions"`
+	// Bootstrap admins come from MOSS_ADMIN_IDS and can't be edited here.
+	Bootstrap bool   `json:"bootstrap,omitempty"`
+	AddedBy   string `json:"added_by,omitempty"`
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+// AuditEntry is one admin action from the audit log. Before and After hold
+// JSON snapshots of the target when the action has them.
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	ActorID    string          `json:"actor_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	IP         string          `json:"ip"`
+	CreatedAt  string          `json:"created_at"`
+}
+
+// RequestRevision is one stored version of a listing request.
+type RequestRevision struct {
+	Revision        int            `json:"revision"`
+	ServerName      string         `json:"server_name"`
+	URL             string         `json:"url"`
+	Description     string         `json:"description"`
+	LongDescription string         `json:"long_description,omitempty"`
+	Tags            string         `json:"tags"`
+	Features        ServerFeatures `json:"features"`
+	Links           []ServerLink   `json:"links,omitempty"`
+	LogoURL         string         `json:"logo_url"`
+	EditedBy        string         `json:"edited_by"`
+	CreatedAt       string         `json:"created_at"`
+}
+
+// ServerFeatures is the structured part of a listing. Empty fields are
+// unknown rather than unsupported.
+type ServerFeatures struct {
+	// Modes holds any of "std", "taiko", "catch" and "mania".
+	Modes []string `json:"modes,omitempty"`
+	// Leaderboards holds any of "vanilla", "relax", "autopilot" and
+	// "scorev2".
+	Leaderboards []string `json:"leaderboards,omitempty"`
+	// PPSystem is "stable", "lazer", "custom" or "none".
+	PPSystem string `json:"pp_system,omitempty"`
+	// UnrankedRankable reports whether unranked maps award pp.
+	UnrankedRankable *bool `json:"unranked_rankable,omitempty"`
+	// Registration is "open", "invite" or "closed".
+	Registration string `json:"registration,omitempty"`
+	// Clients holds any of "stable", "lazer", "mcosu" and "custom".
+	Clients []string `json:"clients,omitempty"`
+}
+
+// IsZero reports whether no feature is set.
+func (f ServerFeatures) IsZero() bool {
+	return len(f.Modes) == 0 && len(f.Leaderboards) == 0 && f.PPSystem == "" &&
+		f.UnrankedRankable == nil && f.Registration == "" && len(f.Clients) == 0
+}
+
+// ServerLink is a typed community link. Type is "discord", "github",
+// "twitter", "youtube" or "donate".
+type ServerLink struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// FieldChange is one listing field an edit request changes.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// OwnerServer is a listing as shown on its owner's dashboard. Role is the
+// viewer's role on the server.
+type OwnerServer struct {
+	ServerResult
+	Role           string         `json:"role"`
+	Rank           int            `json:"rank"`
+	VotesToday     int            `json:"votes_today"`
+	VotesLast7Days int            `json:"votes_last_7_days"`
+	PendingEdit    *ServerRequest `json:"pending_edit,omitempty"`
+	Owners         []ServerOwner  `json:"owners"`
+	Invites        []OwnerInvite  `json:"invites,omitempty"`
+}
+
+type ServerOwner struct {
+	DiscordID string `json:"discord_id"`
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	AddedAt   string `json:"added_at"`
+}
+
+// OwnerInvite asks a Discord user to join a server's team. Transfer invites
+// make the invitee an owner and demote the owner who sent them.
+type OwnerInvite struct {
+	ID         int    `json:"id"`
+	ServerID   int    `json:"server_id"`
+	ServerName string `json:"server_name"`
+	DiscordID  string `json:"discord_id"`
+	Role       string `json:"role"`
+	Kind       string `json:"kind"`
+	InvitedBy  string `json:"invited_by"`
+	Status     string `json:"status"`
+	CreatedAt  string `json:"created_at"`
+}
+
+type APIKey struct {
+	ID                int      `json:"id"`
+	Name              string   `json:"name"`
+	Prefix            string   `json:"prefix"`
+	OwnerDiscord      string   `json:"owner_discord"`
+	Tier              string   `json:"tier"`
+	Scopes            []string `json:"scopes"`
+	RequestCount      int      `json:"request_count"`
+	RequestsToday     int      `json:"requests_today"`
+	RequestsLast7Days int      `json:"requests_last_7_days"`
+	CreatedAt         string   `json:"created_at"`
+	LastUsedAt        string   `json:"last_used_at"`
+	RevokedAt         string   `json:"revoked_at"`
+}
+
+type VoteCheck struct {
+	ServerID   int    `json:"server_id"`
+	Name       string `json:"name"`
+	Voted      bool   `json:"voted"`
+	LastVote   string `json:"last_vote,omitempty"`
+	NextVoteAt string `json:"next_vote_at,omitempty"`
+}
+
+// ServerClaim asks admins to hand a listing over to the claimant.
+// CurrentOwners lists the usernames of the team the claim would replace.
+type ServerClaim struct {
+	ID            int    `json:"id"`
+	ServerID      int    `json:"server_id"`
+	ServerName    string `json:"server_name"`
+	DiscordID     string `json:"discord_id"`
+	Username      string `json:"username"`
+	Evidence      string `json:"evidence"`
+	Status        string `json:"status"`
+	CreatedAt     string `json:"created_at"`
+	DecidedAt     string `json:"decided_at,omitempty"`
+	CurrentOwners string `json:"current_owners"`
+	// VerifiedDomain is the listing's domain when the claimant proved they
+	// control it. It is empty once the listing moves to another domain.
+	VerifiedDomain string `json:"verified_domain,omitempty"`
+}
+
+// DomainVerification is the token an owner publishes to prove they control
+// a listing's domain, either as TXTRecord on the domain or as the only line
+// of WellKnownURL.
+type DomainVerification struct {
+	ServerID      int    `json:"server_id"`
+	Domain        string `json:"domain"`
+	Token         string `json:"token"`
+	TXTRecord     string `json:"txt_record"`
+	WellKnownURL  string `json:"well_known_url"`
+	Method        string `json:"method,omitempty"`
+	VerifiedAt    string `json:"verified_at,omitempty"`
+	LastCheckedAt string `json:"last_checked_at,omitempty"`
+	LastError     string `json:"last_error,omitempty"`
+}
@@ -0,0 +1,513 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	apiScopeRead      = "read"
+	apiScopeVoteCheck = "vote-check"
+
+	apiTierAnonymous = "anonymous"
+	apiTierStandard  = "standard"
+
+	maxActiveAPIKeysPerUser = 5
+)
+
+// apiKeyTiers maps a tier name to its request budget per minute.
+var apiKeyTiers = map[string]int{
+	apiTierAnonymous: 60,
+	apiTierStandard:  300,
+	"partner":        1200,
+}
+
+var apiKeyScopes = []string{apiScopeRead, apiScopeVoteCheck}
+
+var anonymousAPIScopes = []string{apiScopeRead}
+
+var apiLimiter = newRateLimiter(time.Minute)
+
+func generateAPIKey() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "mossai_" + hex.EncodeToString(b), nil
+}
+
+func hashAPIKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func apiKeyFromRequest(c fiber.Ctx) string {
+	if v := strings.TrimSpace(c.Get("X-API-Key")); v != "" {
+		return v
+	}
+	auth := strings.TrimSpace(c.Get("Authorization"))
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+func splitCSV(s string) []string {
+	parts := strings.Split(s, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func containsString(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+const apiKeySelect = `
+	SELECT
+		k.id,
+		k.name,
+		k.key_prefix,
+		k.owner_discord,
+		k.tier,
+		k.scopes,
+		k.request_count,
+		k.created_at,
+		COALESCE(k.last_used_at, ''),
+		COALESCE(k.revoked_at, ''),
+		COALESCE((SELECT u.requests FROM api_key_usage u
+		          WHERE u.key_id = k.id AND u.day = date('now')), 0),
+		COALESCE((SELECT SUM(u.requests) FROM api_key_usage u
+		          WHERE u.key_id = k.id AND u.day > date('now', '-7 days')), 0)
+	FROM api_keys k
+`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIKey(row rowScanner) (APIKey, error) {
+	var k APIKey
+	var scopes string
+	err := row.Scan(
+		&k.ID,
+		&k.Name,
+		&k.Prefix,
+		&k.OwnerDiscord,
+		&k.Tier,
+		&scopes,
+		&k.RequestCount,
+		&k.CreatedAt,
+		&k.LastUsedAt,
+		&k.RevokedAt,
+		&k.RequestsToday,
+		&k.RequestsLast7Days,
+	)
+	k.Scopes = splitCSV(scopes)
+	return k, err
+}
+
+func queryAPIKeys(where string, args ...any) ([]APIKey, error) {
+	rows, err := Database.Query(apiKeySelect+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]APIKey, 0, 8)
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// apiKeyUsage counts keyed requests in memory until the next flush, so
+// serving a request never waits on a database write.
+var apiKeyUsage = struct {
+	sync.Mutex
+	requests map[int]int
+	lastUsed map[int]time.Time
+}{requests: make(map[int]int), lastUsed: make(map[int]time.Time)}
+
+const apiKeyUsageFlushInterval = 15 * time.Second
+
+func recordAPIKeyUsage(id int) {
+	apiKeyUsage.Lock()
+	defer apiKeyUsage.Unlock()
+	apiKeyUsage.requests[id]++
+	apiKeyUsage.lastUsed[id] = time.Now().UTC()
+}
+
+// flushAPIKeyUsage writes the counted requests to api_keys and
+// api_key_usage in one transaction.
+func flushAPIKeyUsage() {
+	apiKeyUsage.Lock()
+	requests, lastUsed := apiKeyUsage.requests, apiKeyUsage.lastUsed
+	apiKeyUsage.requests, apiKeyUsage.lastUsed = make(map[int]int), make(map[int]time.Time)
+	apiKeyUsage.Unlock()
+
+	if len(requests) == 0 {
+		return
+	}
+
+	tx, err := Database.Begin()
+	if err != nil {
+		log.Println("flushAPIKeyUsage begin:", err)
+		return
+	}
+	defer tx.Rollback()
+
+	for id, n := range requests {
+		if _, err := tx.Exec(`
+			UPDATE api_keys
+			SET request_count = request_count + ?,
+			    last_used_at  = MAX(COALESCE(last_used_at, ''), ?)
+			WHERE id = ?
+		`, n, lastUsed[id].Format(time.DateTime), id); err != nil {
+			log.Println("flushAPIKeyUsage update:", err)
+			return
+		}
+
+		if _, err := tx.Exec(`
+			INSERT INTO api_key_usage (key_id, day, requests)
+			VALUES (?, date('now'), ?)
+			ON CONFLICT(key_id, day) DO UPDATE SET requests = requests + excluded.requests
+		`, id, n); err != nil {
+			log.Println("flushAPIKeyUsage usage:", err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println("flushAPIKeyUsage commit:", err)
+	}
+}
+
+func startAPIKeyUsageFlush(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			flushAPIKeyUsage()
+		}
+	}()
+}
+
+// apiAccess authenticates the optional API key on a request, checks that the
+// caller holds scope and applies the caller's rate limit tier. Requests
+// without a key are rate limited per IP at the anonymous tier.
+func apiAccess(scope string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		limiterKey := "ip:" + c.IP()
+		tier := apiTierAnonymous
+		scopes := anonymousAPIScopes
+
+		var key *APIKey
+		if raw := apiKeyFromRequest(c); raw != "" {
+			k, err := scanAPIKey(Database.QueryRow(apiKeySelect+" WHERE k.key_hash = ?", hashAPIKey(raw)))
+			if err == sql.ErrNoRows {
+				return c.Status(fiber.StatusUnauthorized).SendString("invalid api key")
+			}
+			if err != nil {
+				log.Println("apiAccess lookup:", err)
+				return c.Status(fiber.StatusInternalServerError).SendString("internal error")
+			}
+			if k.RevokedAt != "" {
+				return c.Status(fiber.StatusUnauthorized).SendString("api key has been revoked")
+			}
+			key = &k
+			limiterKey = "key:" + strconv.Itoa(k.ID)
+			tier = k.Tier
+			scopes = k.Scopes
+		}
+
+		if !containsString(scopes, scope) {
+			if key == nil {
+				return c.Status(fiber.StatusUnauthorized).SendString("an api key with the " + scope + " scope is required")
+			}
+			return c.Status(fiber.StatusForbidden).SendString("api key is missing the " + scope + " scope")
+		}
+
+		limit, ok := apiKeyTiers[tier]
+		if !ok {
+			limit = apiKeyTiers[apiTierAnonymous]
+		}
+
+		allowed, remaining, reset := apiLimiter.allow(limiterKey, limit)
+		c.Set("X-RateLimit-Limit", strconv.Itoa(limit))
+		c.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		if !allowed {
+			retry := int(time.Until(reset).Seconds()) + 1
+			c.Set("Retry-After", strconv.Itoa(retry))
+			return c.Status(fiber.StatusTooManyRequests).SendString("rate limit exceeded")
+		}
+
+		if key != nil {
+			recordAPIKeyUsage(key.ID)
+		}
+
+		return c.Next()
+	}
+}
+
+func getAPIKeysHandler(c fiber.Ctx) error {
+	u, ok := getSessionUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).SendString("unauthorized")
+	}
+
+	keys, err := queryAPIKeys(" WHERE k.owner_discord = ? ORDER BY k.created_at DESC", u.DiscordID)
+	if err != nil {
+		log.Println("getAPIKeysHandler:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load api keys")
+	}
+
+	return c.JSON(fiber.Map{
+		"keys":   keys,
+		"scopes": apiKeyScopes,
+	})
+}
+
+func postAPIKeyHandler(c fiber.Ctx) error {
+	u, ok := getSessionUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).SendString("unauthorized")
+	}
+
+	type createPayload struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+
+	var payload createPayload
+	if err := c.Bind().Body(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid json body")
+	}
+
+	payload.Name = strings.TrimSpace(payload.Name)
+	if payload.Name == "" || len(payload.Name) > 60 {
+		return c.Status(fiber.StatusBadRequest).SendString("name is required (max 60 characters)")
+	}
+
+	scopes := make([]string, 0, len(apiKeyScopes))
+	for _, s := range payload.Scopes {
+		s = strings.TrimSpace(s)
+		if !containsString(apiKeyScopes, s) {
+			return c.Status(fiber.StatusBadRequest).SendString("unknown scope: " + s)
+		}
+		if !containsString(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	if len(scopes) == 0 {
+		scopes = append(scopes, apiScopeRead)
+	}
+
+	var active int
+	if err := Database.QueryRow(`
+		SELECT COUNT(*)
+		FROM api_keys
+		WHERE owner_discord = ? AND revoked_at IS NULL
+	`, u.DiscordID).Scan(&active); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to count api keys")
+	}
+	if active >= maxActiveAPIKeysPerUser {
+		return c.Status(fiber.StatusBadRequest).SendString("you already have the maximum number of active api keys")
+	}
+
+	raw, err := generateAPIKey()
+	if err != nil {
+		log.Println("generateAPIKey:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("internal error")
+	}
+
+	res, err := Database.Exec(`
+		INSERT INTO api_keys (
+			name,
+			key_hash,
+			key_prefix,
+			owner_discord,
+			tier,
+			scopes,
+			created_at
+		)
+		VALUES (?, ?, ?, ?, ?, ?, datetime('now'))
+	`, payload.Name, hashAPIKey(raw), raw[:14], u.DiscordID, apiTierStandard, strings.Join(scopes, ","))
+	if err != nil {
+		log.Println("insert api_key:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to create api key")
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to get api key id")
+	}
+
+	key, err := scanAPIKey(Database.QueryRow(apiKeySelect+" WHERE k.id = ?", id))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load api key")
+	}
+
+	// The plaintext key is only ever returned here; we store its hash.
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"key":     raw,
+		"api_key": key,
+	})
+}
+
+func postRevokeAPIKeyHandler(c fiber.Ctx) error {
+	u, ok := getSessionUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).SendString("unauthorized")
+	}
+
+	id := strings.TrimSpace(c.Params("id"))
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).SendString("missing id")
+	}
+
+	res, err := Database.Exec(`
+		UPDATE api_keys
+		SET revoked_at = datetime('now')
+		WHERE id = ? AND owner_discord = ? AND revoked_at IS NULL
+	`, id, u.DiscordID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to revoke api key")
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to read update result")
+	}
+	if affected == 0 {
+		return c.Status(fiber.StatusNotFound).SendString("api key not found or already revoked")
+	}
+
+	return c.JSON(fiber.Map{"ok": true})
+}
+
+func getAdminAPIKeysHandler(c fiber.Ctx) error {
+	if _, err := requireAdmin(c); err != nil {
+		return err
+	}
+
+	keys, err := queryAPIKeys(" ORDER BY k.revoked_at IS NOT NULL, k.request_count DESC, k.created_at DESC")
+	if err != nil {
+		log.Println("getAdminAPIKeysHandler:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load api keys")
+	}
+
+	tiers := make([]string, 0, len(apiKeyTiers))
+	for name := range apiKeyTiers {
+		if name != apiTierAnonymous {
+			tiers = append(tiers, name)
+		}
+	}
+	sort.Strings(tiers)
+
+	return c.JSON(fiber.Map{
+		"keys":  keys,
+		"tiers": tiers,
+	})
+}
+
+func postAdminRevokeAPIKeyHandler(c fiber.Ctx) error {
+	if _, err := requireAdmin(c); err != nil {
+		return err
+	}
+
+	id := strings.TrimSpace(c.Params("id"))
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).SendString("missing id")
+	}
+
+	before := auditRow(queryAPIKeys, `WHERE k.id = ?`, id)
+
+	res, err := Database.Exec(`
+		UPDATE api_keys
+		SET revoked_at = datetime('now')
+		WHERE id = ? AND revoked_at IS NULL
+	`, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to revoke api key")
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to read update result")
+	}
+	if affected == 0 {
+		return c.Status(fiber.StatusNotFound).SendString("api key not found or already revoked")
+	}
+
+	recordAdminAction(c, "api_key.revoke", "api_key", id, before, auditRow(queryAPIKeys, `WHERE k.id = ?`, id))
+
+	return c.JSON(fiber.Map{"ok": true})
+}
+
+func postAdminAPIKeyTierHandler(c fiber.Ctx) error {
+	if _, err := requireAdmin(c); err != nil {
+		return err
+	}
+
+	id := strings.TrimSpace(c.Params("id"))
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).SendString("missing id")
+	}
+
+	var payload struct {
+		Tier string `json:"tier"`
+	}
+	if err := c.Bind().Body(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid json body")
+	}
+
+	payload.Tier = strings.TrimSpace(payload.Tier)
+	if _, ok := apiKeyTiers[payload.Tier]; !ok || payload.Tier == apiTierAnonymous {
+		return c.Status(fiber.StatusBadRequest).SendString("unknown tier")
+	}
+
+	before := auditRow(queryAPIKeys, `WHERE k.id = ?`, id)
+
+	res, err := Database.Exec(`UPDATE api_keys SET tier = ? WHERE id = ?`, payload.Tier, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to update api key")
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to read update result")
+	}
+	if affected == 0 {
+		return c.Status(fiber.StatusNotFound).SendString("api key not found")
+	}
+
+	recordAdminAction(c, "api_key.tier", "api_key", id, before, auditRow(queryAPIKeys, `WHERE k.id = ?`, id))
+
+	return c.JSON(fiber.Map{"ok": true})
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAnonymousLimitIsPerForwardedClient(t *testing.T) {
+	srv := newTestServer(t)
+
+	get := func(client string) int {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/leaderboard", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Forwarded-For", client)
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	limit := apiKeyTiers[apiTierAnonymous]
+	for i := 0; i < limit; i++ {
+		if status := get("203.0.113.10"); status != http.StatusOK {
+			t.Fatalf("request %d: status %d", i, status)
+		}
+	}
+	if status := get("203.0.113.10"); status != http.StatusTooManyRequests {
+		t.Fatalf("request over the limit: status %d, want 429", status)
+	}
+	if status := get("203.0.113.11"); status != http.StatusOK {
+		t.Fatalf("another client behind the proxy: status %d, want 200", status)
+	}
+}
+
+func TestAPIKeyUsageIsFlushedInBatches(t *testing.T) {
+	srv := newTestServer(t)
+	raw := insertTestAPIKey(t, "read")
+
+	for i := 0; i < 3; i++ {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/leaderboard", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-API-Key", raw)
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: status %d", i, resp.StatusCode)
+		}
+	}
+
+	keys, err := queryAPIKeys(" WHERE k.key_hash = ?", hashAPIKey(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys[0].RequestCount != 0 {
+		t.Fatalf("request_count = %d before the flush, want 0", keys[0].RequestCount)
+	}
+
+	flushAPIKeyUsage()
+
+	keys, err = queryAPIKeys(" WHERE k.key_hash = ?", hashAPIKey(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k := keys[0]; k.RequestCount != 3 || k.RequestsToday != 3 || k.LastUsedAt == "" {
+		t.Fatalf("after the flush: %+v", k)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 200
+)
+
+// recordAdminAction appends an entry to the admin audit log. before and
+// after are snapshots of the target and may be nil. Entries are never
+// pruned. A failed write is only logged so it can't undo the action itself.
+func recordAdminAction(c fiber.Ctx, action, targetType string, targetID any, before, after any) {
+	actor := ""
+	if u, ok := getSessionUser(c); ok {
+		actor = u.DiscordID
+	}
+
+	_, err := Database.Exec(`
+		INSERT INTO admin_audit_log (actor_id, action, target_type, target_id, before_state, after_state, ip, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, datetime('now'))
+	`, actor, action, targetType, fmt.Sprint(targetID), auditJSON(before), auditJSON(after), c.IP())
+	if err != nil {
+		log.Println("recordAdminAction:", action, err)
+	}
+}
+
+func auditJSON(v any) any {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		log.Println("auditJSON:", err)
+		return nil
+	}
+	return string(b)
+}
+
+// auditRow loads one row through a queryX helper for use as a snapshot, or
+// nil when it is missing.
+func auditRow[T any](query func(string, ...any) ([]T, error), where string, args ...any) any {
+	rows, err := query(where, args...)
+	if err != nil {
+		log.Println("auditRow:", err)
+		return nil
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return rows[0]
+}
+
+// getAdminAuditLogHandler lists audit entries newest first. actor, action,
+// target_type and target_id filter exactly; since and until take RFC3339
+// times; before_id pages back from an earlier response's last id.
+func getAdminAuditLogHandler(c fiber.Ctx) error {
+	if _, err := requireAdmin(c); err != nil {
+		return err
+	}
+
+	where := make([]string, 0, 6)
+	args := make([]any, 0, 8)
+
+	for param, column := range map[string]string{
+		"actor":       "actor_id",
+		"action":      "action",
+		"target_type": "target_type",
+		"target_id":   "target_id",
+	} {
+		if v := strings.TrimSpace(c.Query(param)); v != "" {
+			where = append(where, column+" = ?")
+			args = append(args, v)
+		}
+	}
+
+	for param, op := range map[string]string{"since": ">=", "until": "<"} {
+		v := strings.TrimSpace(c.Query(param))
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(param + " must be an RFC3339 time")
+		}
+		where = append(where, "created_at "+op+" ?")
+		args = append(args, t.UTC().Format("2006-01-02 15:04:05"))
+	}
+
+	if v := c.Query("before_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			return c.Status(fiber.StatusBadRequest).SendString("invalid before_id")
+		}
+		where = append(where, "id < ?")
+		args = append(args, id)
+	}
+
+	limit := defaultAuditPageSize
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxAuditPageSize {
+			return c.Status(fiber.StatusBadRequest).SendString(
+				fmt.Sprintf("limit must be between 1 and %d", maxAuditPageSize),
+			)
+		}
+		limit = n
+	}
+
+	query := `
+		SELECT id, actor_id, action, target_type, target_id,
+		       COALESCE(before_state, ''), COALESCE(after_state, ''),
+		       ip, created_at
+		FROM admin_audit_log
+	`
+	if len(where) > 0 {
+		query += "WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := Database.Query(query, args...)
+	if err != nil {
+		log.Println("getAdminAuditLogHandler:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load audit log")
+	}
+	defer rows.Close()
+
+	entries := make([]AuditEntry, 0, limit)
+	for rows.Next() {
+		var (
+			e             AuditEntry
+			before, after string
+		)
+		if err := rows.Scan(&e.ID, &e.ActorID, &e.Action, &e.TargetType, &e.TargetID, &before, &after, &e.IP, &e.CreatedAt); err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("failed to scan audit entry")
+		}
+		if before != "" {
+			e.Before = json.RawMessage(before)
+		}
+		if after != "" {
+			e.After = json.RawMessage(after)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to read audit log")
+	}
+
+	return c.JSON(fiber.Map{"entries": entries})
+}
@@ -47,20 +47,23 @@ func loadDiscordConfig() (*DiscordConfig, error) {
 		scope = "identify"
 	}
 
-	apiBase := strings.TrimSpace(os.Getenv("DISCORD_API_BASE"))
-	if apiBase == "" {
-		apiBase = "https://discord.com/api"
-	}
-
 	return &DiscordConfig{
 		ClientID:     clientID,
 		ClientSecret: clientSecret,
 		RedirectURI:  redirectURI,
 		Scope:        scope,
-		APIBase:      apiBase,
+		APIBase:      discordAPIBase(),
 	}, nil
 }
 
+func discordAPIBase() string {
+	apiBase := strings.TrimSpace(os.Getenv("DISCORD_API_BASE"))
+	if apiBase == "" {
+		apiBase = "https://discord.com/api"
+	}
+	return strings.TrimRight(apiBase, "/")
+}
+
 func cookieSecure() bool {
 	v := strings.ToLower(strings.TrimSpace(os.Getenv("SESSION_COOKIE_SECURE")))
 	return v == "1" || v == "true" || v == "yes"
@@ -167,16 +170,14 @@ func parseAdminEnvIDs() []string {
 	return out
 }
 
+// isAdminDiscordID reports whether the account has any admin role.
 func isAdminDiscordID(id string) bool {
-	if id == "" {
+	role, err := adminRole(id)
+	if err != nil {
+		log.Println("isAdminDiscordID:", err)
 		return false
 	}
-	for _, adminID := range parseAdminEnvIDs() {
-		if adminID == id {
-			return true
-		}
-	}
-	return false
+	return role != ""
 }
 
 func requireAdmin(c fiber.Ctx) (*SessionUser, error) {
@@ -378,12 +379,19 @@ func authMeHandler(c fiber.Ctx) error {
 		return c.JSON(fiber.Map{"authenticated": false})
 	}
 
+	role, err := adminRole(u.DiscordID)
+	if err != nil {
+		log.Println("authMeHandler:", err)
+	}
+
 	return c.JSON(fiber.Map{
-		"authenticated": true,
-		"discord_id":    u.DiscordID,
-		"username":      u.Username,
-		"avatar_url":    u.AvatarURL,
-		"is_admin":      isAdminDiscordID(u.DiscordID),
+		"authenticated":     true,
+		"discord_id":        u.DiscordID,
+		"username":          u.Username,
+		"avatar_url":        u.AvatarURL,
+		"is_admin":          role != "",
+		"admin_role":        role,
+		"admin_permissions": adminRolePermissions[role],
 	})
 }
 
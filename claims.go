@@ -0,0 +1,493 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const maxClaimEvidenceLength = 2000
+
+const serverClaimSelect = `
+	SELECT c.id,
+	       c.server_id,
+	       COALESCE(s.server_name, ''),
+	       c.discord_id,
+	       c.username,
+	       c.evidence,
+	       c.status,
+	       c.created_at,
+	       COALESCE(c.decided_at, ''),
+	       COALESCE((
+	           SELECT group_concat(o.username, ', ')
+	           FROM server_owners o
+	           WHERE o.server_id = c.server_id
+	       ), ''),
+	       CASE WHEN c.verified_at IS NOT NULL THEN COALESCE(c.verify_domain, '') ELSE '' END,
+	       COALESCE(s.url, '')
+	FROM server_claims c
+	LEFT JOIN servers s
+	  ON s.id = c.server_id
+`
+
+func scanServerClaim(row rowScanner) (ServerClaim, error) {
+	var (
+		cl                  ServerClaim
+		verifiedDomain, url string
+	)
+	err := row.Scan(
+		&cl.ID,
+		&cl.ServerID,
+		&cl.ServerName,
+		&cl.DiscordID,
+		&cl.Username,
+		&cl.Evidence,
+		&cl.Status,
+		&cl.CreatedAt,
+		&cl.DecidedAt,
+		&cl.CurrentOwners,
+		&verifiedDomain,
+		&url,
+	)
+	if err != nil {
+		return cl, err
+	}
+	// A verification only counts for the domain the listing still uses.
+	if verifiedDomain != "" && verifiedDomain == listingDomain(url) {
+		cl.VerifiedDomain = verifiedDomain
+	}
+	return cl, nil
+}
+
+func queryServerClaims(where string, args ...any) ([]ServerClaim, error) {
+	rows, err := Database.Query(serverClaimSelect+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	claims := make([]ServerClaim, 0, 8)
+	for rows.Next() {
+		cl, err := scanServerClaim(rows)
+		if err != nil {
+			return nil, err
+		}
+		claims = append(claims, cl)
+	}
+	return claims, rows.Err()
+}
+
+func postServerClaimHandler(c fiber.Ctx) error {
+	u, ok := getSessionUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).SendString("Sign in with Discord to claim a listing.")
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid server id")
+	}
+
+	type claimPayload struct {
+		Evidence string `json:"evidence"`
+	}
+
+	var payload claimPayload
+	if err := c.Bind().Body(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid json body")
+	}
+
+	evidence := strings.TrimSpace(payload.Evidence)
+	if evidence == "" {
+		return c.Status(fiber.StatusBadRequest).SendString("Explain how you run this server so an admin can check it.")
+	}
+	if len([]rune(evidence)) > maxClaimEvidenceLength {
+		return c.Status(fiber.StatusBadRequest).SendString(
+			fmt.Sprintf("Evidence must be at most %d characters.", maxClaimEvidenceLength),
+		)
+	}
+
+	var exists int
+	err = Database.QueryRow(`SELECT 1 FROM servers WHERE id = ?`, id).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).SendString("server not found")
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load server")
+	}
+
+	role, err := serverRole(u.DiscordID, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to check ownership")
+	}
+	if role == roleOwner {
+		return c.Status(fiber.StatusConflict).SendString("You already own this listing.")
+	}
+
+	var pending int
+	err = Database.QueryRow(`
+		SELECT COUNT(*)
+		FROM server_claims
+		WHERE server_id = ? AND discord_id = ? AND status = 'pending'
+	`, id, u.DiscordID).Scan(&pending)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to check claims")
+	}
+	if pending > 0 {
+		return c.Status(fiber.StatusConflict).SendString("You already have a pending claim for this listing.")
+	}
+
+	res, err := Database.Exec(`
+		INSERT INTO server_claims (server_id, discord_id, username, evidence, status, created_at)
+		VALUES (?, ?, ?, ?, 'pending', datetime('now'))
+	`, id, u.DiscordID, u.Username, evidence)
+	if err != nil {
+		log.Println("insert server_claim:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to save claim")
+	}
+
+	claimID, err := res.LastInsertId()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to get claim id")
+	}
+
+	cl, err := scanServerClaim(Database.QueryRow(serverClaimSelect+`WHERE c.id = ?`, claimID))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load claim")
+	}
+
+	notifyNewServerClaim(&cl)
+
+	return c.Status(fiber.StatusCreated).JSON(cl)
+}
+
+func getOwnerClaimsHandler(c fiber.Ctx) error {
+	u, ok := getSessionUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).SendString("unauthorized")
+	}
+
+	claims, err := queryServerClaims(`
+		WHERE c.discord_id = ?
+		ORDER BY c.created_at DESC
+		LIMIT 20
+	`, u.DiscordID)
+	if err != nil {
+		log.Println("getOwnerClaimsHandler:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load claims")
+	}
+
+	return c.JSON(fiber.Map{"claims": claims})
+}
+
+// loadClaimVerification returns the domain verification of a pending claim
+// the user made, and the domain the claimed listing uses now. Domain and
+// Token are empty until the claimant asks for a token.
+func loadClaimVerification(claimID int, discordID string) (*DomainVerification, string, error) {
+	var (
+		v   DomainVerification
+		url string
+	)
+	err := Database.QueryRow(`
+		SELECT c.server_id,
+		       COALESCE(c.verify_domain, ''),
+		       COALESCE(c.verify_token, ''),
+		       COALESCE(c.verify_method, ''),
+		       COALESCE(c.verified_at, ''),
+		       COALESCE(c.verify_checked_at, ''),
+		       COALESCE(c.verify_error, ''),
+		       COALESCE(s.url, '')
+		FROM server_claims c
+		JOIN servers s
+		  ON s.id = c.server_id
+		WHERE c.id = ? AND c.discord_id = ? AND c.status = 'pending'
+	`, claimID, discordID).Scan(
+		&v.ServerID,
+		&v.Domain,
+		&v.Token,
+		&v.Method,
+		&v.VerifiedAt,
+		&v.LastCheckedAt,
+		&v.LastError,
+		&url,
+	)
+	if err != nil {
+		return nil, "", err
+	}
+	if v.Token != "" {
+		setVerificationInstructions(&v)
+	}
+	return &v, listingDomain(url), nil
+}
+
+// claimVerification loads the verification of the claim in the :id param
+// for the signed-in claimant.
+func claimVerification(c fiber.Ctx) (int, *DomainVerification, string, error) {
+	u, ok := getSessionUser(c)
+	if !ok {
+		return 0, nil, "", fiber.NewError(fiber.StatusUnauthorized, "unauthorized")
+	}
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id <= 0 {
+		return 0, nil, "", fiber.NewError(fiber.StatusBadRequest, "invalid claim id")
+	}
+
+	v, domain, err := loadClaimVerification(id, u.DiscordID)
+	if err == sql.ErrNoRows {
+		return 0, nil, "", fiber.NewError(fiber.StatusNotFound, "claim not found or already processed")
+	}
+	if err != nil {
+		log.Println("loadClaimVerification:", err)
+		return 0, nil, "", fiber.NewError(fiber.StatusInternalServerError, "failed to load verification")
+	}
+	if domain == "" {
+		return 0, nil, "", fiber.NewError(fiber.StatusBadRequest, "This listing has no website link to verify.")
+	}
+	return id, v, domain, nil
+}
+
+// getClaimVerificationHandler returns the token a claimant publishes to
+// back their claim. Claimants get their own token, separate from the one the
+// current owners may already have published.
+func getClaimVerificationHandler(c fiber.Ctx) error {
+	id, v, domain, err := claimVerification(c)
+	if err != nil {
+		return err
+	}
+	if v.Token != "" && v.Domain == domain {
+		return c.JSON(v)
+	}
+
+	token, err := generateVerificationToken()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to create token")
+	}
+	_, err = Database.Exec(`
+		UPDATE server_claims
+		SET verify_domain     = ?,
+		    verify_token      = ?,
+		    verify_method     = NULL,
+		    verified_at       = NULL,
+		    verify_checked_at = NULL,
+		    verify_error      = NULL
+		WHERE id = ?
+	`, domain, token, id)
+	if err != nil {
+		log.Println("getClaimVerificationHandler:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to save token")
+	}
+
+	v.Domain, v.Token = domain, token
+	v.Method, v.VerifiedAt, v.LastCheckedAt, v.LastError = "", "", "", ""
+	setVerificationInstructions(v)
+	return c.JSON(v)
+}
+
+// postClaimVerificationCheckHandler checks the claimant's token once. The
+// result is only shown to admins reviewing the claim; the listing keeps its
+// badge until the claim is approved.
+func postClaimVerificationCheckHandler(c fiber.Ctx) error {
+	id, v, domain, err := claimVerification(c)
+	if err != nil {
+		return err
+	}
+	if v.Token == "" {
+		return c.Status(fiber.StatusNotFound).SendString("request a verification token first")
+	}
+	if v.Domain != domain {
+		return c.Status(fiber.StatusConflict).SendString("The listing moved to another domain. Request a new token.")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), domainCheckTimeout)
+	defer cancel()
+	method, checkErr := defaultDomainVerifier.check(ctx, v.Domain, v.Token)
+
+	if checkErr == nil {
+		_, err = Database.Exec(`
+			UPDATE server_claims
+			SET verify_method     = ?,
+			    verified_at       = COALESCE(verified_at, datetime('now')),
+			    verify_checked_at = datetime('now'),
+			    verify_error      = NULL
+			WHERE id = ? AND verify_token = ?
+		`, method, id, v.Token)
+	} else {
+		_, err = Database.Exec(`
+			UPDATE server_claims
+			SET verify_method     = NULL,
+			    verified_at       = NULL,
+			    verify_checked_at = datetime('now'),
+			    verify_error      = ?
+			WHERE id = ? AND verify_token = ?
+		`, checkErr.Error(), id, v.Token)
+	}
+	if err != nil {
+		log.Println("postClaimVerificationCheckHandler:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to save check")
+	}
+
+	if _, v, _, err = claimVerification(c); err != nil {
+		return err
+	}
+	if checkErr != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(v)
+	}
+	return c.JSON(v)
+}
+
+func getAdminClaimsHandler(c fiber.Ctx) error {
+	if _, err := requireAdmin(c); err != nil {
+		return err
+	}
+
+	claims, err := queryServerClaims(`
+		WHERE c.status = 'pending'
+		ORDER BY c.created_at ASC
+	`)
+	if err != nil {
+		log.Println("getAdminClaimsHandler:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load claims")
+	}
+
+	return c.JSON(fiber.Map{"claims": claims})
+}
+
+// postAdminApproveClaimHandler makes the claimant the only owner of the
+// listing. Everything tied to the old team (its members, their pending
+// invites and edits, and competing claims) is closed in the same
+// transaction.
+func postAdminApproveClaimHandler(c fiber.Ctx) error {
+	admin, err := requireAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	cl, err := scanServerClaim(Database.QueryRow(serverClaimSelect+`WHERE c.id = ? AND c.status = 'pending'`, c.Params("id")))
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).SendString("claim not found or already processed")
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load claim")
+	}
+	if cl.ServerName == "" {
+		return c.Status(fiber.StatusNotFound).SendString("server no longer exists")
+	}
+
+	tx, err := Database.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE server_claims
+		SET status = 'approved', decided_at = datetime('now'), decided_by = ?
+		WHERE id = ? AND status = 'pending'
+	`, admin.DiscordID, cl.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to update claim")
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return c.Status(fiber.StatusNotFound).SendString("claim not found or already processed")
+	}
+
+	rows, err := tx.Query(`
+		SELECT discord_id
+		FROM server_owners
+		WHERE server_id = ? AND discord_id != ?
+	`, cl.ServerID, cl.DiscordID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load owners")
+	}
+	previousOwners := make([]string, 0, 4)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return c.Status(fiber.StatusInternalServerError).SendString("failed to load owners")
+		}
+		previousOwners = append(previousOwners, id)
+	}
+	rows.Close()
+
+	steps := []struct {
+		query string
+		args  []any
+	}{
+		{`DELETE FROM server_owners WHERE server_id = ?`, []any{cl.ServerID}},
+		{`INSERT INTO server_owners (server_id, discord_id, username, role, added_at)
+		  VALUES (?, ?, ?, 'owner', datetime('now'))`, []any{cl.ServerID, cl.DiscordID, cl.Username}},
+		{`UPDATE server_invites SET status = 'cancelled', responded_at = datetime('now')
+		  WHERE server_id = ? AND status = 'pending'`, []any{cl.ServerID}},
+		{`UPDATE server_requests SET status = 'rejected'
+		  WHERE kind = 'edit' AND server_id = ? AND status = 'pending'`, []any{cl.ServerID}},
+		{`UPDATE server_claims SET status = 'rejected', decided_at = datetime('now'), decided_by = ?
+		  WHERE server_id = ? AND status = 'pending'`, []any{admin.DiscordID, cl.ServerID}},
+		// The old team's verification goes. A domain the claimant verified
+		// carries over; otherwise the new owner has to verify it again.
+		{`DELETE FROM domain_verifications WHERE server_id = ?`, []any{cl.ServerID}},
+		{`INSERT INTO domain_verifications (server_id, domain, token, method, verified_at, last_checked_at, created_at)
+		  SELECT server_id, verify_domain, verify_token, verify_method, verified_at, verify_checked_at, datetime('now')
+		  FROM server_claims
+		  WHERE id = ? AND verified_at IS NOT NULL AND verify_domain = ?`, []any{cl.ID, cl.VerifiedDomain}},
+		{`UPDATE servers SET verified_domain = NULLIF(?, '') WHERE id = ?`, []any{cl.VerifiedDomain, cl.ServerID}},
+	}
+	for _, step := range steps {
+		if _, err := tx.Exec(step.query, step.args...); err != nil {
+			log.Println("approve claim:", err)
+			return c.Status(fiber.StatusInternalServerError).SendString("failed to reassign ownership")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to commit transaction")
+	}
+
+	after := cl
+	after.Status = "approved"
+	recordAdminAction(c, "claim.approve", "claim", cl.ID, cl, after)
+
+	notifyClaimApproved(&cl, previousOwners)
+
+	return c.JSON(fiber.Map{"ok": true, "server_id": cl.ServerID})
+}
+
+func postAdminRejectClaimHandler(c fiber.Ctx) error {
+	admin, err := requireAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	cl, err := scanServerClaim(Database.QueryRow(serverClaimSelect+`WHERE c.id = ? AND c.status = 'pending'`, c.Params("id")))
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).SendString("claim not found or already processed")
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load claim")
+	}
+
+	res, err := Database.Exec(`
+		UPDATE server_claims
+		SET status = 'rejected', decided_at = datetime('now'), decided_by = ?
+		WHERE id = ? AND status = 'pending'
+	`, admin.DiscordID, cl.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to update claim")
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return c.Status(fiber.StatusNotFound).SendString("claim not found or already processed")
+	}
+
+	after := cl
+	after.Status = "rejected"
+	recordAdminAction(c, "claim.reject", "claim", cl.ID, cl, after)
+
+	notifyClaimRejected(&cl)
+
+	return c.JSON(fiber.Map{"ok": true})
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestClaimDomainVerification(t *testing.T) {
+	srv := newTestServer(t)
+	domains := &fakeDomain{}
+	useFakeDomains(t, domains)
+
+	const ownerID, claimantID = "100000000000000010", "100000000000000011"
+	serverID := insertTestServer(t, "Claimed", "https://claimed.example/play", 0)
+	if _, err := Database.Exec(`
+		INSERT INTO server_owners (server_id, discord_id, username, role, added_at)
+		VALUES (?, ?, 'old-owner', 'owner', datetime('now'))
+	`, serverID, ownerID); err != nil {
+		t.Fatal(err)
+	}
+	// The current owner already published their own token.
+	owner, err := ensureDomainVerification(serverID, "claimed.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	domains.txt = map[string][]string{"claimed.example": {owner.TXTRecord}}
+
+	claimant := testSessionToken(t, claimantID)
+	admin := testSessionToken(t, testAdminID)
+
+	status, body := testRequest(t, srv, http.MethodPost, "/api/servers/"+strconv.Itoa(serverID)+"/claims", claimant,
+		map[string]string{"evidence": "I run the server now."})
+	if status != http.StatusCreated {
+		t.Fatalf("claim: %d %s", status, body)
+	}
+	var cl ServerClaim
+	if err := json.Unmarshal(body, &cl); err != nil {
+		t.Fatal(err)
+	}
+	base := "/api/owner/claims/" + strconv.Itoa(cl.ID) + "/verification"
+
+	if status, _ := testRequest(t, srv, http.MethodGet, base, testSessionToken(t, ownerID), nil); status != http.StatusNotFound {
+		t.Fatalf("someone else's claim: status %d, want 404", status)
+	}
+
+	status, body = testRequest(t, srv, http.MethodGet, base, claimant, nil)
+	if status != http.StatusOK {
+		t.Fatalf("verification: %d %s", status, body)
+	}
+	var v DomainVerification
+	if err := json.Unmarshal(body, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Domain != "claimed.example" || v.Token == "" || v.Token == owner.Token {
+		t.Fatalf("verification = %+v, want a fresh token for claimed.example", v)
+	}
+
+	// The owner's record doesn't prove anything for the claimant.
+	if status, body := testRequest(t, srv, http.MethodPost, base+"/check", claimant, nil); status != http.StatusUnprocessableEntity {
+		t.Fatalf("check without the claimant's token: %d %s", status, body)
+	}
+
+	domains.wellKnown = map[string]string{v.WellKnownURL: v.Token + "\n"}
+	status, body = testRequest(t, srv, http.MethodPost, base+"/check", claimant, nil)
+	if status != http.StatusOK {
+		t.Fatalf("check: %d %s", status, body)
+	}
+
+	var listed struct {
+		Claims []ServerClaim `json:"claims"`
+	}
+	status, body = testRequest(t, srv, http.MethodGet, "/api/admin/claims", admin, nil)
+	if status != http.StatusOK {
+		t.Fatalf("admin claims: %d %s", status, body)
+	}
+	if err := json.Unmarshal(body, &listed); err != nil {
+		t.Fatal(err)
+	}
+	if len(listed.Claims) != 1 || listed.Claims[0].VerifiedDomain != "claimed.example" {
+		t.Fatalf("admin claims = %+v, want claimed.example verified", listed.Claims)
+	}
+
+	status, body = testRequest(t, srv, http.MethodPost, "/api/admin/claims/"+strconv.Itoa(cl.ID)+"/approve", admin, nil)
+	if status != http.StatusOK {
+		t.Fatalf("approve: %d %s", status, body)
+	}
+
+	// The claimant's verification carries over to the listing.
+	dv, err := loadDomainVerification(serverID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dv.Token != v.Token || dv.VerifiedAt == "" || dv.Method != "http" {
+		t.Fatalf("listing verification = %+v, want the claimant's", dv)
+	}
+	var verified string
+	if err := Database.QueryRow(`SELECT COALESCE(verified_domain, '') FROM servers WHERE id = ?`, serverID).Scan(&verified); err != nil {
+		t.Fatal(err)
+	}
+	if verified != "claimed.example" {
+		t.Fatalf("verified_domain = %q", verified)
+	}
+}
+
+func TestClaimVerificationFollowsListingDomain(t *testing.T) {
+	srv := newTestServer(t)
+	domains := &fakeDomain{}
+	useFakeDomains(t, domains)
+
+	serverID := insertTestServer(t, "Moving", "https://old.example", 0)
+	claimant := testSessionToken(t, "100000000000000012")
+
+	status, body := testRequest(t, srv, http.MethodPost, "/api/servers/"+strconv.Itoa(serverID)+"/claims", claimant,
+		map[string]string{"evidence": "It's mine."})
+	if status != http.StatusCreated {
+		t.Fatalf("claim: %d %s", status, body)
+	}
+	var cl ServerClaim
+	if err := json.Unmarshal(body, &cl); err != nil {
+		t.Fatal(err)
+	}
+	base := "/api/owner/claims/" + strconv.Itoa(cl.ID) + "/verification"
+
+	_, body = testRequest(t, srv, http.MethodGet, base, claimant, nil)
+	var v DomainVerification
+	if err := json.Unmarshal(body, &v); err != nil {
+		t.Fatal(err)
+	}
+	domains.txt = map[string][]string{"old.example": {v.TXTRecord}}
+	if status, body := testRequest(t, srv, http.MethodPost, base+"/check", claimant, nil); status != http.StatusOK {
+		t.Fatalf("check: %d %s", status, body)
+	}
+
+	if _, err := Database.Exec(`UPDATE servers SET url = 'https://new.example' WHERE id = ?`, serverID); err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := queryServerClaims(`WHERE c.id = ?`, cl.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims[0].VerifiedDomain != "" {
+		t.Fatalf("VerifiedDomain = %q after the listing moved", claims[0].VerifiedDomain)
+	}
+	if status, _ := testRequest(t, srv, http.MethodPost, base+"/check", claimant, nil); status != http.StatusConflict {
+		t.Fatalf("check after the move: status %d, want 409", status)
+	}
+
+	_, body = testRequest(t, srv, http.MethodGet, base, claimant, nil)
+	var moved DomainVerification
+	if err := json.Unmarshal(body, &moved); err != nil {
+		t.Fatal(err)
+	}
+	if moved.Domain != "new.example" || moved.Token == v.Token || moved.VerifiedAt != "" {
+		t.Fatalf("verification after the move = %+v", moved)
+	}
+}
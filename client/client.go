@@ -0,0 +1,447 @@
+// Package client is a Go client for the mossai HTTP API.
+//
+// Public endpoints (leaderboard, server details, search) work anonymously or
+// with an API key. Vote checks need a key with the vote-check scope. Admin
+// actions authenticate with a mossai session token, the value of the
+// mossai_session cookie of an admin account.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"mossai/api"
+)
+
+const (
+	defaultTimeout    = 15 * time.Second
+	defaultMaxRetries = 2
+	defaultBackoff    = 500 * time.Millisecond
+	maxBackoff        = 10 * time.Second
+	maxErrorBody      = 4 << 10
+)
+
+// Error is returned when mossai answers with a non-2xx status.
+type Error struct {
+	StatusCode int
+	Message    string
+	// RetryAfter is set from the Retry-After header on 429 responses.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("mossai: HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("mossai: HTTP %d: %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether err is a 404 from mossai.
+func IsNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
+
+type Client struct {
+	baseURL      *url.URL
+	httpClient   *http.Client
+	apiKey       string
+	sessionToken string
+	userAgent    string
+	maxRetries   int
+	backoff      time.Duration
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		if hc != nil {
+			c.httpClient = hc
+		}
+	}
+}
+
+// WithAPIKey sends key in the X-API-Key header on every request.
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = strings.TrimSpace(key)
+	}
+}
+
+// WithSession authenticates admin actions with a mossai session token.
+func WithSession(token string) Option {
+	return func(c *Client) {
+		c.sessionToken = strings.TrimSpace(token)
+	}
+}
+
+// WithUserAgent overrides the User-Agent header.
+func WithUserAgent(ua string) Option {
+	return func(c *Client) {
+		c.userAgent = ua
+	}
+}
+
+// WithRetries sets how many times a failed request is retried and the base
+// delay of the exponential backoff between attempts. Zero retries disables
+// retrying.
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		if maxRetries < 0 {
+			maxRetries = 0
+		}
+		c.maxRetries = maxRetries
+		if backoff > 0 {
+			c.backoff = backoff
+		}
+	}
+}
+
+// New returns a client for the mossai instance at baseURL, for example
+// "https://mossai.example".
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(strings.TrimSpace(baseURL), "/"))
+	if err != nil {
+		return nil, fmt.Errorf("mossai: invalid base url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("mossai: base url must be http or https")
+	}
+
+	c := &Client{
+		baseURL:    u,
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		userAgent:  "mossai-go-client",
+		maxRetries: defaultMaxRetries,
+		backoff:    defaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// Leaderboard returns all listed servers ranked by votes.
+func (c *Client) Leaderboard(ctx context.Context) ([]api.ServerResult, error) {
+	var out []api.ServerResult
+	if err := c.do(ctx, http.MethodGet, "/leaderboard", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// LeaderboardFilter narrows FilterLeaderboard to servers with the given
+// features. Empty fields do not filter.
+type LeaderboardFilter struct {
+	Mode         string
+	Leaderboard  string
+	PPSystem     string
+	Registration string
+	Client       string
+	// UnrankedRankable, when set, matches servers that do or do not award
+	// pp on unranked maps.
+	UnrankedRankable *bool
+	// Sort is "votes" (the default) or "rating".
+	Sort string
+}
+
+// FilterLeaderboard returns the servers matching f, ranked by votes unless
+// f.Sort says otherwise.
+func (c *Client) FilterLeaderboard(ctx context.Context, f LeaderboardFilter) ([]api.ServerResult, error) {
+	q := url.Values{}
+	for key, v := range map[string]string{
+		"mode":         f.Mode,
+		"leaderboard":  f.Leaderboard,
+		"pp_system":    f.PPSystem,
+		"registration": f.Registration,
+		"client":       f.Client,
+		"sort":         f.Sort,
+	} {
+		if v != "" {
+			q.Set(key, v)
+		}
+	}
+	if f.UnrankedRankable != nil {
+		q.Set("unranked_rankable", strconv.FormatBool(*f.UnrankedRankable))
+	}
+
+	var out []api.ServerResult
+	if err := c.do(ctx, http.MethodGet, "/leaderboard", q, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server returns a single server by id.
+func (c *Client) Server(ctx context.Context, id int) (*api.ServerResult, error) {
+	var out api.ServerResult
+	if err := c.do(ctx, http.MethodGet, "/server/"+strconv.Itoa(id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Events returns the site-wide announcement feed. With upcoming set it only
+// lists scheduled events that have not ended, soonest first.
+func (c *Client) Events(ctx context.Context, upcoming bool) ([]api.Announcement, error) {
+	q := url.Values{}
+	if upcoming {
+		q.Set("upcoming", "true")
+	}
+
+	var out []api.Announcement
+	if err := c.do(ctx, http.MethodGet, "/events", q, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SearchParams filters Search results. At least one field must be set.
+type SearchParams struct {
+	// Query matches the server name, description or tags.
+	Query string
+	// Tag matches one tag exactly (case-insensitive).
+	Tag string
+}
+
+// Search returns servers matching p, ranked by votes.
+func (c *Client) Search(ctx context.Context, p SearchParams) ([]api.ServerResult, error) {
+	q := url.Values{}
+	if p.Query != "" {
+		q.Set("q", p.Query)
+	}
+	if p.Tag != "" {
+		q.Set("tag", p.Tag)
+	}
+
+	var out []api.ServerResult
+	if err := c.do(ctx, http.MethodGet, "/search", q, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CheckVote reports whether the player name voted for a server in the
+// current 12 hour window. It requires an API key with the vote-check scope.
+func (c *Client) CheckVote(ctx context.Context, serverID int, name string) (*api.VoteCheck, error) {
+	q := url.Values{}
+	q.Set("name", name)
+
+	var out api.VoteCheck
+	path := "/server/" + strconv.Itoa(serverID) + "/vote/check"
+	if err := c.do(ctx, http.MethodGet, path, q, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PendingRequests returns the new listings and owner edits waiting for
+// review. Edits carry the fields they change in Changes.
+func (c *Client) PendingRequests(ctx context.Context) ([]api.ServerRequest, error) {
+	var out []api.ServerRequest
+	if err := c.do(ctx, http.MethodGet, "/admin/requests/data", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RequestUpdate is the editable part of a pending server request.
+type RequestUpdate struct {
+	ServerName      string             `json:"server_name"`
+	URL             string             `json:"url"`
+	LogoURL         string             `json:"logo_url"`
+	Description     string             `json:"description"`
+	LongDescription string             `json:"long_description"`
+	Tags            []string           `json:"tags"`
+	Features        api.ServerFeatures `json:"features"`
+	Links           []api.ServerLink   `json:"links"`
+	OwnerName       string             `json:"owner_name"`
+	OwnerDiscord    string             `json:"owner_discord"`
+}
+
+// UpdateRequest edits a pending server request.
+func (c *Client) UpdateRequest(ctx context.Context, id int, u RequestUpdate) error {
+	return c.do(ctx, http.MethodPost, "/admin/requests/"+strconv.Itoa(id)+"/update", nil, u, nil)
+}
+
+// ApproveRequest approves a pending request and returns the id of the server
+// it created or edited.
+func (c *Client) ApproveRequest(ctx context.Context, id int) (int64, error) {
+	var out struct {
+		ServerID int64 `json:"server_id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/admin/requests/"+strconv.Itoa(id)+"/approve", nil, nil, &out); err != nil {
+		return 0, err
+	}
+	return out.ServerID, nil
+}
+
+// Rejection is why a request is rejected. Code is one of the canned reasons
+// from RejectionReasons; Reason is free text shown to the submitter. At
+// least one is required, and Reason is required with the code "other".
+type Rejection struct {
+	Code   string `json:"code,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// RejectRequest rejects a pending request.
+func (c *Client) RejectRequest(ctx context.Context, id int, r Rejection) error {
+	return c.do(ctx, http.MethodPost, "/admin/requests/"+strconv.Itoa(id)+"/reject", nil, r, nil)
+}
+
+// RejectionReasons lists the canned reasons admins can reject requests with.
+func (c *Client) RejectionReasons(ctx context.Context) ([]api.RejectionReason, error) {
+	var out struct {
+		Reasons []api.RejectionReason `json:"reasons"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/admin/rejection-reasons", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Reasons, nil
+}
+
+// RemoveServer deletes a live listing.
+func (c *Client) RemoveServer(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodPost, "/api/admin/servers/"+strconv.Itoa(id)+"/remove", nil, nil, nil)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	var payload []byte
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("mossai: encode request: %w", err)
+		}
+		payload = b
+	}
+
+	u := *c.baseURL
+	u.Path = strings.TrimRight(u.Path, "/") + path
+	if query != nil {
+		u.RawQuery = query.Encode()
+	}
+
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, u.String(), payload)
+		if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			defer resp.Body.Close()
+			if out == nil {
+				io.Copy(io.Discard, resp.Body)
+				return nil
+			}
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				return fmt.Errorf("mossai: decode response: %w", err)
+			}
+			return nil
+		}
+
+		var wait time.Duration
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			lastErr = fmt.Errorf("mossai: %s %s: %w", method, path, err)
+		} else {
+			apiErr := readError(resp)
+			lastErr = apiErr
+			wait = apiErr.RetryAfter
+		}
+
+		if attempt >= c.maxRetries || !retryable(method, resp, err) {
+			return lastErr
+		}
+
+		if backoff := c.backoffFor(attempt); wait < backoff {
+			wait = backoff
+		}
+		if wait > maxBackoff {
+			wait = maxBackoff
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, rawURL string, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	if c.sessionToken != "" {
+		req.AddCookie(&http.Cookie{Name: "mossai_session", Value: c.sessionToken})
+	}
+
+	return c.httpClient.Do(req)
+}
+
+func (c *Client) backoffFor(attempt int) time.Duration {
+	return c.backoff << attempt
+}
+
+// retryable reports whether a failed attempt may be repeated. Reads are
+// retried on transport errors and 429/5xx; writes only when the server
+// rate limited them, because it then never ran the handler.
+func retryable(method string, resp *http.Response, err error) bool {
+	if err != nil {
+		return method == http.MethodGet
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if method != http.MethodGet {
+		return false
+	}
+	switch resp.StatusCode {
+	case http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func readError(resp *http.Response) *Error {
+	defer resp.Body.Close()
+
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	e := &Error{
+		StatusCode: resp.StatusCode,
+		Message:    strings.TrimSpace(string(b)),
+	}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		e.RetryAfter = time.Duration(secs) * time.Second
+	}
+	return e
+}
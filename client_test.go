@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mossai/client"
+)
+
+func insertTestRequest(t *testing.T, name string) int {
+	t.Helper()
+
+	res, err := Database.Exec(`
+		INSERT INTO server_requests (server_name, url, description, tags, owner_name, owner_discord, created_at)
+		VALUES (?, 'https://example.com', 'A pending server', 'pp', 'owner', '100000000000000002', datetime('now'))
+	`, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := res.LastInsertId()
+	return int(id)
+}
+
+func TestClientPublicEndpoints(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+
+	top := insertTestServer(t, "Akatsuki Test", "https://akatsuki.example", 30)
+	insertTestServer(t, "Bancho Test", "https://bancho.example", 10)
+
+	if _, err := Database.Exec(`
+		INSERT INTO votes (server, ip, user_name, last_vote)
+		VALUES (?, '127.0.0.1', 'cookiezi', datetime('now', '-1 hour'))
+	`, top); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := client.New(srv.URL, client.WithAPIKey(insertTestAPIKey(t, "read,vote-check")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	servers, err := c.Leaderboard(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(servers) != 2 || servers[0].ID != top {
+		t.Fatalf("Leaderboard = %+v, want %d first", servers, top)
+	}
+
+	s, err := c.Server(ctx, top)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ServerName != "Akatsuki Test" || s.Votes != 30 {
+		t.Fatalf("Server = %+v", s)
+	}
+
+	found, err := c.Search(ctx, client.SearchParams{Query: "bancho"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].ServerName != "Bancho Test" {
+		t.Fatalf("Search = %+v", found)
+	}
+
+	check, err := c.CheckVote(ctx, top, "Cookiezi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !check.Voted || check.NextVoteAt == "" {
+		t.Fatalf("CheckVote = %+v, want voted", check)
+	}
+	check, err = c.CheckVote(ctx, top, "someone-else")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.Voted {
+		t.Fatalf("CheckVote = %+v, want not voted", check)
+	}
+
+	_, err = c.Server(ctx, 9999)
+	if !client.IsNotFound(err) {
+		t.Fatalf("Server(9999) error = %v, want not found", err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+	id := insertTestServer(t, "Gatari Test", "https://gatari.example", 0)
+
+	anon, err := client.New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = anon.CheckVote(ctx, id, "peppy")
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("anonymous CheckVote error = %v, want 401", err)
+	}
+	if apiErr.Message != "an api key with the vote-check scope is required" {
+		t.Fatalf("Message = %q", apiErr.Message)
+	}
+	if client.IsNotFound(err) {
+		t.Fatal("IsNotFound(401) = true")
+	}
+
+	readOnly, err := client.New(srv.URL, client.WithAPIKey(insertTestAPIKey(t, "read")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = readOnly.CheckVote(ctx, id, "peppy")
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("read-only CheckVote error = %v, want 403", err)
+	}
+
+	// Admin calls need a session of an account that is an admin.
+	notAdmin, err := client.New(srv.URL, client.WithSession(testSessionToken(t, "100000000000000003")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = notAdmin.RemoveServer(ctx, id)
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("RemoveServer as non-admin error = %v, want 403", err)
+	}
+}
+
+func TestClientAdminEndpoints(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+
+	approveID := insertTestRequest(t, "Ripple Test")
+	rejectID := insertTestRequest(t, "Spam Test")
+
+	c, err := client.New(srv.URL, client.WithSession(testSessionToken(t, testAdminID)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := c.PendingRequests(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 {
+		t.Fatalf("PendingRequests = %d requests, want 2", len(pending))
+	}
+
+	err = c.UpdateRequest(ctx, approveID, client.RequestUpdate{
+		ServerName:   "Ripple",
+		URL:          "https://ripple.example",
+		Description:  "Edited by an admin",
+		Tags:         []string{"pp"},
+		OwnerName:    "owner",
+		OwnerDiscord: "100000000000000002",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverID, err := c.ApproveRequest(ctx, approveID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := c.Server(ctx, int(serverID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ServerName != "Ripple" || s.Description != "Edited by an admin" {
+		t.Fatalf("approved server = %+v", s)
+	}
+
+	if _, err := c.ApproveRequest(ctx, approveID); !client.IsNotFound(err) {
+		t.Fatalf("approving twice error = %v, want not found", err)
+	}
+
+	reasons, err := c.RejectionReasons(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reasons) == 0 {
+		t.Fatal("RejectionReasons is empty")
+	}
+
+	var apiErr *client.Error
+	err = c.RejectRequest(ctx, rejectID, client.Rejection{Code: "no-such-code"})
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("RejectRequest with unknown code error = %v, want 400", err)
+	}
+	if err := c.RejectRequest(ctx, rejectID, client.Rejection{Code: reasons[0].Code}); err != nil {
+		t.Fatal(err)
+	}
+
+	var status, code string
+	if err := Database.QueryRow(
+		`SELECT status, COALESCE(decision_code, '') FROM server_requests WHERE id = ?`, rejectID,
+	).Scan(&status, &code); err != nil {
+		t.Fatal(err)
+	}
+	if status != "rejected" || code != reasons[0].Code {
+		t.Fatalf("rejected request has status %q and code %q", status, code)
+	}
+
+	if err := c.RemoveServer(ctx, int(serverID)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Server(ctx, int(serverID)); !client.IsNotFound(err) {
+		t.Fatalf("Server after removal error = %v, want not found", err)
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	ctx := context.Background()
+
+	// flaky answers with status for the first fails attempts, then 200.
+	flaky := func(status, fails int, retryAfter string) (*httptest.Server, *atomic.Int32) {
+		var attempts atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if int(attempts.Add(1)) <= fails {
+				if retryAfter != "" {
+					w.Header().Set("Retry-After", retryAfter)
+				}
+				http.Error(w, "try again", status)
+				return
+			}
+			w.Write([]byte(`{"id": 1, "server_name": "Retried"}`))
+		}))
+		t.Cleanup(srv.Close)
+		return srv, &attempts
+	}
+
+	newClient := func(url string) *client.Client {
+		c, err := client.New(url, client.WithRetries(2, time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	t.Run("get retries 5xx", func(t *testing.T) {
+		srv, attempts := flaky(http.StatusServiceUnavailable, 2, "")
+		s, err := newClient(srv.URL).Server(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.ServerName != "Retried" || attempts.Load() != 3 {
+			t.Fatalf("got %+v after %d attempts", s, attempts.Load())
+		}
+	})
+
+	t.Run("get gives up", func(t *testing.T) {
+		srv, attempts := flaky(http.StatusBadGateway, 5, "")
+		_, err := newClient(srv.URL).Server(ctx, 1)
+		var apiErr *client.Error
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway || apiErr.Message != "try again" {
+			t.Fatalf("error = %v, want 502", err)
+		}
+		if attempts.Load() != 3 {
+			t.Fatalf("%d attempts, want 3", attempts.Load())
+		}
+	})
+
+	t.Run("write retries 429", func(t *testing.T) {
+		srv, attempts := flaky(http.StatusTooManyRequests, 1, "1")
+		if err := newClient(srv.URL).RemoveServer(ctx, 1); err != nil {
+			t.Fatal(err)
+		}
+		if attempts.Load() != 2 {
+			t.Fatalf("%d attempts, want 2", attempts.Load())
+		}
+	})
+
+	t.Run("write does not retry 5xx", func(t *testing.T) {
+		srv, attempts := flaky(http.StatusInternalServerError, 1, "")
+		err := newClient(srv.URL).RemoveServer(ctx, 1)
+		var apiErr *client.Error
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+			t.Fatalf("error = %v, want 500", err)
+		}
+		if attempts.Load() != 1 {
+			t.Fatalf("%d attempts, want 1", attempts.Load())
+		}
+	})
+
+	t.Run("retry after is reported", func(t *testing.T) {
+		srv, _ := flaky(http.StatusTooManyRequests, 5, "7")
+		c, err := client.New(srv.URL, client.WithRetries(0, time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = c.Leaderboard(ctx)
+		var apiErr *client.Error
+		if !errors.As(err, &apiErr) || apiErr.RetryAfter != 7*time.Second {
+			t.Fatalf("error = %#v, want RetryAfter 7s", err)
+		}
+	})
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"regexp"
 	"strings"
 
 	_ "github.com/ncruces/go-sqlite3/driver"
@@ -11,9 +13,20 @@ import (
 var Database *sql.DB
 
 func ConnectSQL() {
+	openDatabase("mossai.db")
+}
+
+// openDatabase opens the SQLite database at path as Database.
+func openDatabase(path string) {
 	db, err := sql.Open(
 		"sqlite3",
-		"file:mossai.db?_journal=WAL&_synchronous=NORMAL&_foreign_keys=ON&_temp_store=MEMORY&_mmap_size=268435456",
+		"file:"+path+
+			"?_pragma=busy_timeout(10000)"+
+			"&_pragma=journal_mode(wal)"+
+			"&_pragma=synchronous(normal)"+
+			"&_pragma=foreign_keys(1)"+
+			"&_pragma=temp_store(memory)"+
+			"&_pragma=mmap_size(268435456)",
 	)
 	if err != nil {
 		panic(err)
@@ -48,19 +61,22 @@ func SetupSQL() {
 			ip        TEXT     NOT NULL,
 			user_name TEXT     NOT NULL,
 			last_vote DATETIME NOT NULL,
-			FOREIGN KEY(server) REFERENCES servers(id)
+			FOREIGN KEY(server) REFERENCES servers(id) ON DELETE CASCADE
 		);
 	`); err != nil {
 		panic(err)
 	}
 
+	// discord_id is the account that voted, when the visitor was signed in.
+	addColumn("votes", "discord_id TEXT")
+
 	if _, err := Database.Exec(`
 		CREATE TABLE IF NOT EXISTS users (
 			id        INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
 			username  TEXT    NOT NULL,
 			discordid TEXT    NOT NULL,
 			server    INTEGER,
-			FOREIGN KEY(server) REFERENCES servers(id)
+			FOREIGN KEY(server) REFERENCES servers(id) ON DELETE SET NULL
 		);
 	`); err != nil {
 		panic(err)
@@ -77,18 +93,19 @@ func SetupSQL() {
 			owner_discord TEXT    NOT NULL,
 			logo_url      TEXT,
 			status        TEXT    NOT NULL DEFAULT 'pending',
-			created_at    DATETIME NOT NULL
+			created_at    DATETIME NOT NULL,
+			kind          TEXT    NOT NULL DEFAULT 'new',
+			server_id     INTEGER
 		);
 	`); err != nil {
 		panic(err)
 	}
 
-	if _, err := Database.Exec(`ALTER TABLE server_requests ADD COLUMN logo_url TEXT`); err != nil {
-		lower := strings.ToLower(err.Error())
-		if !strings.Contains(lower, "duplicate column name") {
-			panic(err)
-		}
-	}
+	addColumn("server_requests", "logo_url TEXT")
+	addColumn("server_requests", "kind TEXT NOT NULL DEFAULT 'new'")
+	addColumn("server_requests", "server_id INTEGER")
+	addColumn("server_requests", "decision_reason TEXT")
+	addColumn("server_requests", "decision_code TEXT")
 
 	if _, err := Database.Exec(`
 		CREATE TABLE IF NOT EXISTS admin_users (
@@ -98,4 +115,501 @@ func SetupSQL() {
 	`); err != nil {
 		panic(err)
 	}
+
+	addColumn("admin_users", "role TEXT")
+	addColumn("admin_users", "added_by TEXT")
+	addColumn("admin_users", "created_at DATETIME")
+
+	// Rows from before roles existed only had the request flag.
+	if _, err := Database.Exec(`
+		UPDATE admin_users
+		SET role = CASE WHEN can_manage_requests = 1 THEN 'moderator' ELSE 'support' END
+		WHERE role IS NULL
+	`); err != nil {
+		panic(err)
+	}
+
+	if _, err := Database.Exec(`
+		CREATE TABLE IF NOT EXISTS api_keys (
+			id            INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+			name          TEXT    NOT NULL,
+			key_hash      TEXT    NOT NULL UNIQUE,
+			key_prefix    TEXT    NOT NULL,
+			owner_discord TEXT    NOT NULL,
+			tier          TEXT    NOT NULL DEFAULT 'standard',
+			scopes        TEXT    NOT NULL DEFAULT 'read',
+			request_count INTEGER NOT NULL DEFAULT 0,
+			created_at    DATETIME NOT NULL,
+			last_used_at  DATETIME,
+			revoked_at    DATETIME
+		);
+	`); err != nil {
+		panic(err)
+	}
+
+	if _, err := Database.Exec(`
+		CREATE TABLE IF NOT EXISTS api_key_usage (
+			key_id   INTEGER NOT NULL,
+			day      TEXT    NOT NULL,
+			requests INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (key_id, day),
+			FOREIGN KEY(key_id) REFERENCES api_keys(id)
+		);
+	`); err != nil {
+		panic(err)
+	}
+
+	addColumn("servers", "updated_at DATETIME")
+
+	if _, err := Database.Exec(`
+		CREATE TABLE IF NOT EXISTS server_feed (
+			id           INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+			server_id    INTEGER NOT NULL,
+			request_id   INTEGER,
+			published_at DATETIME NOT NULL,
+			FOREIGN KEY(server_id) REFERENCES servers(id) ON DELETE CASCADE
+		);
+	`); err != nil {
+		panic(err)
+	}
+
+	if _, err := Database.Exec(`
+		CREATE TABLE IF NOT EXISTS server_owners (
+			server_id  INTEGER NOT NULL,
+			discord_id TEXT    NOT NULL,
+			username   TEXT    NOT NULL,
+			role       TEXT    NOT NULL DEFAULT 'owner',
+			added_at   DATETIME NOT NULL,
+			PRIMARY KEY (server_id, discord_id),
+			FOREIGN KEY(server_id) REFERENCES servers(id) ON DELETE CASCADE
+		);
+	`); err != nil {
+		panic(err)
+	}
+
+	if _, err := Database.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_server_owners_discord_id ON server_owners (discord_id);
+	`); err != nil {
+		panic(err)
+	}
+
+	// Ownership used to live in users (one row per server). Copy it over the
+	// first time server_owners is created.
+	if _, err := Database.Exec(`
+		INSERT OR IGNORE INTO server_owners (server_id, discord_id, username, role, added_at)
+		SELECT server, discordid, username, 'owner', datetime('now')
+		FROM users
+		WHERE server IS NOT NULL
+		  AND NOT EXISTS (SELECT 1 FROM server_owners)
+	`); err != nil {
+		panic(err)
+	}
+
+	if _, err := Database.Exec(`
+		CREATE TABLE IF NOT EXISTS server_invites (
+			id           INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+			server_id    INTEGER NOT NULL,
+			discord_id   TEXT    NOT NULL,
+			role         TEXT    NOT NULL,
+			kind         TEXT    NOT NULL DEFAULT 'invite',
+			invited_by   TEXT    NOT NULL,
+			status       TEXT    NOT NULL DEFAULT 'pending',
+			created_at   DATETIME NOT NULL,
+			responded_at DATETIME,
+			FOREIGN KEY(server_id) REFERENCES servers(id) ON DELETE CASCADE
+		);
+	`); err != nil {
+		panic(err)
+	}
+
+	if _, err := Database.Exec(`
+		CREATE TABLE IF NOT EXISTS server_claims (
+			id         INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+			server_id  INTEGER NOT NULL,
+			discord_id TEXT    NOT NULL,
+			username   TEXT    NOT NULL,
+			evidence   TEXT    NOT NULL,
+			status     TEXT    NOT NULL DEFAULT 'pending',
+			created_at DATETIME NOT NULL,
+			decided_at DATETIME,
+			decided_by TEXT,
+			FOREIGN KEY(server_id) REFERENCES servers(id) ON DELETE CASCADE
+		);
+	`); err != nil {
+		panic(err)
+	}
+
+	// A claimant can prove they control the listing's domain before an admin
+	// reviews the claim; the outcome is kept on the claim itself.
+	addColumn("server_claims", "verify_domain TEXT")
+	addColumn("server_claims", "verify_token TEXT")
+	addColumn("server_claims", "verify_method TEXT")
+	addColumn("server_claims", "verified_at DATETIME")
+	addColumn("server_claims", "verify_checked_at DATETIME")
+	addColumn("server_claims", "verify_error TEXT")
+
+	if _, err := Database.Exec(`
+		CREATE TABLE IF NOT EXISTS domain_verifications (
+			server_id       INTEGER NOT NULL PRIMARY KEY,
+			domain          TEXT    NOT NULL,
+			token           TEXT    NOT NULL,
+			method          TEXT,
+			verified_at     DATETIME,
+			last_checked_at DATETIME,
+			last_error      TEXT,
+			failures        INTEGER NOT NULL DEFAULT 0,
+			created_at      DATETIME NOT NULL,
+			FOREIGN KEY(server_id) REFERENCES servers(id) ON DELETE CASCADE
+		);
+	`); err != nil {
+		panic(err)
+	}
+
+	addColumn("servers", "verified_domain TEXT")
+
+	if _, err := Database.Exec(`
+		CREATE TABLE IF NOT EXISTS server_request_revisions (
+			request_id  INTEGER NOT NULL,
+			revision    INTEGER NOT NULL,
+			server_name TEXT    NOT NULL,
+			url         TEXT,
+			description TEXT,
+			tags        TEXT,
+			logo_url    TEXT,
+			edited_by   TEXT    NOT NULL,
+			created_at  DATETIME NOT NULL,
+			PRIMARY KEY(request_id, revision),
+			FOREIGN KEY(request_id) REFERENCES server_requests(id)
+		);
+	`); err != nil {
+		panic(err)
+	}
+
+	// Requests filed before revisions existed get their current state as
+	// the first revision.
+	if _, err := Database.Exec(`
+		INSERT INTO server_request_revisions (
+			request_id, revision, server_name, url, description, tags, logo_url, edited_by, created_at
+		)
+		SELECT id, 1, server_name, url, description, tags, logo_url, owner_discord, created_at
+		FROM server_requests
+		WHERE status = 'pending'
+		  AND id NOT IN (SELECT request_id FROM server_request_revisions)
+	`); err != nil {
+		panic(err)
+	}
+
+	if _, err := Database.Exec(`
+		CREATE TABLE IF NOT EXISTS admin_request_views (
+			admin_discord_id TEXT    NOT NULL,
+			request_id       INTEGER NOT NULL,
+			revision         INTEGER NOT NULL,
+			viewed_at        DATETIME NOT NULL,
+			PRIMARY KEY(admin_discord_id, request_id),
+			FOREIGN KEY(request_id) REFERENCES server_requests(id)
+		);
+	`); err != nil {
+		panic(err)
+	}
+
+	// The M1PPosu logo used to be hardcoded in the pages; keep it as data.
+	if _, err := Database.Exec(`
+		UPDATE servers
+		SET logo_url = '/static/m1pplogo.png'
+		WHERE COALESCE(logo_url, '') = '' AND lower(server_name) = 'm1pposu'
+	`); err != nil {
+		panic(err)
+	}
+
+	addColumn("servers", "long_description TEXT")
+	addColumn("server_requests", "long_description TEXT")
+	addColumn("server_request_revisions", "long_description TEXT")
+
+	addColumn("servers", "features TEXT")
+	addColumn("server_requests", "features TEXT")
+	addColumn("server_request_revisions", "features TEXT")
+
+	addColumn("servers", "links TEXT")
+	addColumn("server_requests", "links TEXT")
+	addColumn("server_request_revisions", "links TEXT")
+
+	if _, err := Database.Exec(`
+		CREATE TABLE IF NOT EXISTS logo_cache (
+			source_url TEXT    NOT NULL PRIMARY KEY,
+			hosted_url TEXT,
+			fetched_at DATETIME NOT NULL,
+			last_error TEXT,
+			failures   INTEGER NOT NULL DEFAULT 0
+		);
+	`); err != nil {
+		panic(err)
+	}
+
+	if _, err := Database.Exec(`
+		CREATE TABLE IF NOT EXISTS server_screenshots (
+			id              INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+			server_id       INTEGER NOT NULL,
+			image_url       TEXT    NOT NULL,
+			thumb_url       TEXT    NOT NULL,
+			caption         TEXT,
+			pending_caption TEXT,
+			position        INTEGER NOT NULL DEFAULT 0,
+			status          TEXT    NOT NULL DEFAULT 'pending',
+			uploaded_by     TEXT    NOT NULL,
+			created_at      DATETIME NOT NULL,
+			decided_at      DATETIME,
+			decided_by      TEXT,
+			FOREIGN KEY(server_id) REFERENCES servers(id) ON DELETE CASCADE
+		);
+		CREATE INDEX IF NOT EXISTS idx_server_screenshots_server
+			ON server_screenshots(server_id, status, position);
+	`); err != nil {
+		panic(err)
+	}
+
+	if _, err := Database.Exec(`
+		CREATE TABLE IF NOT EXISTS discord_invites (
+			server_id      INTEGER NOT NULL PRIMARY KEY,
+			invite_code    TEXT    NOT NULL,
+			guild_id       TEXT,
+			guild_name     TEXT,
+			guild_icon     TEXT,
+			member_count   INTEGER,
+			presence_count INTEGER,
+			checked_at     DATETIME NOT NULL,
+			expired_at     DATETIME,
+			last_error     TEXT,
+			failures       INTEGER NOT NULL DEFAULT 0,
+			FOREIGN KEY(server_id) REFERENCES servers(id) ON DELETE CASCADE
+		);
+	`); err != nil {
+		panic(err)
+	}
+
+	if _, err := Database.Exec(`
+		CREATE TABLE IF NOT EXISTS server_announcements (
+			id          INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+			server_id   INTEGER NOT NULL,
+			title       TEXT    NOT NULL,
+			body        TEXT    NOT NULL,
+			starts_at   DATETIME,
+			ends_at     DATETIME,
+			author_id   TEXT    NOT NULL,
+			author_name TEXT    NOT NULL,
+			created_at  DATETIME NOT NULL,
+			hidden_at   DATETIME,
+			hidden_by   TEXT,
+			FOREIGN KEY(server_id) REFERENCES servers(id) ON DELETE CASCADE
+		);
+		CREATE INDEX IF NOT EXISTS idx_server_announcements_server
+			ON server_announcements(server_id, created_at);
+		CREATE INDEX IF NOT EXISTS idx_server_announcements_starts
+			ON server_announcements(starts_at);
+	`); err != nil {
+		panic(err)
+	}
+
+	if _, err := Database.Exec(`
+		CREATE TABLE IF NOT EXISTS server_reviews (
+			id         INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+			server_id  INTEGER NOT NULL,
+			discord_id TEXT    NOT NULL,
+			username   TEXT    NOT NULL,
+			rating     INTEGER NOT NULL CHECK (rating BETWEEN 1 AND 5),
+			body       TEXT,
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME,
+			reply      TEXT,
+			reply_by   TEXT,
+			replied_at DATETIME,
+			hidden_at  DATETIME,
+			hidden_by  TEXT,
+			UNIQUE(server_id, discord_id),
+			FOREIGN KEY(server_id) REFERENCES servers(id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE IF NOT EXISTS review_reports (
+			id          INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+			review_id   INTEGER NOT NULL,
+			reporter_id TEXT    NOT NULL,
+			reason      TEXT    NOT NULL DEFAULT '',
+			created_at  DATETIME NOT NULL,
+			resolved_at DATETIME,
+			resolved_by TEXT,
+			UNIQUE(review_id, reporter_id),
+			FOREIGN KEY(review_id) REFERENCES server_reviews(id) ON DELETE CASCADE
+		);
+	`); err != nil {
+		panic(err)
+	}
+
+	if _, err := Database.Exec(`
+		CREATE TABLE IF NOT EXISTS server_reports (
+			id            INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+			server_id     INTEGER NOT NULL,
+			reporter_id   TEXT    NOT NULL,
+			reporter_name TEXT    NOT NULL,
+			category      TEXT    NOT NULL,
+			details       TEXT,
+			status        TEXT    NOT NULL DEFAULT 'open',
+			admin_note    TEXT,
+			created_at    DATETIME NOT NULL,
+			updated_at    DATETIME,
+			updated_by    TEXT,
+			UNIQUE(server_id, reporter_id),
+			FOREIGN KEY(server_id) REFERENCES servers(id) ON DELETE CASCADE
+		);
+		CREATE INDEX IF NOT EXISTS idx_server_reports_status
+			ON server_reports(status, server_id);
+	`); err != nil {
+		panic(err)
+	}
+
+	// Set when the admins were pinged about a listing's open reports, and
+	// cleared once they drop below the threshold again.
+	addColumn("servers", "threshold_notified_at DATETIME")
+
+	if _, err := Database.Exec(`
+		CREATE TABLE IF NOT EXISTS admin_audit_log (
+			id           INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+			actor_id     TEXT    NOT NULL,
+			action       TEXT    NOT NULL,
+			target_type  TEXT    NOT NULL,
+			target_id    TEXT    NOT NULL,
+			before_state TEXT,
+			after_state  TEXT,
+			ip           TEXT    NOT NULL,
+			created_at   DATETIME NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_admin_audit_log_actor
+			ON admin_audit_log(actor_id, id);
+		CREATE INDEX IF NOT EXISTS idx_admin_audit_log_target
+			ON admin_audit_log(target_type, target_id, id);
+	`); err != nil {
+		panic(err)
+	}
+
+	// Older databases created these without an ON DELETE action, which left
+	// rows behind when a listing was removed.
+	cascadeServerDeletes("votes", "server", "CASCADE")
+	cascadeServerDeletes("users", "server", "SET NULL")
+	cascadeServerDeletes("server_feed", "server_id", "CASCADE")
+	cascadeServerDeletes("server_owners", "server_id", "CASCADE")
+	cascadeServerDeletes("server_invites", "server_id", "CASCADE")
+	cascadeServerDeletes("server_claims", "server_id", "CASCADE")
+	cascadeServerDeletes("domain_verifications", "server_id", "CASCADE")
+}
+
+var (
+	// createTableName captures the table name, quoted or not, in a stored
+	// CREATE TABLE statement.
+	createTableName = regexp.MustCompile(`(?is)^\s*CREATE\s+TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?("[^"]+"|` + "`[^`]+`" + `|\[[^\]]+\]|[^\s(]+)`)
+
+	// serversReference matches a foreign key on servers(id), capturing the
+	// ON DELETE that follows when it has an action.
+	serversReference = regexp.MustCompile(`(?i)REFERENCES\s+(?:"servers"|` + "`servers`" + `|\[servers\]|servers)\s*\(\s*(?:"id"|` + "`id`" + `|\[id\]|id)\s*\)(\s+ON\s+DELETE\b)?`)
+)
+
+// cascadeServerDeletes rebuilds a table created before its servers foreign
+// key had an ON DELETE action, so removing a listing also clears (or with
+// "SET NULL" detaches) its rows. SQLite can't alter a constraint in place:
+// the table is recreated from its stored schema, rows left behind by
+// earlier removals are dropped, and the rest are copied over. Tables that
+// already have the action are left alone.
+func cascadeServerDeletes(table, column, action string) {
+	var schema string
+	if err := Database.QueryRow(`
+		SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?
+	`, table).Scan(&schema); err != nil {
+		panic(err)
+	}
+	refs := serversReference.FindAllStringSubmatchIndex(schema, -1)
+	if len(refs) == 0 {
+		return
+	}
+	for _, ref := range refs {
+		if ref[2] >= 0 {
+			return
+		}
+	}
+	head := createTableName.FindStringSubmatchIndex(schema)
+	if head == nil || !strings.EqualFold(strings.Trim(schema[head[2]:head[3]], "\"`[]"), table) {
+		panic("cascadeServerDeletes: no CREATE TABLE " + table + " in its stored schema")
+	}
+
+	// The reference comes after the name, so inserting the action first
+	// leaves head's offsets valid.
+	rebuilt := table + "_rebuild"
+	at := refs[0][1]
+	schema = schema[:at] + " ON DELETE " + action + schema[at:]
+	schema = schema[:head[2]] + rebuilt + schema[head[3]:]
+
+	ctx := context.Background()
+	conn, err := Database.Conn(ctx)
+	if err != nil {
+		panic(err)
+	}
+	defer conn.Close()
+
+	// foreign_keys can't be switched inside a transaction, and has to be off
+	// while the old table is dropped.
+	if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF`); err != nil {
+		panic(err)
+	}
+	defer conn.ExecContext(ctx, `PRAGMA foreign_keys = ON`)
+
+	var indexes []string
+	rows, err := conn.QueryContext(ctx, `
+		SELECT sql FROM sqlite_master
+		WHERE type = 'index' AND tbl_name = ? AND sql IS NOT NULL
+	`, table)
+	if err != nil {
+		panic(err)
+	}
+	for rows.Next() {
+		var idx string
+		if err := rows.Scan(&idx); err != nil {
+			panic(err)
+		}
+		indexes = append(indexes, idx)
+	}
+	rows.Close()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		panic(err)
+	}
+	defer tx.Rollback()
+
+	orphans := `DELETE FROM ` + table + ` WHERE ` + column + ` NOT IN (SELECT id FROM servers)`
+	if action == "SET NULL" {
+		orphans = `UPDATE ` + table + ` SET ` + column + ` = NULL WHERE ` + column + ` NOT IN (SELECT id FROM servers)`
+	}
+
+	steps := []string{
+		schema,
+		orphans,
+		`INSERT INTO ` + rebuilt + ` SELECT * FROM ` + table,
+		`DROP TABLE ` + table,
+		`ALTER TABLE ` + rebuilt + ` RENAME TO ` + table,
+	}
+	for _, step := range append(steps, indexes...) {
+		if _, err := tx.Exec(step); err != nil {
+			panic(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		panic(err)
+	}
+}
+
+// addColumn adds a column to an existing table, ignoring the error SQLite
+// returns when the column is already there.
+func addColumn(table, columnDef string) {
+	if _, err := Database.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + columnDef); err != nil {
+		lower := strings.ToLower(err.Error())
+		if !strings.Contains(lower, "duplicate column name") {
+			panic(err)
+		}
+	}
 }
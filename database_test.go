@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCascadeServerDeletesMatchesStoredSchema(t *testing.T) {
+	newTestServer(t)
+
+	if _, err := Database.Exec(`
+		create   table "Legacy_Rows" (
+			id        INTEGER PRIMARY KEY,
+			server_id INTEGER NOT NULL REFERENCES "servers" ( id )
+		)
+	`); err != nil {
+		t.Fatal(err)
+	}
+	kept := insertTestServer(t, "Kept", "https://kept.example", 0)
+	removed := insertTestServer(t, "Removed", "https://removed.example", 0)
+	if _, err := Database.Exec(`INSERT INTO Legacy_Rows (server_id) VALUES (?), (?)`, kept, removed); err != nil {
+		t.Fatal(err)
+	}
+
+	cascadeServerDeletes("Legacy_Rows", "server_id", "CASCADE")
+
+	var schema string
+	if err := Database.QueryRow(`SELECT sql FROM sqlite_master WHERE name = 'Legacy_Rows'`).Scan(&schema); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(schema, "ON DELETE CASCADE") {
+		t.Fatalf("schema after the rebuild: %s", schema)
+	}
+
+	if _, err := Database.Exec(`DELETE FROM servers WHERE id = ?`, removed); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := Database.QueryRow(`SELECT COUNT(*) FROM Legacy_Rows`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("%d rows left, want only the kept server's", n)
+	}
+
+	// Running it again leaves the table alone.
+	cascadeServerDeletes("Legacy_Rows", "server_id", "CASCADE")
+}
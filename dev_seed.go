@@ -16,16 +16,18 @@ func main() {
 			url,
 			description,
 			tags,
+			features,
 			logo_url,
 			votes,
 			added
 		)
-		VALUES (?, ?, ?, ?, ?, ?, ?, datetime('now'))
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, datetime('now'))
 	`, "M1PPosu",
 		0,
 		"https://m1pposu.dev",
 		"a osu! Server where we rank the unrankable! From HUGE Map Packs to Farm Maps, everything is rankable here, giving everyone and everything a chance to excel the rankings! With Vanilla, Relax and Autopilot leaderboards, you can never get bored!",
 		"relax, autopilot, farm, diddy, 67",
+		`{"modes":["std"],"leaderboards":["vanilla","relax","autopilot"],"unranked_rankable":true}`,
 		"/static/m1pplogo.png",
 		0,
 	)
@@ -39,11 +41,11 @@ func main() {
 	}
 
 	_, err = Database.Exec(`
-		INSERT INTO users (username, discordid, server)
-		VALUES (?, ?, ?)
-	`, "M1PP Team", "123456789012345678", id)
+		INSERT INTO server_owners (server_id, discord_id, username, role, added_at)
+		VALUES (?, ?, ?, 'owner', datetime('now'))
+	`, id, "123456789012345678", "M1PP Team")
 	if err != nil {
-		log.Fatal("insert user:", err)
+		log.Fatal("insert owner:", err)
 	}
 
 	log.Printf("Seeded server M1PPosu with id %d\n", id)
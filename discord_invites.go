@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	discordInviteRefreshInterval = 6 * time.Hour
+	discordInviteTimeout         = 10 * time.Second
+	discordInviteBatch           = 50
+	// discordInvitePause spaces out lookups in a refresh so a large batch
+	// stays under Discord's rate limits.
+	discordInvitePause = time.Second
+)
+
+var (
+	errDiscordInviteExpired = errors.New("invite is invalid or expired")
+	errDiscordRateLimited   = errors.New("rate limited by discord")
+)
+
+// discordInvite is the part of Discord's invite object mossai records.
+type discordInvite struct {
+	Code  string `json:"code"`
+	Guild *struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+		Icon string `json:"icon"`
+	} `json:"guild"`
+	ApproximateMemberCount   int `json:"approximate_member_count"`
+	ApproximatePresenceCount int `json:"approximate_presence_count"`
+}
+
+func discordCDNBase() string {
+	base := strings.TrimSpace(os.Getenv("DISCORD_CDN_BASE"))
+	if base == "" {
+		base = "https://cdn.discordapp.com"
+	}
+	return strings.TrimRight(base, "/")
+}
+
+func discordGuildIconURL(guildID, icon string) string {
+	if guildID == "" || icon == "" {
+		return ""
+	}
+	return discordCDNBase() + "/icons/" + url.PathEscape(guildID) + "/" + url.PathEscape(icon) + ".png?size=128"
+}
+
+// discordInviteCode returns the invite code of the listing's Discord link,
+// or "" when it has none.
+func discordInviteCode(links []ServerLink) string {
+	for _, l := range links {
+		if l.Type != "discord" {
+			continue
+		}
+		u, err := url.Parse(l.URL)
+		if err != nil {
+			return ""
+		}
+		return path.Base(strings.TrimRight(u.Path, "/"))
+	}
+	return ""
+}
+
+// resolveDiscordInvite looks an invite up on the Discord API, which
+// DISCORD_API_BASE can point elsewhere.
+func resolveDiscordInvite(ctx context.Context, code string) (*discordInvite, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		discordAPIBase()+"/invites/"+url.PathEscape(code)+"?with_counts=true", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := discordHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, errDiscordInviteExpired
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return nil, errDiscordRateLimited
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return nil, fmt.Errorf("discord invite %s: status %d", code, resp.StatusCode)
+	}
+
+	var inv discordInvite
+	if err := json.NewDecoder(resp.Body).Decode(&inv); err != nil {
+		return nil, err
+	}
+	if inv.Guild == nil {
+		return nil, fmt.Errorf("invite %s is not for a server", code)
+	}
+	return &inv, nil
+}
+
+// refreshDiscordInvite resolves the listing's current Discord invite and
+// records the outcome. Failed lookups keep the last known guild details; an
+// invite Discord no longer knows is flagged to the team and admins once.
+func refreshDiscordInvite(ctx context.Context, serverID int) error {
+	var name, links string
+	if err := Database.QueryRow(`
+		SELECT server_name, COALESCE(links, '') FROM servers WHERE id = ?
+	`, serverID).Scan(&name, &links); err != nil {
+		return err
+	}
+
+	code := discordInviteCode(decodeLinks(links))
+	if code == "" {
+		_, err := Database.Exec(`DELETE FROM discord_invites WHERE server_id = ?`, serverID)
+		return err
+	}
+	// Details of a replaced invite say nothing about the new one.
+	if _, err := Database.Exec(`
+		DELETE FROM discord_invites WHERE server_id = ? AND invite_code <> ?
+	`, serverID, code); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, discordInviteTimeout)
+	defer cancel()
+
+	inv, lookupErr := resolveDiscordInvite(ctx, code)
+	if lookupErr == nil {
+		_, err := Database.Exec(`
+			INSERT INTO discord_invites (
+				server_id, invite_code, guild_id, guild_name, guild_icon,
+				member_count, presence_count, checked_at, failures
+			)
+			VALUES (?, ?, ?, ?, ?, ?, ?, datetime('now'), 0)
+			ON CONFLICT(server_id) DO UPDATE SET
+				guild_id       = excluded.guild_id,
+				guild_name     = excluded.guild_name,
+				guild_icon     = excluded.guild_icon,
+				member_count   = excluded.member_count,
+				presence_count = excluded.presence_count,
+				checked_at     = excluded.checked_at,
+				expired_at     = NULL,
+				last_error     = NULL,
+				failures       = 0
+		`, serverID, code, inv.Guild.ID, inv.Guild.Name, nullEmpty(inv.Guild.Icon),
+			inv.ApproximateMemberCount, inv.ApproximatePresenceCount)
+		return err
+	}
+
+	var wasExpired bool
+	err := Database.QueryRow(`
+		SELECT expired_at IS NOT NULL FROM discord_invites WHERE server_id = ?
+	`, serverID).Scan(&wasExpired)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	expired := errors.Is(lookupErr, errDiscordInviteExpired)
+	if _, err := Database.Exec(`
+		INSERT INTO discord_invites (server_id, invite_code, checked_at, expired_at, last_error, failures)
+		VALUES (?, ?, datetime('now'), CASE WHEN ? THEN datetime('now') END, ?, 1)
+		ON CONFLICT(server_id) DO UPDATE SET
+			checked_at = excluded.checked_at,
+			expired_at = CASE WHEN ? THEN COALESCE(expired_at, excluded.expired_at) END,
+			last_error = excluded.last_error,
+			failures   = failures + 1
+	`, serverID, code, expired, lookupErr.Error(), expired); err != nil {
+		return err
+	}
+
+	if expired && !wasExpired {
+		team, err := loadServerOwners(serverID)
+		if err != nil {
+			return err
+		}
+		owners := make([]string, 0, len(team))
+		for _, o := range team {
+			if o.Role == roleOwner {
+				owners = append(owners, o.DiscordID)
+			}
+		}
+		notifyDiscordInviteExpired(serverID, name, code, owners)
+	}
+	return lookupErr
+}
+
+// refreshDiscordInviteInBackground resolves a listing's invite after its
+// links changed without holding up the request that changed them.
+func refreshDiscordInviteInBackground(serverID int) {
+	go func() {
+		if err := refreshDiscordInvite(context.Background(), serverID); err != nil {
+			log.Printf("discord invite (server %d): %v", serverID, err)
+		}
+	}()
+}
+
+// refreshDiscordInvites re-resolves invites that were never looked up or
+// were last checked more than interval ago.
+func refreshDiscordInvites(ctx context.Context, interval time.Duration) {
+	rows, err := Database.Query(`
+		SELECT s.id
+		FROM servers s
+		LEFT JOIN discord_invites di
+		  ON di.server_id = s.id
+		WHERE (s.links LIKE '%"type":"discord"%' OR di.server_id IS NOT NULL)
+		  AND (di.checked_at IS NULL OR di.checked_at < datetime('now', ?))
+		ORDER BY di.checked_at
+		LIMIT ?
+	`, fmt.Sprintf("-%d seconds", int(interval.Seconds())), discordInviteBatch)
+	if err != nil {
+		log.Println("refreshDiscordInvites:", err)
+		return
+	}
+	ids := make([]int, 0, discordInviteBatch)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	for i, id := range ids {
+		if i > 0 {
+			time.Sleep(discordInvitePause)
+		}
+		err := refreshDiscordInvite(ctx, id)
+		if errors.Is(err, errDiscordRateLimited) {
+			log.Println("refreshDiscordInvites: rate limited, stopping batch")
+			return
+		}
+		if err != nil {
+			log.Printf("refresh discord invite (server %d): %v", id, err)
+		}
+	}
+}
+
+// startDiscordInviteRefresh keeps invite details fresh for the lifetime of
+// the process.
+func startDiscordInviteRefresh(interval time.Duration) {
+	go func() {
+		refreshDiscordInvites(context.Background(), interval)
+
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			refreshDiscordInvites(context.Background(), interval)
+		}
+	}()
+}
+
+// getAdminDiscordInvitesHandler lists listings whose Discord invite has
+// expired.
+func getAdminDiscordInvitesHandler(c fiber.Ctx) error {
+	if _, err := requireAdmin(c); err != nil {
+		return err
+	}
+
+	rows, err := Database.Query(`
+		SELECT s.id, s.server_name, di.invite_code, di.expired_at, COALESCE(di.last_error, '')
+		FROM discord_invites di
+		JOIN servers s ON s.id = di.server_id
+		WHERE di.expired_at IS NOT NULL
+		ORDER BY di.expired_at DESC
+	`)
+	if err != nil {
+		log.Println("getAdminDiscordInvitesHandler:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load invites")
+	}
+	defer rows.Close()
+
+	invites := make([]ExpiredDiscordInvite, 0, 16)
+	for rows.Next() {
+		var inv ExpiredDiscordInvite
+		if err := rows.Scan(&inv.ServerID, &inv.ServerName, &inv.InviteCode, &inv.ExpiredAt, &inv.LastError); err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("failed to read invites")
+		}
+		invites = append(invites, inv)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println("getAdminDiscordInvitesHandler:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to read invites")
+	}
+
+	return c.JSON(fiber.Map{"invites": invites})
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// discordStandIn plays the Discord API for invite lookups, answering each
+// code with its entry in invites (unknown codes get a 404), and counts the
+// admin webhooks and owner DMs mossai sends.
+type discordStandIn struct {
+	mu       sync.Mutex
+	invites  map[string]http.HandlerFunc
+	lookups  int
+	webhooks int
+	dms      int
+}
+
+func (d *discordStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/api/invites/"):
+		d.lookups++
+		answer, ok := d.invites[strings.TrimPrefix(r.URL.Path, "/api/invites/")]
+		if !ok {
+			http.Error(w, `{"message": "Unknown Invite", "code": 10006}`, http.StatusNotFound)
+			return
+		}
+		answer(w, r)
+	case r.URL.Path == "/webhook":
+		d.webhooks++
+		w.WriteHeader(http.StatusNoContent)
+	case r.URL.Path == "/api/users/@me/channels":
+		w.Write([]byte(`{"id": "900"}`))
+	case r.URL.Path == "/api/channels/900/messages":
+		d.dms++
+		w.Write([]byte(`{"id": "901"}`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (d *discordStandIn) setInvite(code string, answer http.HandlerFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.invites[code] = answer
+}
+
+func (d *discordStandIn) removeInvite(code string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.invites, code)
+}
+
+func (d *discordStandIn) counts() (lookups, webhooks, dms int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.lookups, d.webhooks, d.dms
+}
+
+// useDiscordStandIn points the Discord API, bot and admin webhook at a
+// stand-in for the rest of the test.
+func useDiscordStandIn(t *testing.T) *discordStandIn {
+	t.Helper()
+
+	d := &discordStandIn{invites: make(map[string]http.HandlerFunc)}
+	srv := httptest.NewServer(d)
+	t.Cleanup(srv.Close)
+
+	t.Setenv("DISCORD_API_BASE", srv.URL+"/api")
+	t.Setenv("DISCORD_ADMIN_WEBHOOK_URL", srv.URL+"/webhook")
+	t.Setenv("DISCORD_BOT_TOKEN", "test-bot-token")
+	return d
+}
+
+func validInvite(guildName string, members int) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{
+			"code": "x",
+			"guild": {"id": "700", "name": %q, "icon": "abc"},
+			"approximate_member_count": %d,
+			"approximate_presence_count": 3
+		}`, guildName, members)
+	}
+}
+
+func rateLimitedInvite(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Retry-After", "5")
+	http.Error(w, `{"message": "You are being rate limited."}`, http.StatusTooManyRequests)
+}
+
+// insertInviteServer adds a listing linking to discord.gg/code, owned by one
+// account so expiry DMs have a recipient.
+func insertInviteServer(t *testing.T, name, code string) int {
+	t.Helper()
+
+	id := insertTestServer(t, name, "https://"+strings.ToLower(name)+".example", 0)
+	setInviteLink(t, id, code)
+	if _, err := Database.Exec(`
+		INSERT INTO server_owners (server_id, discord_id, username, role, added_at)
+		VALUES (?, '100000000000000040', 'owner', 'owner', datetime('now'))
+	`, id); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func setInviteLink(t *testing.T, serverID int, code string) {
+	t.Helper()
+
+	links := encodeLinks([]ServerLink{{Type: "discord", URL: "https://discord.gg/" + code}})
+	if _, err := Database.Exec(`UPDATE servers SET links = ? WHERE id = ?`, links, serverID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type inviteRow struct {
+	code, guildName string
+	members         int
+	expired         bool
+	failures        int
+}
+
+func loadInviteRow(t *testing.T, serverID int) inviteRow {
+	t.Helper()
+
+	var r inviteRow
+	if err := Database.QueryRow(`
+		SELECT invite_code, COALESCE(guild_name, ''), COALESCE(member_count, 0),
+		       expired_at IS NOT NULL, failures
+		FROM discord_invites
+		WHERE server_id = ?
+	`, serverID).Scan(&r.code, &r.guildName, &r.members, &r.expired, &r.failures); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRefreshDiscordInvite(t *testing.T) {
+	newTestServer(t)
+	discord := useDiscordStandIn(t)
+	ctx := context.Background()
+
+	id := insertInviteServer(t, "Invited", "osu")
+	discord.setInvite("osu", validInvite("osu! community", 1200))
+
+	if err := refreshDiscordInvite(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if got := loadInviteRow(t, id); got != (inviteRow{code: "osu", guildName: "osu! community", members: 1200}) {
+		t.Fatalf("valid invite stored as %+v", got)
+	}
+
+	// A rate limited lookup keeps the last known details.
+	discord.setInvite("osu", rateLimitedInvite)
+	if err := refreshDiscordInvite(ctx, id); !errors.Is(err, errDiscordRateLimited) {
+		t.Fatalf("rate limited lookup error = %v", err)
+	}
+	got := loadInviteRow(t, id)
+	if got.guildName != "osu! community" || got.expired || got.failures != 1 {
+		t.Fatalf("after a 429 the invite is %+v", got)
+	}
+	if _, webhooks, dms := discord.counts(); webhooks != 0 || dms != 0 {
+		t.Fatalf("a 429 sent %d webhooks and %d DMs", webhooks, dms)
+	}
+
+	// Once Discord no longer knows the invite it is flagged, only once.
+	discord.removeInvite("osu")
+	for i := 0; i < 3; i++ {
+		if err := refreshDiscordInvite(ctx, id); !errors.Is(err, errDiscordInviteExpired) {
+			t.Fatalf("expired lookup error = %v", err)
+		}
+	}
+	if got := loadInviteRow(t, id); !got.expired || got.failures != 4 || got.guildName != "osu! community" {
+		t.Fatalf("expired invite stored as %+v", got)
+	}
+	if _, webhooks, dms := discord.counts(); webhooks != 1 || dms != 1 {
+		t.Fatalf("expiry sent %d webhooks and %d DMs, want one of each", webhooks, dms)
+	}
+
+	// The owners replace the link; the old invite's details go with it.
+	setInviteLink(t, id, "osu2")
+	discord.setInvite("osu2", validInvite("osu! community v2", 40))
+	if err := refreshDiscordInvite(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if got := loadInviteRow(t, id); got != (inviteRow{code: "osu2", guildName: "osu! community v2", members: 40}) {
+		t.Fatalf("replaced invite stored as %+v", got)
+	}
+
+	// A replacement that expires in turn is flagged again.
+	setInviteLink(t, id, "osu3")
+	if err := refreshDiscordInvite(ctx, id); !errors.Is(err, errDiscordInviteExpired) {
+		t.Fatalf("expired replacement error = %v", err)
+	}
+	if got := loadInviteRow(t, id); got.code != "osu3" || got.guildName != "" || !got.expired {
+		t.Fatalf("expired replacement stored as %+v", got)
+	}
+	if _, webhooks, dms := discord.counts(); webhooks != 2 || dms != 2 {
+		t.Fatalf("%d webhooks and %d DMs after the replacement expired, want 2 of each", webhooks, dms)
+	}
+
+	// Dropping the link drops the record.
+	if _, err := Database.Exec(`UPDATE servers SET links = NULL WHERE id = ?`, id); err != nil {
+		t.Fatal(err)
+	}
+	if err := refreshDiscordInvite(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := Database.QueryRow(`SELECT COUNT(*) FROM discord_invites WHERE server_id = ?`, id).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatal("invite record kept after the link was removed")
+	}
+}
+
+func TestRefreshDiscordInvitesStopsWhenRateLimited(t *testing.T) {
+	newTestServer(t)
+	discord := useDiscordStandIn(t)
+
+	for _, name := range []string{"First", "Second", "Third"} {
+		code := strings.ToLower(name)
+		insertInviteServer(t, name, code)
+		discord.setInvite(code, rateLimitedInvite)
+	}
+
+	refreshDiscordInvites(context.Background(), discordInviteRefreshInterval)
+
+	if lookups, _, _ := discord.counts(); lookups != 1 {
+		t.Fatalf("%d lookups, want the batch to stop after the first 429", lookups)
+	}
+}
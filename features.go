@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// The values each structured feature field accepts, in display order.
+var (
+	featureModes         = []string{"std", "taiko", "catch", "mania"}
+	featureLeaderboards  = []string{"vanilla", "relax", "autopilot", "scorev2"}
+	featurePPSystems     = []string{"stable", "lazer", "custom", "none"}
+	featureRegistrations = []string{"open", "invite", "closed"}
+	featureClients       = []string{"stable", "lazer", "mcosu", "custom"}
+)
+
+// featureOptions lists the choices for page templates.
+var featureOptions = struct {
+	Modes, Leaderboards, PPSystems, Registrations, Clients []string
+}{featureModes, featureLeaderboards, featurePPSystems, featureRegistrations, featureClients}
+
+// featureLabels are the names shown for feature values on listing pages.
+var featureLabels = map[string]string{
+	"std":       "osu!standard",
+	"taiko":     "osu!taiko",
+	"catch":     "osu!catch",
+	"mania":     "osu!mania",
+	"vanilla":   "Vanilla",
+	"relax":     "Relax",
+	"autopilot": "Autopilot",
+	"scorev2":   "ScoreV2",
+	"stable":    "osu!stable",
+	"lazer":     "osu!lazer",
+	"custom":    "Custom",
+	"none":      "None",
+	"mcosu":     "McOsu",
+	"open":      "Open",
+	"invite":    "Invite only",
+	"closed":    "Closed",
+}
+
+func featureLabel(v string) string {
+	if label, ok := featureLabels[v]; ok {
+		return label
+	}
+	return v
+}
+
+// normalizeFeatureList lower-cases, dedupes and orders values, rejecting any
+// not in allowed.
+func normalizeFeatureList(field string, values, allowed []string) ([]string, error) {
+	seen := make(map[string]bool, len(values))
+	for _, v := range values {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if v == "" {
+			continue
+		}
+		if !slices.Contains(allowed, v) {
+			return nil, fmt.Errorf("unknown %s %q", field, v)
+		}
+		seen[v] = true
+	}
+
+	out := make([]string, 0, len(seen))
+	for _, v := range allowed {
+		if seen[v] {
+			out = append(out, v)
+		}
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return out, nil
+}
+
+func normalizeFeatureValue(field, value string, allowed []string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value != "" && !slices.Contains(allowed, value) {
+		return "", fmt.Errorf("unknown %s %q", field, value)
+	}
+	return value, nil
+}
+
+// normalizeFeatures validates f and puts it in canonical form so equal
+// feature sets compare and store identically.
+func normalizeFeatures(f ServerFeatures) (ServerFeatures, error) {
+	var (
+		out ServerFeatures
+		err error
+	)
+	if out.Modes, err = normalizeFeatureList("mode", f.Modes, featureModes); err != nil {
+		return out, err
+	}
+	if out.Leaderboards, err = normalizeFeatureList("leaderboard", f.Leaderboards, featureLeaderboards); err != nil {
+		return out, err
+	}
+	if out.PPSystem, err = normalizeFeatureValue("pp system", f.PPSystem, featurePPSystems); err != nil {
+		return out, err
+	}
+	if out.Registration, err = normalizeFeatureValue("registration mode", f.Registration, featureRegistrations); err != nil {
+		return out, err
+	}
+	if out.Clients, err = normalizeFeatureList("client", f.Clients, featureClients); err != nil {
+		return out, err
+	}
+	out.UnrankedRankable = f.UnrankedRankable
+	return out, nil
+}
+
+// featuresFromForm reads features from the submission form, where list
+// fields are repeated checkboxes.
+func featuresFromForm(c fiber.Ctx) ServerFeatures {
+	multi := func(key string) []string {
+		if form, err := c.MultipartForm(); err == nil {
+			return form.Value[key]
+		}
+		raw := c.Request().PostArgs().PeekMulti(key)
+		values := make([]string, len(raw))
+		for i, v := range raw {
+			values[i] = string(v)
+		}
+		return values
+	}
+
+	f := ServerFeatures{
+		Modes:        multi("modes"),
+		Leaderboards: multi("leaderboards"),
+		PPSystem:     c.FormValue("pp_system"),
+		Registration: c.FormValue("registration"),
+		Clients:      multi("clients"),
+	}
+	if v, err := strconv.ParseBool(c.FormValue("unranked_rankable")); err == nil {
+		f.UnrankedRankable = &v
+	}
+	return f
+}
+
+// encodeFeatures returns the stored form of f, or "" when nothing is set.
+func encodeFeatures(f ServerFeatures) string {
+	if f.IsZero() {
+		return ""
+	}
+	b, err := json.Marshal(f)
+	if err != nil {
+		log.Println("encodeFeatures:", err)
+		return ""
+	}
+	return string(b)
+}
+
+func decodeFeatures(raw string) ServerFeatures {
+	var f ServerFeatures
+	if raw == "" {
+		return f
+	}
+	if err := json.Unmarshal([]byte(raw), &f); err != nil {
+		log.Println("decodeFeatures:", err)
+	}
+	return f
+}
+
+// featureChanges lists the feature fields that differ between two listings,
+// in the same shape as the other edit diffs.
+func featureChanges(old, next ServerFeatures) []FieldChange {
+	join := func(v []string) string { return strings.Join(v, ",") }
+	tri := func(b *bool) string {
+		if b == nil {
+			return ""
+		}
+		return strconv.FormatBool(*b)
+	}
+
+	fields := []FieldChange{
+		{Field: "modes", Old: join(old.Modes), New: join(next.Modes)},
+		{Field: "leaderboards", Old: join(old.Leaderboards), New: join(next.Leaderboards)},
+		{Field: "pp_system", Old: old.PPSystem, New: next.PPSystem},
+		{Field: "unranked_rankable", Old: tri(old.UnrankedRankable), New: tri(next.UnrankedRankable)},
+		{Field: "registration", Old: old.Registration, New: next.Registration},
+		{Field: "clients", Old: join(old.Clients), New: join(next.Clients)},
+	}
+
+	changes := make([]FieldChange, 0, len(fields))
+	for _, f := range fields {
+		if f.Old != f.New {
+			changes = append(changes, f)
+		}
+	}
+	return changes
+}
+
+// listingFeatures is the features column as JSON that is always valid, for
+// use with SQLite's JSON functions.
+const listingFeatures = `COALESCE(NULLIF(s.features, ''), '{}')`
+
+// featureFilterClauses builds leaderboard filters from the query string.
+// Each list filter matches servers supporting the given value.
+func featureFilterClauses(c fiber.Ctx) ([]string, []any, error) {
+	where := make([]string, 0, 6)
+	args := make([]any, 0, 6)
+
+	lists := []struct {
+		param, key string
+		allowed    []string
+	}{
+		{"mode", "modes", featureModes},
+		{"leaderboard", "leaderboards", featureLeaderboards},
+		{"client", "clients", featureClients},
+	}
+	for _, l := range lists {
+		v, err := normalizeFeatureValue(l.param, c.Query(l.param), l.allowed)
+		if err != nil {
+			return nil, nil, err
+		}
+		if v != "" {
+			where = append(where, `EXISTS (
+				SELECT 1 FROM json_each(`+listingFeatures+`, '$.`+l.key+`') WHERE value = ?
+			)`)
+			args = append(args, v)
+		}
+	}
+
+	values := []struct {
+		param, key string
+		allowed    []string
+	}{
+		{"pp_system", "pp_system", featurePPSystems},
+		{"registration", "registration", featureRegistrations},
+	}
+	for _, f := range values {
+		v, err := normalizeFeatureValue(f.param, c.Query(f.param), f.allowed)
+		if err != nil {
+			return nil, nil, err
+		}
+		if v != "" {
+			where = append(where, `json_extract(`+listingFeatures+`, '$.`+f.key+`') = ?`)
+			args = append(args, v)
+		}
+	}
+
+	if raw := strings.TrimSpace(c.Query("unranked_rankable")); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unranked_rankable must be true or false")
+		}
+		where = append(where, `json_extract(`+listingFeatures+`, '$.unranked_rankable') = ?`)
+		args = append(args, v)
+	}
+
+	return where, args, nil
+}
@@ -1,10 +1,13 @@
 module mossai
 
-go 1.25.0
+go 1.26.0
 
 require (
 	github.com/gofiber/fiber/v3 v3.0.0-rc.2
 	github.com/ncruces/go-sqlite3 v0.29.1
+	github.com/yuin/goldmark v1.7.13
+	golang.org/x/image v0.46.0
+	golang.org/x/net v0.46.0
 )
 
 require (
@@ -22,7 +25,6 @@ require (
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	github.com/valyala/fasthttp v1.67.0 // indirect
 	golang.org/x/crypto v0.43.0 // indirect
-	golang.org/x/net v0.46.0 // indirect
-	golang.org/x/sys v0.37.0 // indirect
-	golang.org/x/text v0.30.0 // indirect
+	golang.org/x/sys v0.48.0 // indirect
+	golang.org/x/text v0.42.0 // indirect
 )
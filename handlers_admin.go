@@ -2,29 +2,101 @@ package main
 
 import (
 	"database/sql"
+	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/gofiber/fiber/v3"
 )
 
+const maxDecisionReasonLength = 500
+
+const serverRequestSelect = `
+	SELECT
+		id,
+		server_name,
+		COALESCE(url, ''),
+		COALESCE(description, ''),
+		COALESCE(long_description, ''),
+		COALESCE(tags, ''),
+		COALESCE(features, ''),
+		COALESCE(links, ''),
+		owner_name,
+		owner_discord,
+		status,
+		created_at,
+		COALESCE(logo_url, ''),
+		kind,
+		COALESCE(server_id, 0),
+		COALESCE(decision_reason, ''),
+		COALESCE(decision_code, ''),
+		COALESCE((
+			SELECT MAX(v.revision)
+			FROM server_request_revisions v
+			WHERE v.request_id = server_requests.id
+		), 0)
+	FROM server_requests
+`
+
+func scanServerRequest(row rowScanner) (ServerRequest, error) {
+	var (
+		r        ServerRequest
+		features string
+		links    string
+	)
+	err := row.Scan(
+		&r.ID,
+		&r.ServerName,
+		&r.URL,
+		&r.Description,
+		&r.LongDescription,
+		&r.Tags,
+		&features,
+		&links,
+		&r.OwnerName,
+		&r.OwnerDiscord,
+		&r.Status,
+		&r.CreatedAt,
+		&r.LogoURL,
+		&r.Kind,
+		&r.ServerID,
+		&r.DecisionReason,
+		&r.DecisionCode,
+		&r.Revision,
+	)
+	r.Features = decodeFeatures(features)
+	r.Links = decodeLinks(links)
+	if r.DecisionCode != "" {
+		r.DecisionLabel = rejectionReasonLabel(r.DecisionCode)
+	}
+	return r, err
+}
+
+func queryServerRequests(where string, args ...any) ([]ServerRequest, error) {
+	rows, err := Database.Query(serverRequestSelect+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := make([]ServerRequest, 0, 16)
+	for rows.Next() {
+		r, err := scanServerRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, r)
+	}
+	return requests, rows.Err()
+}
+
 func getAdminRequestsHandler(c fiber.Ctx) error {
-	if _, err := requireAdmin(c); err != nil {
+	admin, err := requireAdmin(c)
+	if err != nil {
 		return err
 	}
 
-	rows, err := Database.Query(`
-		SELECT
-			id,
-			server_name,
-			COALESCE(url, ''),
-			COALESCE(description, ''),
-			COALESCE(tags, ''),
-			owner_name,
-			owner_discord,
-			status,
-			created_at,
-			COALESCE(logo_url, '')
-		FROM server_requests
+	rows, err := Database.Query(serverRequestSelect + `
 		WHERE status = 'pending'
 		ORDER BY created_at DESC
 	`)
@@ -36,19 +108,8 @@ func getAdminRequestsHandler(c fiber.Ctx) error {
 	requests := make([]ServerRequest, 0, 16)
 
 	for rows.Next() {
-		var r ServerRequest
-		if err := rows.Scan(
-			&r.ID,
-			&r.ServerName,
-			&r.URL,
-			&r.Description,
-			&r.Tags,
-			&r.OwnerName,
-			&r.OwnerDiscord,
-			&r.Status,
-			&r.CreatedAt,
-			&r.LogoURL,
-		); err != nil {
+		r, err := scanServerRequest(rows)
+		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).SendString("failed to scan request")
 		}
 		requests = append(requests, r)
@@ -58,27 +119,51 @@ func getAdminRequestsHandler(c fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).SendString("failed to read requests")
 	}
 
+	for i := range requests {
+		r := &requests[i]
+		if err := loadUnseenRequestChanges(admin.DiscordID, r); err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("failed to load revisions")
+		}
+
+		// Edit requests are shown as a diff against the live listing.
+		if r.Kind != requestKindEdit {
+			continue
+		}
+		current, err := scanServerResult(Database.QueryRow(serverResultSelect+`WHERE s.id = ?`, r.ServerID))
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("failed to load server")
+		}
+		r.Changes = serverEditChanges(current, r)
+	}
+
 	return c.JSON(requests)
 }
 
 func postAdminUpdateHandler(c fiber.Ctx) error {
-	if _, err := requireAdmin(c); err != nil {
+	admin, err := requireAdmin(c)
+	if err != nil {
 		return err
 	}
 
-	id := strings.TrimSpace(c.Params("id"))
-	if id == "" {
-		return c.Status(fiber.StatusBadRequest).SendString("missing id")
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
 	}
 
 	type updatePayload struct {
-		ServerName   string   `json:"server_name"`
-		URL          string   `json:"url"`
-		LogoURL      string   `json:"logo_url"`
-		Description  string   `json:"description"`
-		Tags         []string `json:"tags"`
-		OwnerName    string   `json:"owner_name"`
-		OwnerDiscord string   `json:"owner_discord"`
+		ServerName      string         `json:"server_name"`
+		URL             string         `json:"url"`
+		LogoURL         string         `json:"logo_url"`
+		Description     string         `json:"description"`
+		LongDescription string         `json:"long_description"`
+		Tags            []string       `json:"tags"`
+		Features        ServerFeatures `json:"features"`
+		Links           []ServerLink   `json:"links"`
+		OwnerName       string         `json:"owner_name"`
+		OwnerDiscord    string         `json:"owner_discord"`
 	}
 
 	var payload updatePayload
@@ -90,12 +175,26 @@ func postAdminUpdateHandler(c fiber.Ctx) error {
 	payload.URL = strings.TrimSpace(payload.URL)
 	payload.LogoURL = strings.TrimSpace(payload.LogoURL)
 	payload.Description = strings.TrimSpace(payload.Description)
+	payload.LongDescription = strings.TrimSpace(payload.LongDescription)
 	payload.OwnerName = strings.TrimSpace(payload.OwnerName)
 	payload.OwnerDiscord = strings.TrimSpace(payload.OwnerDiscord)
 
 	if payload.ServerName == "" || payload.OwnerName == "" || payload.OwnerDiscord == "" {
 		return c.Status(fiber.StatusBadRequest).SendString("server_name, owner_name and owner_discord are required")
 	}
+	if len(payload.LongDescription) > MaxLongDescriptionLength {
+		return c.Status(fiber.StatusBadRequest).SendString(
+			fmt.Sprintf("Long description must be at most %d characters.", MaxLongDescriptionLength),
+		)
+	}
+	features, err := normalizeFeatures(payload.Features)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	links, err := normalizeLinks(payload.Links)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
 
 	var tagsJoined string
 	if len(payload.Tags) > 0 {
@@ -109,6 +208,8 @@ func postAdminUpdateHandler(c fiber.Ctx) error {
 		tagsJoined = strings.Join(clean, ",")
 	}
 
+	before := auditRow(queryServerRequests, `WHERE id = ?`, id)
+
 	res, err := Database.Exec(`
 		UPDATE server_requests
 		SET
@@ -116,7 +217,10 @@ func postAdminUpdateHandler(c fiber.Ctx) error {
 			url           = ?,
 			logo_url      = ?,
 			description   = ?,
+			long_description = ?,
 			tags          = ?,
+			features      = ?,
+			links         = ?,
 			owner_name    = ?,
 			owner_discord = ?
 		WHERE id = ? AND status = 'pending'
@@ -125,7 +229,10 @@ func postAdminUpdateHandler(c fiber.Ctx) error {
 		nullEmpty(payload.URL),
 		nullEmpty(payload.LogoURL),
 		nullEmpty(payload.Description),
+		nullEmpty(payload.LongDescription),
 		nullEmpty(tagsJoined),
+		encodeFeatures(features),
+		encodeLinks(links),
 		payload.OwnerName,
 		payload.OwnerDiscord,
 		id,
@@ -142,6 +249,9 @@ func postAdminUpdateHandler(c fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).SendString("request not found or not pending")
 	}
 
+	recordRequestRevision(id, admin.DiscordID)
+	recordAdminAction(c, "request.update", "request", id, before, auditRow(queryServerRequests, `WHERE id = ?`, id))
+
 	return c.JSON(fiber.Map{"ok": true})
 }
 
@@ -155,33 +265,9 @@ func postAdminApproveHandler(c fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).SendString("missing id")
 	}
 
-	var r ServerRequest
-	err := Database.QueryRow(`
-		SELECT
-			id,
-			server_name,
-			COALESCE(url, ''),
-			COALESCE(description, ''),
-			COALESCE(tags, ''),
-			owner_name,
-			owner_discord,
-			status,
-			created_at,
-			COALESCE(logo_url, '')
-		FROM server_requests
+	r, err := scanServerRequest(Database.QueryRow(serverRequestSelect+`
 		WHERE id = ? AND status = 'pending'
-	`, id).Scan(
-		&r.ID,
-		&r.ServerName,
-		&r.URL,
-		&r.Description,
-		&r.Tags,
-		&r.OwnerName,
-		&r.OwnerDiscord,
-		&r.Status,
-		&r.CreatedAt,
-		&r.LogoURL,
-	)
+	`, id))
 	if err == sql.ErrNoRows {
 		return c.Status(fiber.StatusNotFound).SendString("request not found or already processed")
 	}
@@ -189,6 +275,10 @@ func postAdminApproveHandler(c fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).SendString("failed to load request")
 	}
 
+	if r.Kind == requestKindEdit {
+		return approveServerEdit(c, &r)
+	}
+
 	tx, err := Database.Begin()
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).SendString("failed to begin transaction")
@@ -201,19 +291,26 @@ func postAdminApproveHandler(c fiber.Ctx) error {
 			type,
 			url,
 			description,
+			long_description,
 			tags,
+			features,
+			links,
 			logo_url,
 			status,
 			votes,
-			added
+			added,
+			updated_at
 		)
-		VALUES (?, ?, ?, ?, ?, ?, 'unknown', 0, datetime('now'))
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 'unknown', 0, datetime('now'), datetime('now'))
 	`,
 		r.ServerName,
 		0,
 		nullEmpty(r.URL),
 		nullEmpty(r.Description),
+		nullEmpty(r.LongDescription),
 		nullEmpty(r.Tags),
+		encodeFeatures(r.Features),
+		encodeLinks(r.Links),
 		nullEmpty(r.LogoURL),
 	)
 	if err != nil {
@@ -226,17 +323,28 @@ func postAdminApproveHandler(c fiber.Ctx) error {
 	}
 
 	_, err = tx.Exec(`
-		INSERT INTO users (username, discordid, server)
-		VALUES (?, ?, ?)
+		INSERT INTO server_owners (server_id, discord_id, username, role, added_at)
+		VALUES (?, ?, ?, 'owner', datetime('now'))
 	`,
-		r.OwnerName,
-		r.OwnerDiscord,
 		serverID,
+		r.OwnerDiscord,
+		r.OwnerName,
 	)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).SendString("failed to create owner user")
 	}
 
+	_, err = tx.Exec(`
+		INSERT INTO server_feed (server_id, request_id, published_at)
+		VALUES (?, ?, datetime('now'))
+	`,
+		serverID,
+		r.ID,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to create feed entry")
+	}
+
 	_, err = tx.Exec(`
 		UPDATE server_requests
 		SET status = 'approved'
@@ -250,6 +358,10 @@ func postAdminApproveHandler(c fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).SendString("failed to commit transaction")
 	}
 
+	recordAdminAction(c, "request.approve", "request", r.ID, r, fiber.Map{"status": "approved", "server_id": serverID})
+
+	cacheLogoInBackground(r.LogoURL)
+	refreshDiscordInviteInBackground(int(serverID))
 	notifyServerRequestApproved(&r, serverID)
 
 	return c.JSON(fiber.Map{
@@ -258,6 +370,98 @@ func postAdminApproveHandler(c fiber.Ctx) error {
 	})
 }
 
+// approveServerEdit applies an edit request to its live listing. The listing
+// update and the request status change commit together, so an edit is never
+// applied twice or left half-applied.
+func approveServerEdit(c fiber.Ctx, r *ServerRequest) error {
+	before := auditRow(queryServers, `WHERE s.id = ?`, r.ServerID)
+
+	tx, err := Database.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE servers
+		SET
+			server_name = ?,
+			url         = ?,
+			description = ?,
+			long_description = ?,
+			tags        = ?,
+			features    = ?,
+			links       = ?,
+			logo_url    = ?,
+			updated_at  = datetime('now'),
+			verified_domain = CASE WHEN verified_domain = ? THEN verified_domain END
+		WHERE id = ?
+	`,
+		r.ServerName,
+		nullEmpty(r.URL),
+		nullEmpty(r.Description),
+		nullEmpty(r.LongDescription),
+		nullEmpty(r.Tags),
+		encodeFeatures(r.Features),
+		encodeLinks(r.Links),
+		nullEmpty(r.LogoURL),
+		listingDomain(r.URL),
+		r.ServerID,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to update server")
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to read update result")
+	}
+	if affected == 0 {
+		return c.Status(fiber.StatusNotFound).SendString("server no longer exists")
+	}
+
+	// A token for the old domain must not badge the listing again on its
+	// next recheck; the owners verify the new domain from scratch.
+	if _, err := tx.Exec(`
+		DELETE FROM domain_verifications WHERE server_id = ? AND domain != ?
+	`, r.ServerID, listingDomain(r.URL)); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to reset domain verification")
+	}
+
+	res, err = tx.Exec(`
+		UPDATE server_requests
+		SET status = 'approved'
+		WHERE id = ? AND status = 'pending'
+	`, r.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to update request status")
+	}
+
+	affected, err = res.RowsAffected()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to read update result")
+	}
+	if affected == 0 {
+		return c.Status(fiber.StatusNotFound).SendString("request not found or already processed")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to commit transaction")
+	}
+
+	// Edits are logged as the listing before and after they applied.
+	recordAdminAction(c, "request.approve", "request", r.ID, before, auditRow(queryServers, `WHERE s.id = ?`, r.ServerID))
+
+	cacheLogoInBackground(r.LogoURL)
+	refreshDiscordInviteInBackground(r.ServerID)
+	notifyServerEditApproved(r)
+
+	return c.JSON(fiber.Map{
+		"ok":        true,
+		"server_id": r.ServerID,
+	})
+}
+
 func postAdminRejectHandler(c fiber.Ctx) error {
 	if _, err := requireAdmin(c); err != nil {
 		return err
@@ -268,33 +472,9 @@ func postAdminRejectHandler(c fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).SendString("missing id")
 	}
 
-	var r ServerRequest
-	err := Database.QueryRow(`
-		SELECT
-			id,
-			server_name,
-			COALESCE(url, ''),
-			COALESCE(description, ''),
-			COALESCE(tags, ''),
-			owner_name,
-			owner_discord,
-			status,
-			created_at,
-			COALESCE(logo_url, '')
-		FROM server_requests
+	r, err := scanServerRequest(Database.QueryRow(serverRequestSelect+`
 		WHERE id = ? AND status = 'pending'
-	`, id).Scan(
-		&r.ID,
-		&r.ServerName,
-		&r.URL,
-		&r.Description,
-		&r.Tags,
-		&r.OwnerName,
-		&r.OwnerDiscord,
-		&r.Status,
-		&r.CreatedAt,
-		&r.LogoURL,
-	)
+	`, id))
 	if err == sql.ErrNoRows {
 		return c.Status(fiber.StatusNotFound).SendString("request not found or already processed")
 	}
@@ -302,11 +482,42 @@ func postAdminRejectHandler(c fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).SendString("failed to load request")
 	}
 
+	// Code is one of rejectionReasons; Reason is free text for the
+	// submitter, required when no code is given or the code is "other".
+	type rejectPayload struct {
+		Code   string `json:"code"`
+		Reason string `json:"reason"`
+	}
+
+	var payload rejectPayload
+	if len(c.Body()) > 0 {
+		if err := c.Bind().Body(&payload); err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString("invalid json body")
+		}
+	}
+	before := r
+	r.DecisionCode = strings.TrimSpace(payload.Code)
+	r.DecisionReason = strings.TrimSpace(payload.Reason)
+	if r.DecisionCode != "" && !validRejectionCode(r.DecisionCode) {
+		return c.Status(fiber.StatusBadRequest).SendString("unknown rejection reason")
+	}
+	if r.DecisionReason == "" && (r.DecisionCode == "" || r.DecisionCode == "other") {
+		return c.Status(fiber.StatusBadRequest).SendString("Pick a reason or write one for the submitter.")
+	}
+	if len([]rune(r.DecisionReason)) > maxDecisionReasonLength {
+		return c.Status(fiber.StatusBadRequest).SendString(
+			fmt.Sprintf("Reason must be at most %d characters.", maxDecisionReasonLength),
+		)
+	}
+	if r.DecisionCode != "" {
+		r.DecisionLabel = rejectionReasonLabel(r.DecisionCode)
+	}
+
 	res, err := Database.Exec(`
 		UPDATE server_requests
-		SET status = 'rejected'
+		SET status = 'rejected', decision_code = ?, decision_reason = ?
 		WHERE id = ? AND status = 'pending'
-	`, id)
+	`, nullEmpty(r.DecisionCode), nullEmpty(r.DecisionReason), id)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).SendString("failed to update request status")
 	}
@@ -319,6 +530,9 @@ func postAdminRejectHandler(c fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).SendString("request not found or already processed")
 	}
 
+	r.Status = "rejected"
+	recordAdminAction(c, "request.reject", "request", r.ID, before, r)
+
 	notifyServerRequestRejected(&r)
 
 	return c.JSON(fiber.Map{"ok": true})
@@ -334,6 +548,8 @@ func postAdminRemoveServerHandler(c fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).SendString("missing id")
 	}
 
+	before := auditRow(queryServers, `WHERE s.id = ?`, id)
+
 	res, err := Database.Exec(`
 		DELETE FROM servers
 		WHERE id = ?
@@ -350,9 +566,51 @@ func postAdminRemoveServerHandler(c fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).SendString("server not found")
 	}
 
+	recordAdminAction(c, "server.remove", "server", id, before, nil)
+
 	return c.JSON(fiber.Map{"ok": true})
 }
 
+// postAdminAdjustVotesHandler sets a listing's vote total, e.g. after
+// clearing out a vote-farming campaign.
+func postAdminAdjustVotesHandler(c fiber.Ctx) error {
+	if _, err := requireAdmin(c); err != nil {
+		return err
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+
+	var body struct {
+		Votes *int `json:"votes"`
+	}
+	if err := c.Bind().Body(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid request body")
+	}
+	if body.Votes == nil || *body.Votes < 0 {
+		return c.Status(fiber.StatusBadRequest).SendString("votes must be zero or more")
+	}
+
+	var votes int
+	err = Database.QueryRow(`SELECT votes FROM servers WHERE id = ?`, id).Scan(&votes)
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).SendString("server not found")
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load server")
+	}
+
+	if _, err := Database.Exec(`UPDATE servers SET votes = ? WHERE id = ?`, *body.Votes, id); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to update votes")
+	}
+
+	recordAdminAction(c, "server.votes", "server", id, fiber.Map{"votes": votes}, fiber.Map{"votes": *body.Votes})
+
+	return c.JSON(fiber.Map{"ok": true, "votes": *body.Votes})
+}
+
 func nullEmpty(s string) string {
 	return strings.TrimSpace(s)
 }
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	requestKindNew  = "new"
+	requestKindEdit = "edit"
+)
+
+// serverEditChanges compares an edit request with the live listing and
+// returns the fields that differ.
+func serverEditChanges(current ServerResult, r *ServerRequest) []FieldChange {
+	fields := []FieldChange{
+		{Field: "server_name", Old: current.ServerName, New: r.ServerName},
+		{Field: "url", Old: current.URL, New: r.URL},
+		{Field: "description", Old: current.Description, New: r.Description},
+		{Field: "long_description", Old: current.LongDescription, New: r.LongDescription},
+		{Field: "tags", Old: current.Tags, New: r.Tags},
+		{Field: "logo_url", Old: current.LogoURL, New: r.LogoURL},
+	}
+
+	changes := make([]FieldChange, 0, len(fields))
+	for _, f := range fields {
+		oldValue, newValue := strings.TrimSpace(f.Old), strings.TrimSpace(f.New)
+		if f.Field == "tags" {
+			oldValue = strings.Join(splitCSV(oldValue), ",")
+			newValue = strings.Join(splitCSV(newValue), ",")
+		}
+		if oldValue != newValue {
+			changes = append(changes, f)
+		}
+	}
+	if oldLinks, newLinks := formatLinks(current.Links), formatLinks(r.Links); oldLinks != newLinks {
+		changes = append(changes, FieldChange{Field: "links", Old: oldLinks, New: newLinks})
+	}
+	return append(changes, featureChanges(current.Features, r.Features)...)
+}
+
+func validListingURL(raw string) bool {
+	if raw == "" {
+		return true
+	}
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// pendingServerEdit returns the open edit request for a server, if any.
+func pendingServerEdit(serverID int) (*ServerRequest, error) {
+	r, err := scanServerRequest(Database.QueryRow(serverRequestSelect+`
+		WHERE kind = 'edit' AND server_id = ? AND status = 'pending'
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, serverID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func ownerPageHandler(c fiber.Ctx) error {
+	meta := defaultPageMeta("Your servers | mossai", "/owner")
+	meta.NoIndex = true
+
+	return renderPage(c, fiber.StatusOK, "owner.html", fiber.Map{
+		"Meta": meta,
+	})
+}
+
+func getOwnerServersHandler(c fiber.Ctx) error {
+	u, ok := getSessionUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).SendString("unauthorized")
+	}
+
+	servers, err := queryServers(`
+		WHERE s.id IN (SELECT server_id FROM server_owners WHERE discord_id = ?)
+		ORDER BY s.votes DESC, s.added DESC
+	`, u.DiscordID)
+	if err != nil {
+		log.Println("getOwnerServersHandler:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load servers")
+	}
+
+	out := make([]OwnerServer, 0, len(servers))
+	for _, s := range servers {
+		entry := OwnerServer{ServerResult: s}
+
+		if entry.Rank, err = serverRank(s); err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("failed to load rank")
+		}
+
+		err = Database.QueryRow(`
+			SELECT
+				COUNT(CASE WHEN last_vote > datetime('now', '-1 day') THEN 1 END),
+				COUNT(*)
+			FROM votes
+			WHERE server = ? AND last_vote > datetime('now', '-7 days')
+		`, s.ID).Scan(&entry.VotesToday, &entry.VotesLast7Days)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("failed to load vote stats")
+		}
+
+		if entry.PendingEdit, err = pendingServerEdit(s.ID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("failed to load pending edit")
+		}
+
+		entry.Screenshots, err = queryScreenshots(`
+			WHERE sc.server_id = ?
+			ORDER BY sc.position, sc.id
+		`, s.ID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("failed to load screenshots")
+		}
+
+		entry.Announcements, err = queryAnnouncements(`
+			WHERE a.server_id = ?
+			ORDER BY a.created_at DESC, a.id DESC
+			LIMIT ?
+		`, s.ID, announcementsOnPage)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("failed to load announcements")
+		}
+
+		if entry.Reviews, err = publicReviews(s.ID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("failed to load reviews")
+		}
+
+		if entry.Owners, err = loadServerOwners(s.ID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("failed to load owners")
+		}
+		for _, o := range entry.Owners {
+			if o.DiscordID == u.DiscordID {
+				entry.Role = o.Role
+			}
+		}
+
+		if entry.Role == roleOwner {
+			entry.Invites, err = queryOwnerInvites(`
+				WHERE i.server_id = ? AND i.status = 'pending'
+				ORDER BY i.created_at DESC
+			`, s.ID)
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).SendString("failed to load invites")
+			}
+		}
+
+		out = append(out, entry)
+	}
+
+	return c.JSON(fiber.Map{"servers": out})
+}
+
+// getOwnerRequestsHandler lists the listing requests and edits the signed-in
+// user submitted, newest first, so they can follow their review.
+func getOwnerRequestsHandler(c fiber.Ctx) error {
+	u, ok := getSessionUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).SendString("unauthorized")
+	}
+
+	rows, err := Database.Query(serverRequestSelect+`
+		WHERE owner_discord = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT 50
+	`, u.DiscordID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load requests")
+	}
+	defer rows.Close()
+
+	requests := make([]ServerRequest, 0, 8)
+	for rows.Next() {
+		r, err := scanServerRequest(rows)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("failed to read request")
+		}
+		requests = append(requests, r)
+	}
+	if err := rows.Err(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load requests")
+	}
+
+	return c.JSON(fiber.Map{"requests": requests})
+}
+
+func postOwnerServerEditHandler(c fiber.Ctx) error {
+	u, id, err := requireServerRole(c, roleOwner, roleManager)
+	if err != nil {
+		return err
+	}
+
+	type editPayload struct {
+		ServerName      string         `json:"server_name"`
+		URL             string         `json:"url"`
+		LogoURL         string         `json:"logo_url"`
+		Description     string         `json:"description"`
+		LongDescription string         `json:"long_description"`
+		Tags            []string       `json:"tags"`
+		Features        ServerFeatures `json:"features"`
+		Links           []ServerLink   `json:"links"`
+	}
+
+	var payload editPayload
+	if err := c.Bind().Body(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid json body")
+	}
+
+	clean := make([]string, 0, len(payload.Tags))
+	for _, t := range payload.Tags {
+		t = strings.TrimSpace(t)
+		if t != "" {
+			clean = append(clean, t)
+		}
+	}
+
+	r := ServerRequest{
+		ServerName:      strings.TrimSpace(payload.ServerName),
+		URL:             strings.TrimSpace(payload.URL),
+		LogoURL:         strings.TrimSpace(payload.LogoURL),
+		Description:     strings.TrimSpace(payload.Description),
+		LongDescription: strings.TrimSpace(payload.LongDescription),
+		Tags:            strings.Join(clean, ","),
+		OwnerName:       u.Username,
+		OwnerDiscord:    u.DiscordID,
+		Status:          "pending",
+		Kind:            requestKindEdit,
+		ServerID:        id,
+	}
+
+	if r.ServerName == "" {
+		return c.Status(fiber.StatusBadRequest).SendString("server_name is required")
+	}
+	if len(r.Description) > MaxDescriptionLength {
+		return c.Status(fiber.StatusBadRequest).SendString(
+			fmt.Sprintf("Description must be at most %d characters.", MaxDescriptionLength),
+		)
+	}
+	if len(r.LongDescription) > MaxLongDescriptionLength {
+		return c.Status(fiber.StatusBadRequest).SendString(
+			fmt.Sprintf("Long description must be at most %d characters.", MaxLongDescriptionLength),
+		)
+	}
+	if r.Features, err = normalizeFeatures(payload.Features); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	if r.Links, err = normalizeLinks(payload.Links); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	current, err := scanServerResult(Database.QueryRow(serverResultSelect+`WHERE s.id = ?`, id))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load server")
+	}
+
+	// Only new links are checked, so listings keeping a /static/ logo can
+	// still be edited.
+	if (r.URL != current.URL && !validListingURL(r.URL)) ||
+		(r.LogoURL != current.LogoURL && !validLogoURL(r.LogoURL)) {
+		return c.Status(fiber.StatusBadRequest).SendString("url and logo_url must be http(s) links")
+	}
+
+	changes := serverEditChanges(current, &r)
+	if len(changes) == 0 {
+		return c.Status(fiber.StatusBadRequest).SendString("nothing to change")
+	}
+
+	// A server has at most one open edit; a new proposal replaces it.
+	existing, err := pendingServerEdit(id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load pending edit")
+	}
+
+	if existing != nil {
+		r.ID = existing.ID
+		_, err = Database.Exec(`
+			UPDATE server_requests
+			SET
+				server_name   = ?,
+				url           = ?,
+				logo_url      = ?,
+				description   = ?,
+				long_description = ?,
+				tags          = ?,
+				features      = ?,
+				links         = ?,
+				owner_name    = ?,
+				owner_discord = ?,
+				created_at    = datetime('now')
+			WHERE id = ? AND status = 'pending'
+		`,
+			r.ServerName,
+			nullEmpty(r.URL),
+			nullEmpty(r.LogoURL),
+			nullEmpty(r.Description),
+			nullEmpty(r.LongDescription),
+			nullEmpty(r.Tags),
+			encodeFeatures(r.Features),
+			encodeLinks(r.Links),
+			r.OwnerName,
+			r.OwnerDiscord,
+			r.ID,
+		)
+	} else {
+		var res sql.Result
+		res, err = Database.Exec(`
+			INSERT INTO server_requests (
+				server_name,
+				url,
+				description,
+				long_description,
+				tags,
+				features,
+				links,
+				owner_name,
+				owner_discord,
+				logo_url,
+				status,
+				created_at,
+				kind,
+				server_id
+			)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 'pending', datetime('now'), ?, ?)
+		`,
+			r.ServerName,
+			nullEmpty(r.URL),
+			nullEmpty(r.Description),
+			nullEmpty(r.LongDescription),
+			nullEmpty(r.Tags),
+			encodeFeatures(r.Features),
+			encodeLinks(r.Links),
+			r.OwnerName,
+			r.OwnerDiscord,
+			nullEmpty(r.LogoURL),
+			requestKindEdit,
+			id,
+		)
+		if err == nil {
+			var newID int64
+			newID, err = res.LastInsertId()
+			r.ID = int(newID)
+		}
+	}
+	if err != nil {
+		log.Println("save server edit request:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to save edit request")
+	}
+
+	recordRequestRevision(r.ID, u.DiscordID)
+
+	notifyServerEditRequest(&r, current, changes)
+
+	return c.JSON(fiber.Map{
+		"ok":         true,
+		"request_id": r.ID,
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/gofiber/fiber/v3"
@@ -54,56 +55,175 @@ func verifyTurnstile(token, remoteIP string) bool {
 	return parsed.Success
 }
 
-func getLeaderboardHandler(c fiber.Ctx) error {
-	rows, err := Database.Query(`
-		SELECT s.id,
-		       s.server_name,
-		       COALESCE(s.url, ''),
-		       COALESCE(s.description, ''),
-		       COALESCE(s.tags, ''),
-		       COALESCE(s.logo_url, ''),
-		       COALESCE(s.status, 'unknown'),
-		       COALESCE(s.online, 0),
-		       COALESCE(s.registered, 0),
-		       s.votes,
-		       s.added,
-		       u.username
-		FROM servers s
-		JOIN users u
-		  ON u.server = s.id
-		ORDER BY s.votes DESC, s.added DESC
-	`)
+const serverResultSelect = `
+	SELECT s.id,
+	       s.server_name,
+	       COALESCE(s.url, ''),
+	       COALESCE(s.description, ''),
+	       COALESCE(s.long_description, ''),
+	       COALESCE(s.tags, ''),
+	       COALESCE(s.features, ''),
+	       COALESCE(s.links, ''),
+	       COALESCE(s.logo_url, ''),
+	       CASE
+	           WHEN s.logo_url LIKE '/logos/%' OR s.logo_url LIKE '/static/%' THEN s.logo_url
+	           ELSE COALESCE((
+	               SELECT lc.hosted_url
+	               FROM logo_cache lc
+	               WHERE lc.source_url = s.logo_url
+	           ), '')
+	       END,
+	       COALESCE(s.status, 'unknown'),
+	       COALESCE(s.online, 0),
+	       COALESCE(s.registered, 0),
+	       s.votes,
+	       COALESCE(rv.rating, 0),
+	       COALESCE(rv.rating_count, 0),
+	       s.added,
+	       COALESCE((
+	           SELECT group_concat(username, ', ')
+	           FROM (
+	               SELECT o.username
+	               FROM server_owners o
+	               WHERE o.server_id = s.id AND o.role = 'owner'
+	               ORDER BY o.added_at, o.discord_id
+	           )
+	       ), ''),
+	       COALESCE(s.verified_domain, ''),
+	       di.server_id IS NOT NULL,
+	       COALESCE(di.guild_id, ''),
+	       COALESCE(di.guild_name, ''),
+	       COALESCE(di.guild_icon, ''),
+	       COALESCE(di.member_count, 0),
+	       COALESCE(di.presence_count, 0),
+	       di.expired_at IS NOT NULL,
+	       COALESCE(di.checked_at, '')
+	FROM servers s
+	LEFT JOIN discord_invites di
+	  ON di.server_id = s.id
+	LEFT JOIN (
+	    -- score is the average pulled towards 3 stars by five phantom
+	    -- reviews, so a couple of 5-star reviews don't top the rating sort.
+	    SELECT server_id,
+	           ROUND(AVG(rating), 2) AS rating,
+	           COUNT(*) AS rating_count,
+	           (SUM(rating) + 3.0 * 5) / (COUNT(*) + 5) AS score
+	    FROM server_reviews
+	    WHERE hidden_at IS NULL
+	    GROUP BY server_id
+	) rv
+	  ON rv.server_id = s.id
+`
+
+func scanServerResult(row rowScanner) (ServerResult, error) {
+	var (
+		s        ServerResult
+		features string
+		links    string
+		resolved bool
+		guildID  string
+		icon     string
+		discord  DiscordCommunity
+	)
+	err := row.Scan(
+		&s.ID,
+		&s.ServerName,
+		&s.URL,
+		&s.Description,
+		&s.LongDescription,
+		&s.Tags,
+		&features,
+		&links,
+		&s.LogoURL,
+		&s.Logo,
+		&s.Status,
+		&s.Online,
+		&s.Registered,
+		&s.Votes,
+		&s.Rating,
+		&s.RatingCount,
+		&s.Added,
+		&s.Owner,
+		&s.VerifiedDomain,
+		&resolved,
+		&guildID,
+		&discord.GuildName,
+		&icon,
+		&discord.MemberCount,
+		&discord.PresenceCount,
+		&discord.Expired,
+		&discord.CheckedAt,
+	)
+	s.Features = decodeFeatures(features)
+	s.Links = decodeLinks(links)
+	if resolved && (discord.GuildName != "" || discord.Expired) {
+		discord.IconURL = discordGuildIconURL(guildID, icon)
+		s.Discord = &discord
+	}
+	return s, err
+}
+
+func queryServers(where string, args ...any) ([]ServerResult, error) {
+	rows, err := Database.Query(serverResultSelect+where, args...)
 	if err != nil {
-		log.Println("leaderboard query error:", err)
-		return c.Status(500).SendString("internal error")
+		return nil, err
 	}
 	defer rows.Close()
 
 	servers := make([]ServerResult, 0, 16)
 
 	for rows.Next() {
-		var s ServerResult
-		if err := rows.Scan(
-			&s.ID,
-			&s.ServerName,
-			&s.URL,
-			&s.Description,
-			&s.Tags,
-			&s.LogoURL,
-			&s.Status,
-			&s.Online,
-			&s.Registered,
-			&s.Votes,
-			&s.Added,
-			&s.Owner,
-		); err != nil {
-			return c.Status(500).SendString(err.Error())
+		s, err := scanServerResult(rows)
+		if err != nil {
+			return nil, err
 		}
 		servers = append(servers, s)
 	}
 
-	if err := rows.Err(); err != nil {
-		return c.Status(500).SendString(err.Error())
+	return servers, rows.Err()
+}
+
+// leaderboardSorts are the orders the leaderboard can be sorted in, keyed by
+// the sort query parameter. Votes is the default.
+var leaderboardSorts = map[string]string{
+	"votes":  `ORDER BY s.votes DESC, s.added DESC`,
+	"rating": `ORDER BY COALESCE(rv.score, 3.0) DESC, COALESCE(rv.rating_count, 0) DESC, s.votes DESC, s.added DESC`,
+}
+
+func leaderboardOrder(c fiber.Ctx) (string, error) {
+	sort := strings.ToLower(strings.TrimSpace(c.Query("sort")))
+	if sort == "" {
+		sort = "votes"
+	}
+	order, ok := leaderboardSorts[sort]
+	if !ok {
+		return "", fmt.Errorf("sort must be votes or rating")
+	}
+	return order, nil
+}
+
+// getLeaderboardHandler lists servers by votes, or with ?sort=rating by
+// review rating weighted towards the middle for servers with few reviews.
+// Feature filters such as ?mode=mania&leaderboard=relax narrow the list.
+func getLeaderboardHandler(c fiber.Ctx) error {
+	where, args, err := featureFilterClauses(c)
+	if err != nil {
+		return c.Status(400).SendString(err.Error())
+	}
+	order, err := leaderboardOrder(c)
+	if err != nil {
+		return c.Status(400).SendString(err.Error())
+	}
+
+	clause := ""
+	if len(where) > 0 {
+		clause = "WHERE " + strings.Join(where, " AND ") + " "
+	}
+
+	servers, err := queryServers(clause+order, args...)
+	if err != nil {
+		log.Println("leaderboard query error:", err)
+		return c.Status(500).SendString("internal error")
 	}
 
 	return c.JSON(servers)
@@ -112,50 +232,79 @@ func getLeaderboardHandler(c fiber.Ctx) error {
 func getServerHandler(c fiber.Ctx) error {
 	id := c.Params("id", "0")
 
-	row := Database.QueryRow(`
-		SELECT s.id,
-		       s.server_name,
-		       COALESCE(s.url, ''),
-		       COALESCE(s.description, ''),
-		       COALESCE(s.tags, ''),
-		       COALESCE(s.logo_url, ''),
-		       COALESCE(s.status, 'unknown'),
-		       COALESCE(s.online, 0),
-		       COALESCE(s.registered, 0),
-		       s.votes,
-		       s.added,
-		       u.username
-		FROM servers s
-		JOIN users u
-		  ON u.server = s.id
-		WHERE s.id = ?
-	`, id)
-
-	var s ServerResult
-
-	if err := row.Scan(
-		&s.ID,
-		&s.ServerName,
-		&s.URL,
-		&s.Description,
-		&s.Tags,
-		&s.LogoURL,
-		&s.Status,
-		&s.Online,
-		&s.Registered,
-		&s.Votes,
-		&s.Added,
-		&s.Owner,
-	); err != nil {
+	s, err := scanServerResult(Database.QueryRow(serverResultSelect+`WHERE s.id = ?`, id))
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return c.Status(404).SendString("server not found")
 		}
 		return c.Status(500).SendString(err.Error())
 	}
 
+	if s.Screenshots, err = publicScreenshots(s.ID); err != nil {
+		return c.Status(500).SendString("failed to load screenshots")
+	}
+	if s.Announcements, err = publicAnnouncements(s.ID); err != nil {
+		return c.Status(500).SendString("failed to load announcements")
+	}
+	if s.Reviews, err = publicReviews(s.ID); err != nil {
+		return c.Status(500).SendString("failed to load reviews")
+	}
+
 	return c.JSON(s)
 }
 
+// likeEscape escapes s for use in a LIKE ... ESCAPE '\' clause.
+func likeEscape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
+
+// tagFilterClause matches servers whose comma separated tags contain the
+// argument built by tagFilterArg.
+const tagFilterClause = `(',' || REPLACE(LOWER(COALESCE(s.tags, '')), ', ', ',') || ',') LIKE ? ESCAPE '\'`
+
+func tagFilterArg(tag string) string {
+	return "%," + likeEscape(strings.ToLower(strings.TrimSpace(tag))) + ",%"
+}
+
+func getSearchHandler(c fiber.Ctx) error {
+	q := strings.TrimSpace(c.Query("q"))
+	tag := strings.ToLower(strings.TrimSpace(c.Query("tag")))
+
+	if q == "" && tag == "" {
+		return c.Status(400).SendString("q or tag is required")
+	}
+	if len(q) > 100 || len(tag) > 50 {
+		return c.Status(400).SendString("search term is too long")
+	}
+
+	where := make([]string, 0, 2)
+	args := make([]any, 0, 4)
+
+	if q != "" {
+		pattern := "%" + likeEscape(q) + "%"
+		where = append(where, `(s.server_name LIKE ? ESCAPE '\'
+		    OR s.description LIKE ? ESCAPE '\'
+		    OR s.tags LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern, pattern)
+	}
+	if tag != "" {
+		where = append(where, tagFilterClause)
+		args = append(args, tagFilterArg(tag))
+	}
+
+	servers, err := queryServers(
+		"WHERE "+strings.Join(where, " AND ")+" ORDER BY s.votes DESC, s.added DESC",
+		args...,
+	)
+	if err != nil {
+		log.Println("search query error:", err)
+		return c.Status(500).SendString("internal error")
+	}
+
+	return c.JSON(servers)
+}
+
 func postVoteHandler(c fiber.Ctx) error {
 	id := c.Params("id", "0")
 	ip := c.IP()
@@ -190,12 +339,18 @@ func postVoteHandler(c fiber.Ctx) error {
 		return c.Status(500).SendString(err.Error())
 	}
 
+	var discordID string
+	if u, ok := getSessionUser(c); ok {
+		discordID = u.DiscordID
+	}
+
 	if _, err := Database.Exec(
-		`INSERT INTO votes (ip, server, user_name, last_vote)
-		 VALUES (?, ?, ?, datetime('now'))`,
+		`INSERT INTO votes (ip, server, user_name, discord_id, last_vote)
+		 VALUES (?, ?, ?, ?, datetime('now'))`,
 		ip,
 		id,
 		name,
+		nullEmpty(discordID),
 	); err != nil {
 		return c.Status(500).SendString(err.Error())
 	}
@@ -203,19 +358,27 @@ func postVoteHandler(c fiber.Ctx) error {
 	return c.SendString("ok")
 }
 
+// postServerRequestHandler files a new listing request. The submitter is
+// always the signed-in Discord account, never a form field.
 func postServerRequestHandler(c fiber.Ctx) error {
+	u, ok := getSessionUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).SendString("Sign in with Discord to submit a server.")
+	}
+
 	serverName := strings.TrimSpace(c.FormValue("server_name"))
 	urlValue := strings.TrimSpace(c.FormValue("url"))
 	description := strings.TrimSpace(c.FormValue("description"))
+	longDescription := strings.TrimSpace(c.FormValue("long_description"))
 	tags := strings.TrimSpace(c.FormValue("tags"))
-	ownerName := strings.TrimSpace(c.FormValue("owner_name"))
-	ownerDiscord := strings.TrimSpace(c.FormValue("owner_discord"))
+	ownerName := u.Username
+	ownerDiscord := u.DiscordID
 	logoURL := strings.TrimSpace(c.FormValue("logo_url"))
 	tosAccepted := strings.TrimSpace(c.FormValue("tos_accept")) != ""
 	captchaToken := c.FormValue("cf-turnstile-response")
 
-	if serverName == "" || ownerName == "" || ownerDiscord == "" {
-		return c.Status(400).SendString("server_name, owner_name and owner_discord are required")
+	if serverName == "" {
+		return c.Status(400).SendString("server_name is required")
 	}
 
 	if len(description) > MaxDescriptionLength {
@@ -224,6 +387,25 @@ func postServerRequestHandler(c fiber.Ctx) error {
 		)
 	}
 
+	if len(longDescription) > MaxLongDescriptionLength {
+		return c.Status(400).SendString(
+			fmt.Sprintf("Long description must be at most %d characters.", MaxLongDescriptionLength),
+		)
+	}
+
+	if !validListingURL(urlValue) {
+		return c.Status(400).SendString("url must be an http(s) link")
+	}
+
+	features, err := normalizeFeatures(featuresFromForm(c))
+	if err != nil {
+		return c.Status(400).SendString(err.Error())
+	}
+	links, err := normalizeLinks(linksFromForm(c))
+	if err != nil {
+		return c.Status(400).SendString(err.Error())
+	}
+
 	if !tosAccepted {
 		return c.Status(400).SendString("You must accept the Terms of Service to submit.")
 	}
@@ -232,37 +414,125 @@ func postServerRequestHandler(c fiber.Ctx) error {
 		return c.Status(400).SendString("Captcha verification failed.")
 	}
 
-	_, err := Database.Exec(`
+	// An uploaded logo replaces any logo_url typed into the form. It is only
+	// written once the request is stored, so a failed submission leaves no
+	// file behind.
+	var upload *encodedLogo
+	if fh, err := c.FormFile("logo_file"); err == nil && fh.Size > 0 {
+		data, err := readLogoUpload(c, "logo_file")
+		if err != nil {
+			return c.Status(400).SendString(err.Error())
+		}
+		img, err := decodeLogo(data)
+		if err != nil {
+			return c.Status(400).SendString(err.Error())
+		}
+		logo, err := encodeLogo(img)
+		if err != nil {
+			log.Println("encodeLogo:", err)
+			return c.Status(500).SendString("internal error")
+		}
+		upload = &logo
+		logoURL = logo.url()
+	}
+	if !validLogoURL(logoURL) {
+		return c.Status(400).SendString("logo_url must be an http(s) link")
+	}
+
+	res, err := Database.Exec(`
 		INSERT INTO server_requests (
 			server_name,
 			url,
 			description,
+			long_description,
 			tags,
+			features,
+			links,
 			owner_name,
 			owner_discord,
 			logo_url,
 			status,
 			created_at
 		)
-		VALUES (?, ?, ?, ?, ?, ?, ?, 'pending', datetime('now'))
-	`, serverName, urlValue, description, tags, ownerName, ownerDiscord, logoURL)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 'pending', datetime('now'))
+	`, serverName, urlValue, description, nullEmpty(longDescription), tags, encodeFeatures(features),
+		encodeLinks(links), ownerName, ownerDiscord, logoURL)
+	if err != nil {
+		log.Println("insert server_request:", err)
+		return c.Status(500).SendString("internal error")
+	}
+	requestID, err := res.LastInsertId()
 	if err != nil {
 		log.Println("insert server_request:", err)
 		return c.Status(500).SendString("internal error")
 	}
 
+	if upload != nil {
+		if err := upload.write(); err != nil {
+			log.Println("store logo:", err)
+			if _, err := Database.Exec(`DELETE FROM server_requests WHERE id = ?`, requestID); err != nil {
+				log.Println("delete server_request:", err)
+			}
+			return c.Status(500).SendString("internal error")
+		}
+	}
+
+	recordRequestRevision(int(requestID), ownerDiscord)
+
 	req := ServerRequest{
-		ServerName:   serverName,
-		URL:          urlValue,
-		Description:  description,
-		Tags:         tags,
-		OwnerName:    ownerName,
-		OwnerDiscord: ownerDiscord,
-		LogoURL:      logoURL,
-		Status:       "pending",
+		ServerName:      serverName,
+		URL:             urlValue,
+		Description:     description,
+		LongDescription: longDescription,
+		Tags:            tags,
+		Features:        features,
+		Links:           links,
+		OwnerName:       ownerName,
+		OwnerDiscord:    ownerDiscord,
+		LogoURL:         logoURL,
+		Status:          "pending",
 	}
 	notifyNewServerRequest(&req)
 
 	c.Set("Location", "/list?submitted=1")
 	return c.SendStatus(fiber.StatusSeeOther)
 }
+
+func getVoteCheckHandler(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id", "0"))
+	if err != nil || id <= 0 {
+		return c.Status(400).SendString("invalid server id")
+	}
+
+	name := strings.TrimSpace(c.Query("name"))
+	if name == "" {
+		return c.Status(400).SendString("name is required")
+	}
+
+	check := VoteCheck{
+		ServerID: id,
+		Name:     name,
+	}
+
+	var nextVote string
+	err = Database.QueryRow(`
+		SELECT last_vote,
+		       datetime(last_vote, '+12 hours')
+		FROM votes
+		WHERE server = ? AND user_name = ? COLLATE NOCASE
+		  AND last_vote > datetime('now', '-12 hours')
+		ORDER BY last_vote DESC
+		LIMIT 1
+	`, id, name).Scan(&check.LastVote, &nextVote)
+
+	if err != nil && err != sql.ErrNoRows {
+		return c.Status(500).SendString(err.Error())
+	}
+
+	if err == nil {
+		check.Voted = true
+		check.NextVoteAt = nextVote
+	}
+
+	return c.JSON(check)
+}
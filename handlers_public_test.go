@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestServerRequestValidatesLinks(t *testing.T) {
+	srv := newTestServer(t)
+	t.Setenv("TURNSTILE_SECRET", "")
+	session := testSessionToken(t, "100000000000000030")
+
+	submit := func(link, logo string) (int, string) {
+		t.Helper()
+
+		form := url.Values{
+			"server_name": {"Linked"},
+			"url":         {link},
+			"logo_url":    {logo},
+			"tos_accept":  {"on"},
+		}
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/list", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: "mossai_session", Value: session})
+
+		// Keep the 303 so it can be told apart from a redirect's target.
+		hc := *srv.Client()
+		hc.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+		resp, err := hc.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	for _, tt := range []struct{ link, logo string }{
+		{"javascript:alert(1)", ""},
+		{"example.com", ""},
+		{"https://example.com", "data:image/png;base64,AAAA"},
+		{"https://example.com", "ftp://example.com/logo.png"},
+	} {
+		if status, body := submit(tt.link, tt.logo); status != http.StatusBadRequest {
+			t.Errorf("url %q, logo %q: %d %s, want 400", tt.link, tt.logo, status, body)
+		}
+	}
+
+	if status, body := submit("https://example.com", "https://example.com/logo.png"); status != http.StatusSeeOther {
+		t.Fatalf("valid submission: %d %s", status, body)
+	}
+	var count int
+	if err := Database.QueryRow(`SELECT COUNT(*) FROM server_requests`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("%d requests stored, want 1", count)
+	}
+}
+
+func TestServerRequestStoresUploadedLogoOnlyOnSuccess(t *testing.T) {
+	srv := newTestServer(t)
+	t.Setenv("TURNSTILE_SECRET", "")
+	session := testSessionToken(t, "100000000000000031")
+	logo := testLogo(t, 256, 256)
+
+	submit := func() int {
+		t.Helper()
+
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		mw.WriteField("server_name", "Uploaded")
+		mw.WriteField("url", "https://uploaded.example")
+		mw.WriteField("tos_accept", "on")
+		fw, err := mw.CreateFormFile("logo_file", "logo.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(logo)
+		mw.Close()
+
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/list", &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		req.AddCookie(&http.Cookie{Name: "mossai_session", Value: session})
+
+		hc := *srv.Client()
+		hc.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+		resp, err := hc.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	stored := func() int {
+		t.Helper()
+		files, err := os.ReadDir(logoDir())
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		return len(files)
+	}
+
+	if _, err := Database.Exec(`
+		CREATE TRIGGER reject_requests BEFORE INSERT ON server_requests
+		BEGIN SELECT RAISE(ABORT, 'rejected'); END
+	`); err != nil {
+		t.Fatal(err)
+	}
+	if status := submit(); status != http.StatusInternalServerError {
+		t.Fatalf("failed insert: status %d, want 500", status)
+	}
+	if n := stored(); n != 0 {
+		t.Fatalf("%d logo files left after the insert failed", n)
+	}
+
+	if _, err := Database.Exec(`DROP TRIGGER reject_requests`); err != nil {
+		t.Fatal(err)
+	}
+	if status := submit(); status != http.StatusSeeOther {
+		t.Fatalf("submission: status %d, want 303", status)
+	}
+	if n := stored(); n != len(logoVariantSizes) {
+		t.Fatalf("%d logo files stored, want %d", n, len(logoVariantSizes))
+	}
+}
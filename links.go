@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const maxLinkURLLength = 300
+
+// linkType describes one kind of community link and how its URLs are
+// checked.
+type linkType struct {
+	name  string
+	label string
+	// hosts are the accepted hostnames; empty accepts any host.
+	hosts []string
+	// path, when set, must match the URL path.
+	path *regexp.Regexp
+	// hostPaths, when set, gives the path a host's links must match in
+	// place of path.
+	hostPaths map[string]*regexp.Regexp
+}
+
+// linkTypes are the accepted link types, in display order.
+var linkTypes = []linkType{
+	{
+		name:  "discord",
+		label: "Discord",
+		hosts: []string{"discord.gg", "discord.com", "discordapp.com"},
+		hostPaths: map[string]*regexp.Regexp{
+			"discord.gg":     regexp.MustCompile(`^/[A-Za-z0-9-]+/?$`),
+			"discord.com":    regexp.MustCompile(`^/invite/[A-Za-z0-9-]+/?$`),
+			"discordapp.com": regexp.MustCompile(`^/invite/[A-Za-z0-9-]+/?$`),
+		},
+	},
+	{
+		name:  "github",
+		label: "GitHub",
+		hosts: []string{"github.com"},
+		path:  regexp.MustCompile(`^/[A-Za-z0-9-]+(/[A-Za-z0-9._-]+)?/?$`),
+	},
+	{
+		name:  "twitter",
+		label: "Twitter / X",
+		hosts: []string{"twitter.com", "x.com"},
+		path:  regexp.MustCompile(`^/[A-Za-z0-9_]{1,15}/?$`),
+	},
+	{
+		name:  "youtube",
+		label: "YouTube",
+		hosts: []string{"youtube.com", "youtu.be"},
+		path:  regexp.MustCompile(`^/(@[A-Za-z0-9._-]+|c/[A-Za-z0-9._-]+|channel/[A-Za-z0-9_-]+|watch|[A-Za-z0-9_-]{11})/?$`),
+	},
+	{
+		name:  "donate",
+		label: "Donate",
+	},
+}
+
+func findLinkType(name string) (linkType, bool) {
+	for _, t := range linkTypes {
+		if t.name == name {
+			return t, true
+		}
+	}
+	return linkType{}, false
+}
+
+// linkTypeOptions lists the link types for page templates.
+func linkTypeOptions() []struct{ Name, Label string } {
+	out := make([]struct{ Name, Label string }, len(linkTypes))
+	for i, t := range linkTypes {
+		out[i].Name, out[i].Label = t.name, t.label
+	}
+	return out
+}
+
+func linkLabel(name string) string {
+	if t, ok := findLinkType(name); ok {
+		return t.label
+	}
+	return name
+}
+
+// validate checks raw against the link type. Only https links are accepted;
+// a www. prefix on the host is ignored.
+func (t linkType) validate(raw string) error {
+	if len(raw) > maxLinkURLLength {
+		return fmt.Errorf("%s link must be at most %d characters", t.label, maxLinkURLLength)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.User != nil {
+		return fmt.Errorf("%s link must be an https URL", t.label)
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if len(t.hosts) > 0 && !containsString(t.hosts, host) {
+		return fmt.Errorf("%s link must point to %s", t.label, strings.Join(t.hosts, " or "))
+	}
+	path := t.path
+	if p, ok := t.hostPaths[host]; ok {
+		path = p
+	}
+	if path != nil && !path.MatchString(u.Path) {
+		return fmt.Errorf("%s link does not look like a valid %s URL", t.label, t.label)
+	}
+	return nil
+}
+
+// normalizeLinks validates links, drops empty ones and orders the rest by
+// type. Each type may appear once.
+func normalizeLinks(links []ServerLink) ([]ServerLink, error) {
+	byType := make(map[string]string, len(links))
+	for _, l := range links {
+		name := strings.ToLower(strings.TrimSpace(l.Type))
+		raw := strings.TrimSpace(l.URL)
+		if raw == "" {
+			continue
+		}
+		t, ok := findLinkType(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown link type %q", l.Type)
+		}
+		if _, dup := byType[name]; dup {
+			return nil, fmt.Errorf("only one %s link is allowed", t.label)
+		}
+		if err := t.validate(raw); err != nil {
+			return nil, err
+		}
+		byType[name] = raw
+	}
+
+	out := make([]ServerLink, 0, len(byType))
+	for _, t := range linkTypes {
+		if raw, ok := byType[t.name]; ok {
+			out = append(out, ServerLink{Type: t.name, URL: raw})
+		}
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return out, nil
+}
+
+// linksFromForm reads the submission form, which has one link_<type> field
+// per link type.
+func linksFromForm(c fiber.Ctx) []ServerLink {
+	links := make([]ServerLink, 0, len(linkTypes))
+	for _, t := range linkTypes {
+		links = append(links, ServerLink{Type: t.name, URL: c.FormValue("link_" + t.name)})
+	}
+	return links
+}
+
+// encodeLinks returns the stored form of links, or "" when there are none.
+func encodeLinks(links []ServerLink) string {
+	if len(links) == 0 {
+		return ""
+	}
+	b, err := json.Marshal(links)
+	if err != nil {
+		log.Println("encodeLinks:", err)
+		return ""
+	}
+	return string(b)
+}
+
+func decodeLinks(raw string) []ServerLink {
+	if raw == "" {
+		return nil
+	}
+	var links []ServerLink
+	if err := json.Unmarshal([]byte(raw), &links); err != nil {
+		log.Println("decodeLinks:", err)
+	}
+	return links
+}
+
+// formatLinks renders links one per line for edit diffs.
+func formatLinks(links []ServerLink) string {
+	lines := make([]string, len(links))
+	for i, l := range links {
+		lines[i] = l.Type + ": " + l.URL
+	}
+	return strings.Join(lines, "\n")
+}
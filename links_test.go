@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestDiscordLinkPaths(t *testing.T) {
+	discord, _ := findLinkType("discord")
+
+	for raw, ok := range map[string]bool{
+		"https://discord.gg/osu":                        true,
+		"https://discord.gg/osu/":                       true,
+		"https://discord.com/invite/osu":                true,
+		"https://www.discordapp.com/invite/osu":         true,
+		"https://discord.gg/invite/osu":                 false,
+		"https://discord.com/osu":                       false,
+		"https://discord.com/channels":                  false,
+		"https://discord.com/invite/osu/extra":          false,
+		"https://discordapp.com/developers":             false,
+		"https://discord.com/invite/osu?event=12345678": true,
+	} {
+		if err := discord.validate(raw); (err == nil) != ok {
+			t.Errorf("validate(%q) = %v, want ok %v", raw, err, ok)
+		}
+	}
+}
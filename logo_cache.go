@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	logoRefreshInterval = 24 * time.Hour
+	logoFetchTimeout    = 15 * time.Second
+	logoFetchBatch      = 50
+)
+
+// logoFetcher downloads remote logos, refusing internal addresses. Tests
+// replace it to hand back generated images.
+var logoFetcher = fetchRemoteBytes
+
+// isLocalLogoURL reports whether a logo is already served from our origin.
+func isLocalLogoURL(raw string) bool {
+	return isHostedLogoURL(raw) || strings.HasPrefix(raw, "/static/")
+}
+
+// cacheRemoteLogo fetches a remote logo, stores it like an upload and
+// records where the copy lives. A failed refresh keeps the previous copy.
+func cacheRemoteLogo(ctx context.Context, src string) error {
+	ctx, cancel := context.WithTimeout(ctx, logoFetchTimeout)
+	defer cancel()
+
+	hosted, err := func() (string, error) {
+		data, _, err := logoFetcher(ctx, src, maxLogoUploadBytes)
+		if err != nil {
+			return "", err
+		}
+		img, err := decodeLogo(data)
+		if err != nil {
+			return "", err
+		}
+		return storeLogo(img)
+	}()
+
+	if err != nil {
+		if _, dbErr := Database.Exec(`
+			INSERT INTO logo_cache (source_url, fetched_at, last_error, failures)
+			VALUES (?, datetime('now'), ?, 1)
+			ON CONFLICT(source_url) DO UPDATE SET
+				fetched_at = excluded.fetched_at,
+				last_error = excluded.last_error,
+				failures   = failures + 1
+		`, src, err.Error()); dbErr != nil {
+			log.Println("cacheRemoteLogo:", dbErr)
+		}
+		return err
+	}
+
+	_, err = Database.Exec(`
+		INSERT INTO logo_cache (source_url, hosted_url, fetched_at, failures)
+		VALUES (?, ?, datetime('now'), 0)
+		ON CONFLICT(source_url) DO UPDATE SET
+			hosted_url = excluded.hosted_url,
+			fetched_at = excluded.fetched_at,
+			last_error = NULL,
+			failures   = 0
+	`, src, hosted)
+	return err
+}
+
+// cacheLogoInBackground caches a newly set remote logo without holding up
+// the request that set it.
+func cacheLogoInBackground(src string) {
+	src = strings.TrimSpace(src)
+	if src == "" || isLocalLogoURL(src) {
+		return
+	}
+	go func() {
+		if err := cacheRemoteLogo(context.Background(), src); err != nil {
+			log.Printf("cache logo %s: %v", src, err)
+		}
+	}()
+}
+
+// refreshRemoteLogos caches remote logos of live listings that were never
+// fetched or were last fetched more than interval ago.
+func refreshRemoteLogos(ctx context.Context, interval time.Duration) {
+	rows, err := Database.Query(`
+		SELECT DISTINCT s.logo_url
+		FROM servers s
+		LEFT JOIN logo_cache lc
+		  ON lc.source_url = s.logo_url
+		WHERE (s.logo_url LIKE 'http://%' OR s.logo_url LIKE 'https://%')
+		  AND (lc.fetched_at IS NULL OR lc.fetched_at < datetime('now', ?))
+		LIMIT ?
+	`, fmt.Sprintf("-%d seconds", int(interval.Seconds())), logoFetchBatch)
+	if err != nil {
+		log.Println("refreshRemoteLogos:", err)
+		return
+	}
+	sources := make([]string, 0, logoFetchBatch)
+	for rows.Next() {
+		var src string
+		if err := rows.Scan(&src); err == nil {
+			sources = append(sources, src)
+		}
+	}
+	rows.Close()
+
+	for _, src := range sources {
+		if err := cacheRemoteLogo(ctx, src); err != nil {
+			log.Printf("refresh logo %s: %v", src, err)
+		}
+	}
+}
+
+// startLogoRefresh keeps cached copies of remote logos fresh for the
+// lifetime of the process.
+func startLogoRefresh(interval time.Duration) {
+	go func() {
+		refreshRemoteLogos(context.Background(), interval)
+
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			refreshRemoteLogos(context.Background(), interval)
+		}
+	}()
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testLogo encodes a solid w x h PNG.
+func testLogo(t *testing.T, w, h int) []byte {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{R: 200, G: 40, B: 90, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// useLogoFetcher answers logo fetches from images, keyed by URL, for the
+// rest of the test.
+func useLogoFetcher(t *testing.T, images map[string][]byte) {
+	t.Helper()
+
+	prev := logoFetcher
+	logoFetcher = func(_ context.Context, rawURL string, _ int64) ([]byte, string, error) {
+		data, ok := images[rawURL]
+		if !ok {
+			return nil, "", errors.New("unexpected status 404")
+		}
+		return data, "image/png", nil
+	}
+	t.Cleanup(func() { logoFetcher = prev })
+}
+
+func logoCacheRow(t *testing.T, src string) (hosted, lastError string, failures int) {
+	t.Helper()
+
+	if err := Database.QueryRow(`
+		SELECT COALESCE(hosted_url, ''), COALESCE(last_error, ''), failures
+		FROM logo_cache
+		WHERE source_url = ?
+	`, src).Scan(&hosted, &lastError, &failures); err != nil {
+		t.Fatal(err)
+	}
+	return hosted, lastError, failures
+}
+
+func TestCacheRemoteLogo(t *testing.T) {
+	newTestServer(t)
+	const src = "https://cdn.example/wide.png"
+	images := map[string][]byte{src: testLogo(t, 400, 100)}
+	useLogoFetcher(t, images)
+
+	if err := cacheRemoteLogo(context.Background(), src); err != nil {
+		t.Fatal(err)
+	}
+	hosted, _, failures := logoCacheRow(t, src)
+	if !isHostedLogoURL(hosted) || failures != 0 {
+		t.Fatalf("logo_cache = %q, %d failures", hosted, failures)
+	}
+
+	// Every variant is a square with the logo letterboxed in the middle.
+	for _, size := range logoVariantSizes {
+		name := strings.TrimPrefix(logoVariantURL(hosted, size), hostedLogoPrefix)
+		f, err := os.Open(filepath.Join(logoDir(), name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if b := img.Bounds(); b.Dx() != size || b.Dy() != size {
+			t.Fatalf("variant %d is %dx%d", size, b.Dx(), b.Dy())
+		}
+		if _, _, _, a := img.At(size/2, 0).RGBA(); a != 0 {
+			t.Errorf("variant %d: top edge is not transparent", size)
+		}
+		if _, _, _, a := img.At(size/2, size/2).RGBA(); a == 0 {
+			t.Errorf("variant %d: centre is transparent", size)
+		}
+	}
+
+	// A failed refresh keeps serving the copy already stored.
+	delete(images, src)
+	if err := cacheRemoteLogo(context.Background(), src); err == nil {
+		t.Fatal("refresh passed without the image")
+	}
+	kept, lastError, failures := logoCacheRow(t, src)
+	if kept != hosted || lastError == "" || failures != 1 {
+		t.Fatalf("logo_cache after a failure = %q, %q, %d failures", kept, lastError, failures)
+	}
+}
+
+func TestCacheRemoteLogoRejectsBadImages(t *testing.T) {
+	newTestServer(t)
+	useLogoFetcher(t, map[string][]byte{
+		"https://cdn.example/tiny.png": testLogo(t, 16, 16),
+		"https://cdn.example/page.png": []byte("<html>not an image</html>"),
+	})
+
+	for _, src := range []string{"https://cdn.example/tiny.png", "https://cdn.example/page.png"} {
+		if err := cacheRemoteLogo(context.Background(), src); err == nil {
+			t.Errorf("cached %s", src)
+		}
+		if hosted, _, failures := logoCacheRow(t, src); hosted != "" || failures != 1 {
+			t.Errorf("%s: logo_cache = %q, %d failures", src, hosted, failures)
+		}
+	}
+}
+
+func TestRefreshRemoteLogosServesCachedCopy(t *testing.T) {
+	newTestServer(t)
+	const src = "https://cdn.example/logo.png"
+	useLogoFetcher(t, map[string][]byte{src: testLogo(t, 128, 128)})
+
+	id := insertTestServer(t, "Logo", "https://logo.example", 0)
+	if _, err := Database.Exec(`UPDATE servers SET logo_url = ? WHERE id = ?`, src, id); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := scanServerResult(Database.QueryRow(serverResultSelect+`WHERE s.id = ?`, id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Logo != "" {
+		t.Fatalf("Logo = %q before the logo was cached", s.Logo)
+	}
+
+	refreshRemoteLogos(context.Background(), logoRefreshInterval)
+
+	if s, err = scanServerResult(Database.QueryRow(serverResultSelect+`WHERE s.id = ?`, id)); err != nil {
+		t.Fatal(err)
+	}
+	if !isHostedLogoURL(s.Logo) || s.LogoURL != src {
+		t.Fatalf("Logo = %q, LogoURL = %q", s.Logo, s.LogoURL)
+	}
+}
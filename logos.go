@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"golang.org/x/image/draw"
+)
+
+const (
+	maxLogoUploadBytes = 2 << 20
+	minLogoDimension   = 32
+
+	// hostedLogoPrefix is the route hosted logos are served from.
+	hostedLogoPrefix = "/logos/"
+
+	// logoDisplaySize is the variant stored in logo_url.
+	logoDisplaySize = 256
+
+	logoCacheMaxAge = 365 * 24 * 60 * 60
+)
+
+// logoVariantSizes are the square sizes every hosted logo is rendered at.
+var logoVariantSizes = []int{64, logoDisplaySize}
+
+var hostedLogoName = regexp.MustCompile(`^[0-9a-f]{32}-([0-9]+)\.png$`)
+
+// allowedLogoTypes are the sniffed content types accepted for logos.
+var allowedLogoTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/webp": true,
+	"image/gif":  true,
+}
+
+var errLogoType = errors.New("logo must be a PNG, JPEG, WebP or GIF image")
+
+var logoUploadLimiter = newRateLimiter(time.Hour)
+
+const logoUploadsPerHour = 30
+
+// logoDir is where hosted logos are written; MOSS_LOGO_DIR overrides it.
+func logoDir() string {
+	if v := strings.TrimSpace(os.Getenv("MOSS_LOGO_DIR")); v != "" {
+		return v
+	}
+	return "./data/logos"
+}
+
+// isHostedLogoURL reports whether raw points at a logo stored by mossai.
+func isHostedLogoURL(raw string) bool {
+	name, ok := strings.CutPrefix(raw, hostedLogoPrefix)
+	return ok && hostedLogoName.MatchString(name)
+}
+
+// validLogoURL accepts remote http(s) links and hosted logos.
+func validLogoURL(raw string) bool {
+	return validListingURL(raw) || isHostedLogoURL(raw)
+}
+
+// logoVariantURL returns the size variant of a hosted logo. Other URLs are
+// returned unchanged.
+func logoVariantURL(logoURL string, size int) string {
+	if !isHostedLogoURL(logoURL) {
+		return logoURL
+	}
+	base := strings.TrimSuffix(logoURL, strconv.Itoa(logoDisplaySize)+".png")
+	return base + strconv.Itoa(size) + ".png"
+}
+
+// decodeLogo sniffs and decodes an uploaded or fetched logo, rejecting
+// anything that is not a reasonably sized PNG, JPEG, WebP or GIF.
+func decodeLogo(data []byte) (image.Image, error) {
+	if len(data) > maxLogoUploadBytes {
+		return nil, fmt.Errorf("logo must be at most %d MB", maxLogoUploadBytes>>20)
+	}
+	if !allowedLogoTypes[http.DetectContentType(data)] {
+		return nil, errLogoType
+	}
+
+	img, _, err := decodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	if b.Dx() < minLogoDimension || b.Dy() < minLogoDimension {
+		return nil, fmt.Errorf("logo must be at least %dx%d pixels", minLogoDimension, minLogoDimension)
+	}
+	return img, nil
+}
+
+// storeLogo re-encodes img as PNG at every variant size and writes the
+// variants under a name derived from their content. It returns the URL of
+// the display variant. Re-encoding drops any metadata the original carried.
+func storeLogo(img image.Image) (string, error) {
+	logo, err := encodeLogo(img)
+	if err != nil {
+		return "", err
+	}
+	if err := logo.write(); err != nil {
+		return "", err
+	}
+	return logo.url(), nil
+}
+
+// encodedLogo is a logo re-encoded at every variant size, named by the hash
+// of its display variant.
+type encodedLogo struct {
+	hash     string
+	variants map[int][]byte
+}
+
+func encodeLogo(img image.Image) (encodedLogo, error) {
+	variants := make(map[int][]byte, len(logoVariantSizes))
+	for _, size := range logoVariantSizes {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, fitSquare(img, size)); err != nil {
+			return encodedLogo{}, err
+		}
+		variants[size] = buf.Bytes()
+	}
+
+	sum := sha256.Sum256(variants[logoDisplaySize])
+	return encodedLogo{hash: hex.EncodeToString(sum[:16]), variants: variants}, nil
+}
+
+// url returns where the display variant is served once written.
+func (l encodedLogo) url() string {
+	return fmt.Sprintf("%s%s-%d.png", hostedLogoPrefix, l.hash, logoDisplaySize)
+}
+
+// write stores the variants under logoDir. If one fails, the variants this
+// call created are removed again; files already there belong to other
+// listings with the same logo and are kept.
+func (l encodedLogo) write() error {
+	dir := logoDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	var created []string
+	for size, encoded := range l.variants {
+		path := filepath.Join(dir, fmt.Sprintf("%s-%d.png", l.hash, size))
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		if err := writeFileAtomic(path, encoded); err != nil {
+			for _, p := range created {
+				os.Remove(p)
+			}
+			return err
+		}
+		created = append(created, path)
+	}
+	return nil
+}
+
+// fitSquare scales img to fit a size x size square, centred on a
+// transparent background.
+func fitSquare(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := size, size
+	if b.Dx() > b.Dy() {
+		h = max(1, size*b.Dy()/b.Dx())
+	} else if b.Dy() > b.Dx() {
+		w = max(1, size*b.Dx()/b.Dy())
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	target := image.Rect((size-w)/2, (size-h)/2, (size-w)/2+w, (size-h)/2+h)
+	draw.CatmullRom.Scale(dst, target, img, b, draw.Src, nil)
+	return dst
+}
+
+// writeFileAtomic writes data next to path and renames it into place, so a
+// concurrent reader never sees a partial file. Existing files are kept since
+// names are content hashes.
+func writeFileAtomic(path string, data []byte) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".logo-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// readLogoUpload reads the multipart file field, enforcing the size limit
+// before the whole body is buffered.
+func readLogoUpload(c fiber.Ctx, field string) ([]byte, error) {
+	fh, err := c.FormFile(field)
+	if err != nil {
+		return nil, errors.New("attach an image as the logo")
+	}
+	if fh.Size > maxLogoUploadBytes {
+		return nil, fmt.Errorf("logo must be at most %d MB", maxLogoUploadBytes>>20)
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, maxLogoUploadBytes+1))
+}
+
+// postLogoUploadHandler stores an uploaded logo and returns its URL, which
+// the submission and edit forms then send as logo_url.
+func postLogoUploadHandler(c fiber.Ctx) error {
+	u, ok := getSessionUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).SendString("Sign in with Discord to upload a logo.")
+	}
+
+	if allowed, _, _ := logoUploadLimiter.allow(u.DiscordID, logoUploadsPerHour); !allowed {
+		return c.Status(fiber.StatusTooManyRequests).SendString("Too many uploads, try again later.")
+	}
+
+	data, err := readLogoUpload(c, "logo")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	img, err := decodeLogo(data)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	logoURL, err := storeLogo(img)
+	if err != nil {
+		log.Println("storeLogo:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to store logo")
+	}
+
+	variants := fiber.Map{}
+	for _, size := range logoVariantSizes {
+		variants[strconv.Itoa(size)] = logoVariantURL(logoURL, size)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"logo_url": logoURL,
+		"variants": variants,
+	})
+}
@@ -14,30 +14,62 @@ func main() {
 	SetupSQL()
 	defer Database.Close()
 
+	LoadTemplates()
+
+	startDomainRechecks(defaultDomainVerifier, domainRecheckInterval)
+	startLogoRefresh(logoRefreshInterval)
+	startDiscordInviteRefresh(discordInviteRefreshInterval)
+	startAPIKeyUsageFlush(apiKeyUsageFlushInterval)
+
+	log.Fatal(newApp().Listen(":8080"))
+}
+
+// newApp builds the fiber app with every route registered.
+func newApp() *fiber.App {
+	// Behind a reverse proxy on the host or private network, c.IP() is the
+	// client from X-Forwarded-For so rate limits are per visitor.
 	app := fiber.New(fiber.Config{
 		TrustProxy: true,
+		TrustProxyConfig: fiber.TrustProxyConfig{
+			Loopback:  true,
+			Private:   true,
+			LinkLocal: true,
+		},
+		ProxyHeader:        fiber.HeaderXForwardedFor,
+		EnableIPValidation: true,
 	})
 
 	// static
 	app.Use("/static", static.New("./public"))
+	app.Use("/logos", static.New(logoDir(), static.Config{MaxAge: logoCacheMaxAge}))
+	app.Get("/screenshots/pending/:name", getPendingScreenshotHandler)
+	app.Use("/screenshots", static.New(screenshotDir(), static.Config{MaxAge: logoCacheMaxAge}))
 
 	// pages
-	app.Get("/", func(c fiber.Ctx) error {
-		return c.SendFile("./public/index.html")
-	})
-	app.Get("/servers/:id", func(c fiber.Ctx) error {
-		return c.SendFile("./public/server.html")
-	})
-	app.Get("/list", func(c fiber.Ctx) error {
-		return c.SendFile("./public/list.html")
+	app.Get("/", indexPageHandler)
+	app.Get("/servers/:id", serverPageHandler)
+	app.Get("/servers/:id/og.png", serverOGImageHandler)
+	app.Get("/list", listPageHandler)
+	app.Get("/tags/:tag", tagPageHandler)
+	app.Get("/robots.txt", robotsHandler)
+	app.Get("/sitemap.xml", sitemapHandler)
+	app.Get("/feed.atom", atomFeedHandler)
+	app.Get("/feed.rss", rssFeedHandler)
+	app.Get("/developers", func(c fiber.Ctx) error {
+		return c.SendFile("./public/developers.html")
 	})
+	app.Get("/owner", ownerPageHandler)
 	app.Get("/admin/requests", adminPageHandler)
 
 	// public JSON APIs
-	app.Get("/leaderboard", getLeaderboardHandler)
-	app.Get("/server/:id", getServerHandler)
+	app.Get("/leaderboard", apiAccess(apiScopeRead), getLeaderboardHandler)
+	app.Get("/server/:id", apiAccess(apiScopeRead), getServerHandler)
+	app.Get("/search", apiAccess(apiScopeRead), getSearchHandler)
+	app.Get("/events", apiAccess(apiScopeRead), getEventsHandler)
+	app.Get("/server/:id/vote/check", apiAccess(apiScopeVoteCheck), getVoteCheckHandler)
 	app.Post("/server/:id/vote", postVoteHandler)
 	app.Post("/list", postServerRequestHandler)
+	app.Post("/api/logos", postLogoUploadHandler)
 
 	// auth APIs
 	app.Get("/auth/discord/login", discordLoginHandler)
@@ -45,11 +77,76 @@ func main() {
 	app.Get("/auth/me", authMeHandler)
 	app.Post("/auth/logout", logoutHandler)
 
+	// api key management
+	app.Get("/api/keys", getAPIKeysHandler)
+	app.Post("/api/keys", postAPIKeyHandler)
+	app.Post("/api/keys/:id/revoke", postRevokeAPIKeyHandler)
+
+	// owner APIs
+	app.Get("/api/owner/servers", getOwnerServersHandler)
+	app.Post("/api/owner/servers/:id/edits", postOwnerServerEditHandler)
+	app.Post("/api/owner/servers/:id/invites", postOwnerInviteHandler)
+	app.Post("/api/owner/servers/:id/invites/:inviteId/cancel", postCancelInviteHandler)
+	app.Post("/api/owner/servers/:id/transfer", postOwnerTransferHandler)
+	app.Post("/api/owner/servers/:id/owners/:discordId/remove", postRemoveOwnerHandler)
+	app.Get("/api/owner/servers/:id/verification", getOwnerVerificationHandler)
+	app.Post("/api/owner/servers/:id/verification/check", postOwnerVerificationCheckHandler)
+	app.Post("/api/owner/servers/:id/screenshots", postOwnerScreenshotHandler)
+	app.Post("/api/owner/servers/:id/screenshots/order", postOwnerScreenshotOrderHandler)
+	app.Post("/api/owner/servers/:id/screenshots/:shotId", postOwnerScreenshotCaptionHandler)
+	app.Post("/api/owner/servers/:id/screenshots/:shotId/remove", postOwnerScreenshotRemoveHandler)
+	app.Post("/api/owner/servers/:id/announcements", postOwnerAnnouncementHandler)
+	app.Post("/api/owner/servers/:id/announcements/:annId/remove", postOwnerAnnouncementRemoveHandler)
+	app.Post("/api/owner/servers/:id/reviews/:reviewId/reply", postOwnerReviewReplyHandler)
+	app.Get("/api/owner/requests", getOwnerRequestsHandler)
+	app.Post("/api/owner/requests/:id", postOwnerRequestEditHandler)
+	app.Post("/api/owner/requests/:id/withdraw", postOwnerRequestWithdrawHandler)
+	app.Get("/api/owner/invites", getOwnerInvitesHandler)
+	app.Get("/api/owner/claims", getOwnerClaimsHandler)
+	app.Get("/api/owner/claims/:id/verification", getClaimVerificationHandler)
+	app.Post("/api/owner/claims/:id/verification/check", postClaimVerificationCheckHandler)
+	app.Post("/api/servers/:id/claims", postServerClaimHandler)
+	app.Get("/api/servers/:id/reviews/mine", getMyReviewHandler)
+	app.Post("/api/servers/:id/reviews", postReviewHandler)
+	app.Post("/api/servers/:id/reviews/remove", postRemoveReviewHandler)
+	app.Post("/api/reviews/:id/report", postReviewReportHandler)
+	app.Post("/api/servers/:id/reports", postServerReportHandler)
+	app.Post("/api/owner/invites/:id/accept", postAcceptInviteHandler)
+	app.Post("/api/owner/invites/:id/decline", postDeclineInviteHandler)
+
 	// admin JSON APIs
 	app.Get("/admin/requests/data", getAdminRequestsHandler)
-	app.Post("/admin/requests/:id/update", postAdminUpdateHandler)
-	app.Post("/admin/requests/:id/approve", postAdminApproveHandler)
-	app.Post("/admin/requests/:id/reject", postAdminRejectHandler)
-	app.Post("/api/admin/servers/:id/remove", postAdminRemoveServerHandler)
-	log.Fatal(app.Listen(":8080"))
+	app.Post("/admin/requests/:id/update", requirePermission(permManageRequests), postAdminUpdateHandler)
+	app.Post("/admin/requests/:id/approve", requirePermission(permManageRequests), postAdminApproveHandler)
+	app.Post("/admin/requests/:id/reject", requirePermission(permManageRequests), postAdminRejectHandler)
+	app.Get("/admin/requests/:id/revisions", getAdminRequestRevisionsHandler)
+	app.Post("/admin/requests/:id/seen", postAdminRequestSeenHandler)
+	app.Get("/api/admin/rejection-reasons", getAdminRejectionReasonsHandler)
+	app.Post("/api/admin/servers/:id/remove", requirePermission(permRemoveServers), postAdminRemoveServerHandler)
+	app.Post("/api/admin/servers/:id/votes", requirePermission(permAdjustVotes), postAdminAdjustVotesHandler)
+	app.Get("/api/admin/claims", getAdminClaimsHandler)
+	app.Post("/api/admin/claims/:id/approve", requirePermission(permManageRequests), postAdminApproveClaimHandler)
+	app.Post("/api/admin/claims/:id/reject", requirePermission(permManageRequests), postAdminRejectClaimHandler)
+	app.Get("/api/admin/screenshots", getAdminScreenshotsHandler)
+	app.Post("/api/admin/screenshots/:id/approve", requirePermission(permManageRequests), postAdminApproveScreenshotHandler)
+	app.Post("/api/admin/screenshots/:id/reject", requirePermission(permManageRequests), postAdminRejectScreenshotHandler)
+	app.Get("/api/admin/discord-invites", getAdminDiscordInvitesHandler)
+	app.Get("/api/admin/announcements", getAdminAnnouncementsHandler)
+	app.Post("/api/admin/announcements/:id/hide", requirePermission(permModerateContent), postAdminHideAnnouncementHandler)
+	app.Post("/api/admin/announcements/:id/unhide", requirePermission(permModerateContent), postAdminUnhideAnnouncementHandler)
+	app.Get("/api/admin/reviews", getAdminReviewsHandler)
+	app.Post("/api/admin/reviews/:id/hide", requirePermission(permModerateContent), postAdminHideReviewHandler)
+	app.Post("/api/admin/reviews/:id/unhide", requirePermission(permModerateContent), postAdminUnhideReviewHandler)
+	app.Post("/api/admin/reviews/:id/dismiss", requirePermission(permModerateContent), postAdminDismissReviewReportsHandler)
+	app.Get("/api/admin/reports", getAdminReportsHandler)
+	app.Post("/api/admin/reports/:id", requirePermission(permModerateContent), postAdminUpdateReportHandler)
+	app.Get("/api/admin/api-keys", getAdminAPIKeysHandler)
+	app.Post("/api/admin/api-keys/:id/revoke", requirePermission(permManageAPIKeys), postAdminRevokeAPIKeyHandler)
+	app.Post("/api/admin/api-keys/:id/tier", requirePermission(permManageAPIKeys), postAdminAPIKeyTierHandler)
+	app.Get("/api/admin/admins", getAdminUsersHandler)
+	app.Get("/api/admin/audit-log", getAdminAuditLogHandler)
+	app.Post("/api/admin/admins", requirePermission(permManageAdmins), postAdminSetUserHandler)
+	app.Post("/api/admin/admins/:discordId/remove", requirePermission(permManageAdmins), postAdminRemoveUserHandler)
+
+	return app
 }
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3/middleware/adaptor"
+)
+
+const testAdminID = "100000000000000001"
+
+// newTestServer serves the full app from a fresh database under t.TempDir.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Setenv("MOSS_ADMIN_IDS", testAdminID)
+	t.Setenv("MOSS_LOGO_DIR", filepath.Join(dir, "logos"))
+	t.Setenv("MOSS_SCREENSHOT_DIR", filepath.Join(dir, "screenshots"))
+	t.Setenv("DISCORD_ADMIN_WEBHOOK_URL", "")
+	t.Setenv("DISCORD_BOT_TOKEN", "")
+
+	openDatabase(filepath.Join(dir, "mossai.db"))
+	t.Cleanup(func() { Database.Close() })
+	SetupSQL()
+	LoadTemplates()
+
+	// Usage counted against an earlier test's keys must not be flushed here.
+	apiKeyUsage.Lock()
+	apiKeyUsage.requests, apiKeyUsage.lastUsed = make(map[int]int), make(map[int]time.Time)
+	apiKeyUsage.Unlock()
+
+	srv := httptest.NewServer(adaptor.FiberApp(newApp()))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// insertTestServer adds a live listing and returns its id.
+func insertTestServer(t *testing.T, name, url string, votes int) int {
+	t.Helper()
+
+	res, err := Database.Exec(`
+		INSERT INTO servers (server_name, type, url, description, tags, votes, added)
+		VALUES (?, 0, ?, ?, 'pp,relax', ?, datetime('now'))
+	`, name, url, name+" is a test server", votes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := res.LastInsertId()
+	return int(id)
+}
+
+// insertTestAPIKey stores a key with the given scopes and returns the raw key.
+func insertTestAPIKey(t *testing.T, scopes string) string {
+	t.Helper()
+
+	raw := "moss_test_" + scopes
+	_, err := Database.Exec(`
+		INSERT INTO api_keys (name, key_hash, key_prefix, owner_discord, scopes, created_at)
+		VALUES ('test', ?, ?, ?, ?, datetime('now'))
+	`, hashAPIKey(raw), raw[:9], testAdminID, scopes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func testSessionToken(t *testing.T, discordID string) string {
+	t.Helper()
+
+	token, err := encodeSessionToken(SessionUser{DiscordID: discordID, Username: "tester"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// testRequest sends a request as the session's user and returns the status
+// and body. body is encoded as JSON unless it is nil.
+func testRequest(t *testing.T, srv *httptest.Server, method, path, session string, body any) (int, []byte) {
+	t.Helper()
+
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, srv.URL+path, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if session != "" {
+		req.AddCookie(&http.Cookie{Name: "mossai_session", Value: session})
+	}
+
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	out, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, out
+}
+
+// fakeDomain stands in for a domain's DNS and web server during
+// verification checks.
+type fakeDomain struct {
+	txt       map[string][]string
+	wellKnown map[string]string
+}
+
+func (f *fakeDomain) LookupTXT(_ context.Context, name string) ([]string, error) {
+	records, ok := f.txt[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
+
+func (f *fakeDomain) fetch(_ context.Context, rawURL string, _ int64) ([]byte, string, error) {
+	body, ok := f.wellKnown[rawURL]
+	if !ok {
+		return nil, "", errors.New("HTTP 404")
+	}
+	return []byte(body), "text/plain", nil
+}
+
+// useFakeDomains routes verification checks to f for the rest of the test.
+func useFakeDomains(t *testing.T, f *fakeDomain) {
+	t.Helper()
+
+	prev := defaultDomainVerifier
+	defaultDomainVerifier = &domainVerifier{resolver: f, fetch: f.fetch}
+	t.Cleanup(func() { defaultDomainVerifier = prev })
+}
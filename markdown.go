@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const MaxLongDescriptionLength = 10000
+
+// defaultImageHosts may be embedded in long descriptions unless
+// MOSS_IMAGE_HOSTS lists others.
+var defaultImageHosts = []string{
+	"i.imgur.com",
+	"cdn.discordapp.com",
+	"media.discordapp.net",
+	"raw.githubusercontent.com",
+}
+
+// markdownRenderer never passes raw HTML through; sanitizeHTML still checks
+// its output so a renderer bug cannot reach the page.
+var markdownRenderer = goldmark.New(
+	goldmark.WithExtensions(extension.Strikethrough, extension.Linkify),
+)
+
+func allowedImageHosts() []string {
+	if v := strings.TrimSpace(os.Getenv("MOSS_IMAGE_HOSTS")); v != "" {
+		return splitCSV(strings.ToLower(v))
+	}
+	return defaultImageHosts
+}
+
+// allowedTags maps each permitted element to the attributes it may keep.
+var allowedTags = map[atom.Atom][]string{
+	atom.P:          nil,
+	atom.Br:         nil,
+	atom.Hr:         nil,
+	atom.H2:         nil,
+	atom.H3:         nil,
+	atom.H4:         nil,
+	atom.Ul:         nil,
+	atom.Ol:         {"start"},
+	atom.Li:         nil,
+	atom.Strong:     nil,
+	atom.Em:         nil,
+	atom.Del:        nil,
+	atom.Code:       nil,
+	atom.Pre:        nil,
+	atom.Blockquote: nil,
+	atom.A:          {"href", "title"},
+	atom.Img:        {"src", "alt", "title"},
+}
+
+// headingShift keeps Markdown headings below the page's own h1.
+var headingShift = map[atom.Atom]atom.Atom{
+	atom.H1: atom.H2,
+	atom.H5: atom.H4,
+	atom.H6: atom.H4,
+}
+
+// droppedWithContent are elements whose text must not leak into the page.
+var droppedWithContent = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Iframe:   true,
+	atom.Object:   true,
+	atom.Textarea: true,
+	atom.Title:    true,
+}
+
+func safeLinkURL(raw string) bool {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return false
+	}
+	return u.Scheme == "http" || u.Scheme == "https" || u.Scheme == "mailto"
+}
+
+func safeImageURL(raw string) bool {
+	if isLocalLogoURL(raw) {
+		return true
+	}
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Scheme != "https" {
+		return false
+	}
+	return containsString(allowedImageHosts(), strings.ToLower(u.Hostname()))
+}
+
+// sanitizeHTML keeps only allowlisted elements and attributes. Disallowed
+// elements are unwrapped so their text survives, except for those in
+// droppedWithContent.
+func sanitizeHTML(src string) string {
+	z := html.NewTokenizer(strings.NewReader(src))
+	var out strings.Builder
+	skipDepth := 0
+	// open tracks which start tags were written so end tags stay balanced.
+	open := make([]atom.Atom, 0, 16)
+
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			for i := len(open) - 1; i >= 0; i-- {
+				out.WriteString("</" + open[i].String() + ">")
+			}
+			return out.String()
+
+		case html.TextToken:
+			if skipDepth == 0 {
+				out.WriteString(html.EscapeString(string(z.Text())))
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			a := tok.DataAtom
+			if droppedWithContent[a] {
+				if tt == html.StartTagToken {
+					skipDepth++
+				}
+				continue
+			}
+			if skipDepth > 0 {
+				continue
+			}
+			if shifted, ok := headingShift[a]; ok {
+				a = shifted
+			}
+			attrs, ok := allowedTags[a]
+			if !ok {
+				continue
+			}
+
+			var b strings.Builder
+			b.WriteString("<" + a.String())
+			valid := true
+			for _, attr := range tok.Attr {
+				if !containsString(attrs, attr.Key) {
+					continue
+				}
+				switch {
+				case a == atom.A && attr.Key == "href" && !safeLinkURL(attr.Val):
+					continue
+				case a == atom.Img && attr.Key == "src" && !safeImageURL(attr.Val):
+					valid = false
+				}
+				b.WriteString(" " + attr.Key + `="` + html.EscapeString(attr.Val) + `"`)
+			}
+			if !valid {
+				// Images from other hosts are dropped; their alt text stays.
+				for _, attr := range tok.Attr {
+					if attr.Key == "alt" {
+						out.WriteString(html.EscapeString(attr.Val))
+					}
+				}
+				continue
+			}
+			if a == atom.A {
+				b.WriteString(` rel="nofollow ugc noopener"`)
+			}
+			b.WriteString(">")
+			out.WriteString(b.String())
+
+			if a != atom.Img && a != atom.Br && a != atom.Hr && tt == html.StartTagToken {
+				open = append(open, a)
+			}
+
+		case html.EndTagToken:
+			tok := z.Token()
+			a := tok.DataAtom
+			if droppedWithContent[a] {
+				if skipDepth > 0 {
+					skipDepth--
+				}
+				continue
+			}
+			if skipDepth > 0 {
+				continue
+			}
+			if shifted, ok := headingShift[a]; ok {
+				a = shifted
+			}
+			// Close back to the matching open tag, if there is one.
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] != a {
+					continue
+				}
+				for j := len(open) - 1; j >= i; j-- {
+					out.WriteString("</" + open[j].String() + ">")
+				}
+				open = open[:i]
+				break
+			}
+		}
+	}
+}
+
+// renderMarkdown turns a long description into sanitized HTML.
+func renderMarkdown(src string) template.HTML {
+	src = strings.TrimSpace(src)
+	if src == "" {
+		return ""
+	}
+
+	var buf bytes.Buffer
+	if err := markdownRenderer.Convert([]byte(src), &buf); err != nil {
+		log.Println("renderMarkdown:", err)
+		return template.HTML("<p>" + html.EscapeString(src) + "</p>")
+	}
+	return template.HTML(sanitizeHTML(buf.String()))
+}
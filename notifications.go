@@ -94,6 +94,74 @@ func sendAdminWebhook(embed discordEmbed) {
 	}
 }
 
+var discordHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+func getDiscordBotToken() string {
+	return strings.TrimSpace(os.Getenv("DISCORD_BOT_TOKEN"))
+}
+
+// discordBotRequest calls the Discord API as the mossai bot and decodes the
+// JSON response into out when it is not nil.
+func discordBotRequest(method, path string, body, out any) error {
+	token := getDiscordBotToken()
+	if token == "" {
+		return fmt.Errorf("DISCORD_BOT_TOKEN is not set")
+	}
+
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(method, discordAPIBase()+path, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bot "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := discordHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("discord %s %s: status %d", method, path, resp.StatusCode)
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+// sendDiscordDM messages a user through the mossai bot. It is a no-op when no
+// bot token is configured, and users who closed their DMs are only logged.
+func sendDiscordDM(discordID string, embed discordEmbed) {
+	if getDiscordBotToken() == "" {
+		return
+	}
+	if _, err := strconv.ParseUint(discordID, 10, 64); err != nil {
+		return
+	}
+
+	var channel struct {
+		ID string `json:"id"`
+	}
+	if err := discordBotRequest(http.MethodPost, "/users/@me/channels", map[string]string{
+		"recipient_id": discordID,
+	}, &channel); err != nil {
+		log.Println("sendDiscordDM channel:", err)
+		return
+	}
+
+	if err := discordBotRequest(http.MethodPost, "/channels/"+channel.ID+"/messages", discordWebhookPayload{
+		Embeds: []discordEmbed{embed},
+	}, nil); err != nil {
+		log.Println("sendDiscordDM message:", err)
+	}
+}
+
 func notifyNewServerRequest(r *ServerRequest) {
 	descRaw := coalesce(r.Description, "No description was provided.")
 	descSnippet := truncate(descRaw, 200)
@@ -105,7 +173,7 @@ func notifyNewServerRequest(r *ServerRequest) {
 
 	var thumb *discordThumbnail
 	if url := strings.TrimSpace(r.LogoURL); url != "" {
-		thumb = &discordThumbnail{URL: url}
+		thumb = &discordThumbnail{URL: absoluteURL(url)}
 	}
 
 	embed := discordEmbed{
@@ -152,12 +220,59 @@ func notifyNewServerRequest(r *ServerRequest) {
 	sendAdminWebhook(embed)
 }
 
+func notifyServerEditRequest(r *ServerRequest, current ServerResult, changes []FieldChange) {
+	fields := []discordField{
+		{
+			Name:   "Owner",
+			Value:  formatDiscordOwner(r.OwnerName, r.OwnerDiscord),
+			Inline: true,
+		},
+		{
+			Name:   "Request ID",
+			Value:  fmt.Sprintf("`%d`", r.ID),
+			Inline: true,
+		},
+		{
+			Name:   "Server ID",
+			Value:  fmt.Sprintf("`%d`", r.ServerID),
+			Inline: true,
+		},
+	}
+	for _, ch := range changes {
+		fields = append(fields, discordField{
+			Name:   ch.Field,
+			Value:  fmt.Sprintf("%s\n→ %s", truncate(coalesce(ch.Old, "(empty)"), 200), truncate(coalesce(ch.New, "(empty)"), 200)),
+			Inline: false,
+		})
+	}
+
+	serverURL := buildServerURL(int64(r.ServerID))
+
+	embed := discordEmbed{
+		Title:       fmt.Sprintf("✏️ Edit request · %s", coalesce(current.ServerName, "unnamed server")),
+		Description: "An owner proposed changes to a live listing.",
+		URL:         serverURL,
+		Color:       0xFEE75C,
+		Author: &discordAuthor{
+			Name: coalesce(current.ServerName, "unnamed server"),
+			URL:  serverURL,
+		},
+		Fields:    fields,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Footer: &discordFooter{
+			Text: footerText("edit request"),
+		},
+	}
+
+	sendAdminWebhook(embed)
+}
+
 func notifyServerRequestApproved(r *ServerRequest, serverID int64) {
 	desc := "The request has been approved and the server is now live on mossai."
 
 	var thumb *discordThumbnail
 	if url := strings.TrimSpace(r.LogoURL); url != "" {
-		thumb = &discordThumbnail{URL: url}
+		thumb = &discordThumbnail{URL: absoluteURL(url)}
 	}
 
 	serverURL := buildServerURL(serverID)
@@ -208,7 +323,49 @@ func notifyServerRequestApproved(r *ServerRequest, serverID int64) {
 	sendAdminWebhook(embed)
 }
 
+func notifyServerEditApproved(r *ServerRequest) {
+	serverURL := buildServerURL(int64(r.ServerID))
+
+	embed := discordEmbed{
+		Title:       "🟢 Edit approved",
+		Description: "The owner's changes are now live on mossai.",
+		URL:         serverURL,
+		Color:       0x57F287,
+		Author: &discordAuthor{
+			Name: coalesce(r.ServerName, "unnamed server"),
+			URL:  serverURL,
+		},
+		Fields: []discordField{
+			{
+				Name:   "Server ID",
+				Value:  fmt.Sprintf("`%d`", r.ServerID),
+				Inline: true,
+			},
+			{
+				Name:   "Request ID",
+				Value:  fmt.Sprintf("`%d`", r.ID),
+				Inline: true,
+			},
+			{
+				Name:   "Owner",
+				Value:  formatDiscordOwner(r.OwnerName, r.OwnerDiscord),
+				Inline: false,
+			},
+		},
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Footer: &discordFooter{
+			Text: footerText("edit approved"),
+		},
+	}
+
+	sendAdminWebhook(embed)
+}
+
+// notifyServerRequestRejected tells the admins and, by DM, the submitter
+// that a request was rejected and why.
 func notifyServerRequestRejected(r *ServerRequest) {
+	reason := coalesce(formatRejection(r), "No reason given.")
+
 	descRaw := coalesce(r.Description, "No description was provided.")
 	descSnippet := truncate(descRaw, 200)
 
@@ -235,6 +392,11 @@ func notifyServerRequestRejected(r *ServerRequest) {
 				Value:  fmt.Sprintf("`%d`", r.ID),
 				Inline: true,
 			},
+			{
+				Name:   "Reason",
+				Value:  truncate(reason, 1000),
+				Inline: false,
+			},
 			{
 				Name:   "Submitted at",
 				Value:  coalesce(r.CreatedAt, "N/A"),
@@ -248,6 +410,18 @@ func notifyServerRequestRejected(r *ServerRequest) {
 	}
 
 	sendAdminWebhook(embed)
+
+	what := fmt.Sprintf("Your request to list %s", coalesce(r.ServerName, "your server"))
+	if r.Kind == requestKindEdit {
+		what = fmt.Sprintf("Your edit to %s", coalesce(r.ServerName, "your listing"))
+	}
+	sendDiscordDM(r.OwnerDiscord, discordEmbed{
+		Title:       "Your request was not approved",
+		Description: fmt.Sprintf("%s was rejected by the mossai admins.\n\n**Reason:** %s", what, truncate(reason, 1500)),
+		URL:         getBaseURL() + "/owner",
+		Color:       0xED4245,
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+	})
 }
 
 func coalesce(s, fallback string) string {
@@ -335,3 +509,253 @@ func getBaseURL() string {
 func buildServerURL(id int64) string {
 	return fmt.Sprintf("%s/servers/%d", getBaseURL(), id)
 }
+
+func notifyNewServerClaim(cl *ServerClaim) {
+	serverURL := buildServerURL(int64(cl.ServerID))
+
+	embed := discordEmbed{
+		Title:       fmt.Sprintf("🏷️ Ownership claim · %s", coalesce(cl.ServerName, "unnamed server")),
+		Description: fmt.Sprintf("> %s", truncate(cl.Evidence, 900)),
+		URL:         serverURL,
+		Color:       0x5865F2,
+		Author: &discordAuthor{
+			Name: coalesce(cl.ServerName, "unnamed server"),
+			URL:  serverURL,
+		},
+		Fields: []discordField{
+			{
+				Name:   "Claimant",
+				Value:  formatDiscordOwner(cl.Username, cl.DiscordID),
+				Inline: true,
+			},
+			{
+				Name:   "Claim ID",
+				Value:  fmt.Sprintf("`%d`", cl.ID),
+				Inline: true,
+			},
+			{
+				Name:   "Current owners",
+				Value:  coalesce(cl.CurrentOwners, "none"),
+				Inline: false,
+			},
+		},
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Footer: &discordFooter{
+			Text: footerText("ownership claim"),
+		},
+	}
+
+	sendAdminWebhook(embed)
+}
+
+// notifyClaimApproved tells the admins, the claimant and every previous owner
+// that a listing changed hands.
+func notifyClaimApproved(cl *ServerClaim, previousOwners []string) {
+	serverURL := buildServerURL(int64(cl.ServerID))
+	name := coalesce(cl.ServerName, "unnamed server")
+
+	sendAdminWebhook(discordEmbed{
+		Title:       "🟢 Claim approved",
+		Description: fmt.Sprintf("Ownership of %s was reassigned.", name),
+		URL:         serverURL,
+		Color:       0x57F287,
+		Fields: []discordField{
+			{
+				Name:   "New owner",
+				Value:  formatDiscordOwner(cl.Username, cl.DiscordID),
+				Inline: true,
+			},
+			{
+				Name:   "Claim ID",
+				Value:  fmt.Sprintf("`%d`", cl.ID),
+				Inline: true,
+			},
+			{
+				Name:   "Previous owners",
+				Value:  coalesce(cl.CurrentOwners, "none"),
+				Inline: false,
+			},
+		},
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Footer: &discordFooter{
+			Text: footerText("claim approved"),
+		},
+	})
+
+	sendDiscordDM(cl.DiscordID, discordEmbed{
+		Title:       "Your claim was approved",
+		Description: fmt.Sprintf("You are now the owner of %s on mossai. Manage it from %s/owner.", name, getBaseURL()),
+		URL:         serverURL,
+		Color:       0x57F287,
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+	})
+
+	for _, id := range previousOwners {
+		sendDiscordDM(id, discordEmbed{
+			Title: "A listing you managed changed owners",
+			Description: fmt.Sprintf(
+				"After reviewing an ownership claim, the mossai admins reassigned %s to %s. "+
+					"If you think this is a mistake, contact the mossai admins.",
+				name, coalesce(cl.Username, "its operator"),
+			),
+			URL:       serverURL,
+			Color:     0xFEE75C,
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+func notifyNewScreenshot(sc *Screenshot, u *SessionUser) {
+	serverURL := buildServerURL(int64(sc.ServerID))
+
+	sendAdminWebhook(discordEmbed{
+		Title:       fmt.Sprintf("🖼️ New screenshot · %s", coalesce(sc.ServerName, "unnamed server")),
+		Description: truncate(coalesce(sc.Caption, "No caption."), 300),
+		URL:         serverURL,
+		Color:       0x5865F2,
+		Fields: []discordField{
+			{
+				Name:   "Uploaded by",
+				Value:  formatDiscordOwner(u.Username, u.DiscordID),
+				Inline: true,
+			},
+			{
+				Name:   "Screenshot ID",
+				Value:  fmt.Sprintf("`%d`", sc.ID),
+				Inline: true,
+			},
+		},
+		Thumbnail: &discordThumbnail{URL: absoluteURL(sc.ThumbURL)},
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Footer: &discordFooter{
+			Text: footerText("screenshot"),
+		},
+	})
+}
+
+func notifyClaimRejected(cl *ServerClaim) {
+	sendDiscordDM(cl.DiscordID, discordEmbed{
+		Title: "Your claim was not approved",
+		Description: fmt.Sprintf(
+			"The mossai admins did not approve your ownership claim for %s.",
+			coalesce(cl.ServerName, "a listing"),
+		),
+		URL:       buildServerURL(int64(cl.ServerID)),
+		Color:     0xED4245,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// notifyDiscordInviteExpired tells the admins and the listing's owners that
+// its Discord invite stopped working.
+func notifyDiscordInviteExpired(serverID int, serverName, code string, owners []string) {
+	serverURL := buildServerURL(int64(serverID))
+	name := coalesce(serverName, "unnamed server")
+
+	sendAdminWebhook(discordEmbed{
+		Title:       "🔗 Discord invite expired",
+		Description: fmt.Sprintf("The Discord invite on %s no longer resolves.", name),
+		URL:         serverURL,
+		Color:       0xFEE75C,
+		Fields: []discordField{
+			{
+				Name:   "Invite",
+				Value:  fmt.Sprintf("`%s`", code),
+				Inline: true,
+			},
+			{
+				Name:   "Server ID",
+				Value:  fmt.Sprintf("`%d`", serverID),
+				Inline: true,
+			},
+		},
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Footer: &discordFooter{
+			Text: footerText("discord invite"),
+		},
+	})
+
+	for _, id := range owners {
+		sendDiscordDM(id, discordEmbed{
+			Title: "Your Discord invite expired",
+			Description: fmt.Sprintf(
+				"The Discord invite on %s (`%s`) no longer works, so visitors cannot join. "+
+					"Post a new invite from %s/owner.",
+				name, code, getBaseURL(),
+			),
+			URL:       serverURL,
+			Color:     0xFEE75C,
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+func notifyNewAnnouncement(a *Announcement) {
+	fields := []discordField{
+		{
+			Name:   "Posted by",
+			Value:  coalesce(a.AuthorName, "unknown"),
+			Inline: true,
+		},
+		{
+			Name:   "Announcement ID",
+			Value:  fmt.Sprintf("`%d`", a.ID),
+			Inline: true,
+		},
+	}
+	if a.StartsAt != "" {
+		when := a.StartsAt
+		if a.EndsAt != "" {
+			when += " – " + a.EndsAt
+		}
+		fields = append(fields, discordField{
+			Name:   "When",
+			Value:  when,
+			Inline: false,
+		})
+	}
+
+	sendAdminWebhook(discordEmbed{
+		Title:       "📣 New announcement",
+		Description: fmt.Sprintf("**%s** on %s\n%s", a.Title, coalesce(a.ServerName, "unnamed server"), truncate(a.Body, 300)),
+		URL:         buildServerURL(int64(a.ServerID)),
+		Color:       0x5865F2,
+		Fields:      fields,
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+		Footer: &discordFooter{
+			Text: footerText("announcement"),
+		},
+	})
+}
+
+// notifyServerReportThreshold pings the admins when a listing collects
+// enough open abuse reports to need a look.
+func notifyServerReportThreshold(r *ServerReport) {
+	sendAdminWebhook(discordEmbed{
+		Title:       "🚩 Listing reported",
+		Description: fmt.Sprintf("%s has %d open abuse reports.", coalesce(r.ServerName, "unnamed server"), r.OpenReports),
+		URL:         buildServerURL(int64(r.ServerID)),
+		Color:       0xED4245,
+		Fields: []discordField{
+			{
+				Name:   "Latest category",
+				Value:  r.Category,
+				Inline: true,
+			},
+			{
+				Name:   "Reported by",
+				Value:  formatDiscordOwner(r.ReporterName, r.ReporterID),
+				Inline: true,
+			},
+			{
+				Name:   "Details",
+				Value:  coalesce(truncate(r.Details, 500), "none given"),
+				Inline: false,
+			},
+		},
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Footer: &discordFooter{
+			Text: footerText("abuse report"),
+		},
+	})
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v3"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	ogImageWidth  = 1200
+	ogImageHeight = 630
+
+	// ogImageVersion is part of the cache key; bump it when the layout changes.
+	ogImageVersion = "1"
+
+	maxOGImageCacheEntries = 512
+)
+
+var (
+	ogBackground = color.RGBA{0x12, 0x12, 0x1a, 0xff}
+	ogPanel      = color.RGBA{0x1c, 0x1c, 0x28, 0xff}
+	ogAccent     = color.RGBA{0xff, 0x66, 0xaa, 0xff}
+	ogText       = color.RGBA{0xf4, 0xf4, 0xf8, 0xff}
+	ogMuted      = color.RGBA{0xa0, 0xa0, 0xb4, 0xff}
+	ogPill       = color.RGBA{0x2a, 0x2a, 0x3a, 0xff}
+)
+
+// ogFonts holds the faces for one render. Faces cache glyphs and aren't safe
+// for concurrent use, so each render makes its own from the parsed fonts.
+type ogFonts struct {
+	rank  font.Face
+	title font.Face
+	stats font.Face
+	tag   font.Face
+	brand font.Face
+}
+
+var (
+	ogFontsOnce sync.Once
+	ogRegular   *opentype.Font
+	ogBold      *opentype.Font
+	ogFontsErr  error
+)
+
+func loadOGFonts() (*ogFonts, error) {
+	ogFontsOnce.Do(func() {
+		if ogRegular, ogFontsErr = opentype.Parse(goregular.TTF); ogFontsErr != nil {
+			return
+		}
+		ogBold, ogFontsErr = opentype.Parse(gobold.TTF)
+	})
+	if ogFontsErr != nil {
+		return nil, ogFontsErr
+	}
+
+	var err error
+	face := func(f *opentype.Font, size float64) font.Face {
+		if err != nil {
+			return nil
+		}
+		var ff font.Face
+		ff, err = opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+		return ff
+	}
+
+	fonts := &ogFonts{
+		rank:  face(ogBold, 44),
+		title: face(ogBold, 68),
+		stats: face(ogRegular, 36),
+		tag:   face(ogRegular, 26),
+		brand: face(ogBold, 30),
+	}
+	return fonts, err
+}
+
+type ogImageCacheEntry struct {
+	key string
+	png []byte
+}
+
+var ogImageCache = struct {
+	sync.Mutex
+	entries map[int]ogImageCacheEntry
+}{entries: make(map[int]ogImageCacheEntry)}
+
+// serverRank returns the 1-based position of s in the leaderboard order.
+func serverRank(s ServerResult) (int, error) {
+	var rank int
+	err := Database.QueryRow(`
+		SELECT COUNT(*) + 1
+		FROM servers
+		WHERE votes > ? OR (votes = ? AND added > (SELECT added FROM servers WHERE id = ?))
+	`, s.Votes, s.Votes, s.ID).Scan(&rank)
+	return rank, err
+}
+
+// serverRanks returns the leaderboard position of every server, keyed by id,
+// ranked the same way as serverRank.
+func serverRanks() (map[int]int, error) {
+	rows, err := Database.Query(`
+		SELECT id, RANK() OVER (ORDER BY votes DESC, added DESC)
+		FROM servers
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ranks := make(map[int]int, 64)
+	for rows.Next() {
+		var id, rank int
+		if err := rows.Scan(&id, &rank); err != nil {
+			return nil, err
+		}
+		ranks[id] = rank
+	}
+	return ranks, rows.Err()
+}
+
+// ogImageKey hashes everything that is drawn on the card.
+func ogImageKey(s ServerResult, rank int) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		ogImageVersion,
+		strconv.Itoa(s.ID),
+		s.ServerName,
+		strconv.Itoa(rank),
+		strconv.Itoa(s.Votes),
+		strconv.Itoa(s.Online),
+		s.Tags,
+		serverLogo(s),
+	}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+func serverOGImageURL(id int) string {
+	return fmt.Sprintf("/servers/%d/og.png", id)
+}
+
+func serverOGImageHandler(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id <= 0 {
+		return c.Status(404).SendString("server not found")
+	}
+
+	s, err := scanServerResult(Database.QueryRow(serverResultSelect+`WHERE s.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return c.Status(404).SendString("server not found")
+	}
+	if err != nil {
+		log.Println("serverOGImageHandler:", err)
+		return c.Status(500).SendString("internal error")
+	}
+
+	rank, err := serverRank(s)
+	if err != nil {
+		log.Println("serverOGImageHandler rank:", err)
+		return c.Status(500).SendString("internal error")
+	}
+
+	key := ogImageKey(s, rank)
+	etag := `"` + key[:32] + `"`
+	c.Set("ETag", etag)
+	c.Set("Cache-Control", "public, max-age=600")
+
+	if c.Get("If-None-Match") == etag {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	ogImageCache.Lock()
+	entry, ok := ogImageCache.entries[id]
+	ogImageCache.Unlock()
+
+	if !ok || entry.key != key {
+		ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+		data, err := renderServerOGImage(ctx, s, rank)
+		cancel()
+		if err != nil {
+			log.Println("renderServerOGImage:", err)
+			return c.Status(500).SendString("failed to render image")
+		}
+		entry = ogImageCacheEntry{key: key, png: data}
+
+		ogImageCache.Lock()
+		if len(ogImageCache.entries) >= maxOGImageCacheEntries {
+			for k := range ogImageCache.entries {
+				delete(ogImageCache.entries, k)
+				break
+			}
+		}
+		ogImageCache.entries[id] = entry
+		ogImageCache.Unlock()
+	}
+
+	c.Type("png")
+	return c.Send(entry.png)
+}
+
+func renderServerOGImage(ctx context.Context, s ServerResult, rank int) ([]byte, error) {
+	fonts, err := loadOGFonts()
+	if err != nil {
+		return nil, err
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, ogImageWidth, ogImageHeight))
+	fillRect(img, img.Bounds(), ogBackground)
+	fillRect(img, image.Rect(0, 0, 14, ogImageHeight), ogAccent)
+
+	// Logo
+	logoBox := image.Rect(80, 150, 340, 410)
+	fillRect(img, logoBox, ogPanel)
+	if logo, err := loadLogoImage(ctx, serverLogo(s)); err == nil {
+		drawFitted(img, logoBox.Inset(10), logo)
+	} else {
+		initial, _ := utf8.DecodeRuneInString(strings.ToUpper(s.ServerName))
+		if initial != utf8.RuneError {
+			str := string(initial)
+			w := font.MeasureString(fonts.title, str).Ceil()
+			drawText(img, fonts.title, logoBox.Min.X+(logoBox.Dx()-w)/2, logoBox.Min.Y+logoBox.Dy()/2+24, ogAccent, str)
+		}
+	}
+
+	textX := 390
+	maxTextWidth := ogImageWidth - textX - 70
+
+	drawText(img, fonts.rank, textX, 200, ogAccent, "#"+strconv.Itoa(rank))
+	drawText(img, fonts.title, textX, 280, ogText, fitText(fonts.title, s.ServerName, maxTextWidth))
+
+	stats := fmt.Sprintf("%s votes  ·  %s online", formatCount(s.Votes), formatCount(s.Online))
+	drawText(img, fonts.stats, textX, 345, ogMuted, stats)
+
+	// Tags
+	x, y := textX, 395
+	for _, tag := range splitCSV(s.Tags) {
+		tag = fitText(fonts.tag, tag, 260)
+		w := font.MeasureString(fonts.tag, tag).Ceil()
+		if x+w+28 > ogImageWidth-70 {
+			break
+		}
+		fillRect(img, image.Rect(x, y, x+w+28, y+44), ogPill)
+		drawText(img, fonts.tag, x+14, y+31, ogText, tag)
+		x += w + 28 + 12
+	}
+
+	drawText(img, fonts.brand, 80, 560, ogAccent, "mossai")
+	drawText(img, fonts.stats, 200, 560, ogMuted, "osu! private servers")
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func fillRect(dst draw.Image, r image.Rectangle, c color.Color) {
+	draw.Draw(dst, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+func drawText(dst draw.Image, face font.Face, x, y int, c color.Color, s string) {
+	d := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(s)
+}
+
+// drawFitted scales src to fit inside r, keeping its aspect ratio.
+func drawFitted(dst draw.Image, r image.Rectangle, src image.Image) {
+	sb := src.Bounds()
+	if sb.Dx() == 0 || sb.Dy() == 0 {
+		return
+	}
+	scale := min(float64(r.Dx())/float64(sb.Dx()), float64(r.Dy())/float64(sb.Dy()))
+	w := int(float64(sb.Dx()) * scale)
+	h := int(float64(sb.Dy()) * scale)
+	x := r.Min.X + (r.Dx()-w)/2
+	y := r.Min.Y + (r.Dy()-h)/2
+	draw.CatmullRom.Scale(dst, image.Rect(x, y, x+w, y+h), src, sb, draw.Over, nil)
+}
+
+// fitText shortens s with an ellipsis until it is at most maxWidth pixels.
+func fitText(face font.Face, s string, maxWidth int) string {
+	if font.MeasureString(face, s).Ceil() <= maxWidth {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 1 {
+		runes = runes[:len(runes)-1]
+		candidate := strings.TrimSpace(string(runes)) + "…"
+		if font.MeasureString(face, candidate).Ceil() <= maxWidth {
+			return candidate
+		}
+	}
+	return string(runes)
+}
+
+func formatCount(n int) string {
+	s := strconv.Itoa(n)
+	if n < 0 {
+		return s
+	}
+	var out []byte
+	for i := range len(s) {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			out = append(out, ',')
+		}
+		out = append(out, s[i])
+	}
+	return string(out)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestServerRanksMatchServerRank(t *testing.T) {
+	newTestServer(t)
+
+	for i, votes := range []int{5, 20, 5, 0, 20} {
+		insertTestServer(t, "Server "+string(rune('A'+i)), "", votes)
+	}
+
+	ranks, err := serverRanks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	servers, err := queryServers("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranks) != len(servers) {
+		t.Fatalf("serverRanks has %d entries, want %d", len(ranks), len(servers))
+	}
+	for _, s := range servers {
+		want, err := serverRank(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ranks[s.ID] != want {
+			t.Errorf("server %d: serverRanks = %d, serverRank = %d", s.ID, ranks[s.ID], want)
+		}
+	}
+}
+
+func TestRenderServerOGImageConcurrently(t *testing.T) {
+	newTestServer(t)
+
+	var servers []ServerResult
+	for i, name := range []string{"Alpha", "Bravo", "Charlie", "Delta"} {
+		id := insertTestServer(t, name, "", i)
+		s, err := queryServers("WHERE s.id = ?", id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		servers = append(servers, s[0])
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*len(servers))
+	for round := 0; round < 2; round++ {
+		for i, s := range servers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := renderServerOGImage(context.Background(), s, i+1)
+				errs <- err
+			}()
+		}
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	roleOwner   = "owner"
+	roleManager = "manager"
+
+	inviteKindMember   = "invite"
+	inviteKindTransfer = "transfer"
+)
+
+// serverRole returns discordID's role on a server, or "" when they are not on
+// its team.
+func serverRole(discordID string, serverID int) (string, error) {
+	var role string
+	err := Database.QueryRow(`
+		SELECT role
+		FROM server_owners
+		WHERE server_id = ? AND discord_id = ?
+	`, serverID, discordID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+func loadServerOwners(serverID int) ([]ServerOwner, error) {
+	rows, err := Database.Query(`
+		SELECT discord_id, username, role, added_at
+		FROM server_owners
+		WHERE server_id = ?
+		ORDER BY role = 'owner' DESC, added_at, discord_id
+	`, serverID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	owners := make([]ServerOwner, 0, 4)
+	for rows.Next() {
+		var o ServerOwner
+		if err := rows.Scan(&o.DiscordID, &o.Username, &o.Role, &o.AddedAt); err != nil {
+			return nil, err
+		}
+		owners = append(owners, o)
+	}
+	return owners, rows.Err()
+}
+
+const ownerInviteSelect = `
+	SELECT i.id,
+	       i.server_id,
+	       COALESCE(s.server_name, ''),
+	       i.discord_id,
+	       i.role,
+	       i.kind,
+	       i.invited_by,
+	       i.status,
+	       i.created_at
+	FROM server_invites i
+	JOIN servers s
+	  ON s.id = i.server_id
+`
+
+func queryOwnerInvites(where string, args ...any) ([]OwnerInvite, error) {
+	rows, err := Database.Query(ownerInviteSelect+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invites := make([]OwnerInvite, 0, 4)
+	for rows.Next() {
+		var inv OwnerInvite
+		if err := rows.Scan(
+			&inv.ID,
+			&inv.ServerID,
+			&inv.ServerName,
+			&inv.DiscordID,
+			&inv.Role,
+			&inv.Kind,
+			&inv.InvitedBy,
+			&inv.Status,
+			&inv.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		invites = append(invites, inv)
+	}
+	return invites, rows.Err()
+}
+
+func validDiscordID(id string) bool {
+	_, err := strconv.ParseUint(id, 10, 64)
+	return err == nil && len(id) >= 15 && len(id) <= 20
+}
+
+// requireServerRole loads the session user and checks they have one of roles
+// on the server in the :id route param.
+func requireServerRole(c fiber.Ctx, roles ...string) (*SessionUser, int, error) {
+	u, ok := getSessionUser(c)
+	if !ok {
+		return nil, 0, fiber.NewError(fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id <= 0 {
+		return nil, 0, fiber.NewError(fiber.StatusBadRequest, "invalid server id")
+	}
+
+	role, err := serverRole(u.DiscordID, id)
+	if err != nil {
+		return nil, 0, fiber.NewError(fiber.StatusInternalServerError, "failed to check ownership")
+	}
+	if role == "" {
+		return nil, 0, fiber.NewError(fiber.StatusNotFound, "server not found")
+	}
+	if !containsString(roles, role) {
+		return nil, 0, fiber.NewError(fiber.StatusForbidden, "only owners can do this")
+	}
+	return u, id, nil
+}
+
+func createOwnerInvite(c fiber.Ctx, kind string) error {
+	u, id, err := requireServerRole(c, roleOwner)
+	if err != nil {
+		return err
+	}
+
+	type invitePayload struct {
+		DiscordID string `json:"discord_id"`
+		Role      string `json:"role"`
+	}
+
+	var payload invitePayload
+	if err := c.Bind().Body(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid json body")
+	}
+
+	payload.DiscordID = strings.TrimSpace(payload.DiscordID)
+	if !validDiscordID(payload.DiscordID) {
+		return c.Status(fiber.StatusBadRequest).SendString("discord_id must be a Discord user ID")
+	}
+	if payload.DiscordID == u.DiscordID {
+		return c.Status(fiber.StatusBadRequest).SendString("you are already on this server's team")
+	}
+
+	role := strings.TrimSpace(payload.Role)
+	if kind == inviteKindTransfer {
+		role = roleOwner
+	}
+	if role != roleOwner && role != roleManager {
+		return c.Status(fiber.StatusBadRequest).SendString("role must be owner or manager")
+	}
+
+	current, err := serverRole(payload.DiscordID, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to check team")
+	}
+	if current == roleOwner || (current == roleManager && role == roleManager) {
+		return c.Status(fiber.StatusConflict).SendString("that user already has this role")
+	}
+
+	tx, err := Database.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	// A newer invite for the same person replaces the older one.
+	if _, err := tx.Exec(`
+		UPDATE server_invites
+		SET status = 'cancelled', responded_at = datetime('now')
+		WHERE server_id = ? AND discord_id = ? AND status = 'pending'
+	`, id, payload.DiscordID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to update invites")
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO server_invites (server_id, discord_id, role, kind, invited_by, status, created_at)
+		VALUES (?, ?, ?, ?, ?, 'pending', datetime('now'))
+	`, id, payload.DiscordID, role, kind, u.DiscordID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to create invite")
+	}
+
+	inviteID, err := res.LastInsertId()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to get invite id")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to commit transaction")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"ok":        true,
+		"invite_id": inviteID,
+	})
+}
+
+func postOwnerInviteHandler(c fiber.Ctx) error {
+	return createOwnerInvite(c, inviteKindMember)
+}
+
+func postOwnerTransferHandler(c fiber.Ctx) error {
+	return createOwnerInvite(c, inviteKindTransfer)
+}
+
+func getOwnerInvitesHandler(c fiber.Ctx) error {
+	u, ok := getSessionUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).SendString("unauthorized")
+	}
+
+	invites, err := queryOwnerInvites(`
+		WHERE i.discord_id = ? AND i.status = 'pending'
+		ORDER BY i.created_at DESC
+	`, u.DiscordID)
+	if err != nil {
+		log.Println("getOwnerInvitesHandler:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load invites")
+	}
+
+	return c.JSON(fiber.Map{"invites": invites})
+}
+
+func postAcceptInviteHandler(c fiber.Ctx) error {
+	u, ok := getSessionUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).SendString("unauthorized")
+	}
+
+	invites, err := queryOwnerInvites(`
+		WHERE i.id = ? AND i.discord_id = ? AND i.status = 'pending'
+	`, c.Params("id"), u.DiscordID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load invite")
+	}
+	if len(invites) == 0 {
+		return c.Status(fiber.StatusNotFound).SendString("invite not found or already answered")
+	}
+	inv := invites[0]
+
+	inviterRole, err := serverRole(inv.InvitedBy, inv.ServerID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to check invite")
+	}
+	if inviterRole != roleOwner {
+		return c.Status(fiber.StatusConflict).SendString("this invite is no longer valid")
+	}
+
+	tx, err := Database.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE server_invites
+		SET status = 'accepted', responded_at = datetime('now')
+		WHERE id = ? AND status = 'pending'
+	`, inv.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to update invite")
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return c.Status(fiber.StatusNotFound).SendString("invite not found or already answered")
+	}
+
+	// Accepting never lowers an existing role.
+	if _, err := tx.Exec(`
+		INSERT INTO server_owners (server_id, discord_id, username, role, added_at)
+		VALUES (?, ?, ?, ?, datetime('now'))
+		ON CONFLICT (server_id, discord_id) DO UPDATE SET
+			username = excluded.username,
+			role     = CASE WHEN server_owners.role = 'owner' THEN 'owner' ELSE excluded.role END
+	`, inv.ServerID, u.DiscordID, u.Username, inv.Role); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to add owner")
+	}
+
+	if inv.Kind == inviteKindTransfer {
+		if _, err := tx.Exec(`
+			UPDATE server_owners
+			SET role = 'manager'
+			WHERE server_id = ? AND discord_id = ? AND role = 'owner'
+		`, inv.ServerID, inv.InvitedBy); err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("failed to transfer ownership")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to commit transaction")
+	}
+
+	return c.JSON(fiber.Map{"ok": true, "server_id": inv.ServerID})
+}
+
+func postDeclineInviteHandler(c fiber.Ctx) error {
+	u, ok := getSessionUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).SendString("unauthorized")
+	}
+
+	res, err := Database.Exec(`
+		UPDATE server_invites
+		SET status = 'declined', responded_at = datetime('now')
+		WHERE id = ? AND discord_id = ? AND status = 'pending'
+	`, c.Params("id"), u.DiscordID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to update invite")
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return c.Status(fiber.StatusNotFound).SendString("invite not found or already answered")
+	}
+
+	return c.JSON(fiber.Map{"ok": true})
+}
+
+func postCancelInviteHandler(c fiber.Ctx) error {
+	_, id, err := requireServerRole(c, roleOwner)
+	if err != nil {
+		return err
+	}
+
+	res, err := Database.Exec(`
+		UPDATE server_invites
+		SET status = 'cancelled', responded_at = datetime('now')
+		WHERE id = ? AND server_id = ? AND status = 'pending'
+	`, c.Params("inviteId"), id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to update invite")
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return c.Status(fiber.StatusNotFound).SendString("invite not found or already answered")
+	}
+
+	return c.JSON(fiber.Map{"ok": true})
+}
+
+// postRemoveOwnerHandler removes someone from a server's team. Owners can
+// remove anyone and everyone can remove themselves, but the last owner
+// cannot leave.
+func postRemoveOwnerHandler(c fiber.Ctx) error {
+	u, id, err := requireServerRole(c, roleOwner, roleManager)
+	if err != nil {
+		return err
+	}
+
+	target := strings.TrimSpace(c.Params("discordId"))
+	if target != u.DiscordID {
+		role, err := serverRole(u.DiscordID, id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("failed to check ownership")
+		}
+		if role != roleOwner {
+			return c.Status(fiber.StatusForbidden).SendString("only owners can do this")
+		}
+	}
+
+	tx, err := Database.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		DELETE FROM server_owners
+		WHERE server_id = ? AND discord_id = ?
+	`, id, target)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to remove owner")
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return c.Status(fiber.StatusNotFound).SendString("that user is not on this server's team")
+	}
+
+	var owners int
+	if err := tx.QueryRow(`
+		SELECT COUNT(*)
+		FROM server_owners
+		WHERE server_id = ? AND role = 'owner'
+	`, id).Scan(&owners); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to count owners")
+	}
+	if owners == 0 {
+		return c.Status(fiber.StatusConflict).SendString("a server needs at least one owner; transfer ownership first")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to commit transaction")
+	}
+
+	return c.JSON(fiber.Map{"ok": true})
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"html/template"
+	"log"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const siteDescription = "Browse community osu! private servers and compare them by player votes, activity and features."
+
+type pageMeta struct {
+	Title       string
+	Description string
+	Canonical   string
+	Image       string
+	ImageWidth  int
+	ImageHeight int
+	Type        string
+	TwitterCard string
+	NoIndex     bool
+}
+
+type serverCard struct {
+	Rank int
+	ServerResult
+}
+
+// rankedCards pairs each server with its overall leaderboard rank, so a card
+// shows the same rank on filtered, re-sorted and tag pages as on the index.
+func rankedCards(servers []ServerResult) ([]serverCard, error) {
+	ranks, err := serverRanks()
+	if err != nil {
+		return nil, err
+	}
+
+	cards := make([]serverCard, len(servers))
+	for i, s := range servers {
+		cards[i] = serverCard{Rank: ranks[s.ID], ServerResult: s}
+	}
+	return cards, nil
+}
+
+var pageTemplates map[string]*template.Template
+
+var templateFuncs = template.FuncMap{
+	"formatDate":   formatDisplayDate,
+	"formatTime":   formatDisplayTime,
+	"splitTags":    splitCSV,
+	"serverLogo":   serverLogo,
+	"logoVariant":  logoVariantURL,
+	"tagPath":      tagPath,
+	"featureLabel": featureLabel,
+	"linkLabel":    linkLabel,
+	"markdown":     renderMarkdown,
+	"stars":        stars,
+	"isTrue":       func(b *bool) bool { return b != nil && *b },
+	"rankClass": func(rank int) string {
+		if rank >= 1 && rank <= 3 {
+			return "server-rank-" + strconv.Itoa(rank)
+		}
+		return ""
+	},
+}
+
+// LoadTemplates parses every page in templates/ together with the shared
+// layout partials.
+func LoadTemplates() {
+	pages, err := filepath.Glob("./templates/*.html")
+	if err != nil {
+		panic(err)
+	}
+
+	pageTemplates = make(map[string]*template.Template, len(pages))
+	for _, page := range pages {
+		name := filepath.Base(page)
+		if name == "layout.html" {
+			continue
+		}
+		t := template.Must(
+			template.New(name).Funcs(templateFuncs).ParseFiles("./templates/layout.html", page),
+		)
+		pageTemplates[name] = t
+	}
+}
+
+func renderPage(c fiber.Ctx, status int, name string, data any) error {
+	t, ok := pageTemplates[name]
+	if !ok {
+		log.Println("renderPage: unknown template", name)
+		return c.Status(500).SendString("internal error")
+	}
+
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, name, data); err != nil {
+		log.Println("renderPage", name+":", err)
+		return c.Status(500).SendString("internal error")
+	}
+
+	c.Type("html", "utf-8")
+	return c.Status(status).Send(buf.Bytes())
+}
+
+func formatDisplayDate(value string) string {
+	if strings.TrimSpace(value) == "" {
+		return "–"
+	}
+	if t, ok := parseDBTime(value); ok {
+		return t.Format("Jan 02, 2006")
+	}
+	return value
+}
+
+// formatDisplayTime is formatDisplayDate with the time of day, for event
+// schedules.
+func formatDisplayTime(value string) string {
+	if t, ok := parseDBTime(value); ok {
+		return t.UTC().Format("Jan 02, 2006 15:04 UTC")
+	}
+	return formatDisplayDate(value)
+}
+
+// serverLogo returns the logo to show for s. Remote logos are only shown
+// once a copy is served from our origin.
+func serverLogo(s ServerResult) string {
+	return s.Logo
+}
+
+// absoluteURL turns a site-relative path into an absolute URL on the public
+// base URL; absolute URLs are returned unchanged.
+func absoluteURL(u string) string {
+	if u == "" || strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") {
+		return u
+	}
+	return getBaseURL() + "/" + strings.TrimLeft(u, "/")
+}
+
+func defaultPageMeta(title, path string) pageMeta {
+	return pageMeta{
+		Title:       title,
+		Description: siteDescription,
+		Canonical:   getBaseURL() + path,
+		Type:        "website",
+		TwitterCard: "summary",
+	}
+}
+
+func serverPageMeta(s ServerResult) pageMeta {
+	meta := defaultPageMeta(s.ServerName+" - osu! private server | mossai", fmt.Sprintf("/servers/%d", s.ID))
+	meta.Type = "article"
+
+	if s.Description != "" {
+		meta.Description = truncate(s.Description, 160)
+	} else {
+		meta.Description = truncate(fmt.Sprintf(
+			"%s is an osu! private server listed on mossai with %d votes.",
+			s.ServerName, s.Votes,
+		), 160)
+	}
+
+	meta.Image = absoluteURL(serverOGImageURL(s.ID))
+	meta.ImageWidth = ogImageWidth
+	meta.ImageHeight = ogImageHeight
+	meta.TwitterCard = "summary_large_image"
+
+	return meta
+}
+
+func indexPageHandler(c fiber.Ctx) error {
+	where, args, err := featureFilterClauses(c)
+	if err != nil {
+		return c.Redirect().Status(fiber.StatusFound).To("/")
+	}
+	order, err := leaderboardOrder(c)
+	if err != nil {
+		return c.Redirect().Status(fiber.StatusFound).To("/")
+	}
+	sorted := c.Query("sort") != "" && c.Query("sort") != "votes"
+
+	clause := ""
+	if len(where) > 0 {
+		clause = "WHERE " + strings.Join(where, " AND ") + " "
+	}
+
+	servers, err := queryServers(clause+order, args...)
+	if err != nil {
+		log.Println("indexPageHandler:", err)
+		return c.Status(500).SendString("internal error")
+	}
+
+	cards, err := rankedCards(servers)
+	if err != nil {
+		log.Println("indexPageHandler:", err)
+		return c.Status(500).SendString("internal error")
+	}
+
+	meta := defaultPageMeta("mossai [osu! private servers]", "/")
+	meta.NoIndex = len(where) > 0 || sorted
+
+	return renderPage(c, fiber.StatusOK, "index.html", fiber.Map{
+		"Meta":     meta,
+		"Servers":  cards,
+		"Filtered": len(where) > 0 || sorted,
+		"Filter": fiber.Map{
+			"Sort":             c.Query("sort"),
+			"Mode":             c.Query("mode"),
+			"Leaderboard":      c.Query("leaderboard"),
+			"PPSystem":         c.Query("pp_system"),
+			"Registration":     c.Query("registration"),
+			"Client":           c.Query("client"),
+			"UnrankedRankable": c.Query("unranked_rankable"),
+		},
+		"Features": featureOptions,
+	})
+}
+
+func serverPageHandler(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id <= 0 {
+		return renderServerNotFound(c)
+	}
+
+	s, err := scanServerResult(Database.QueryRow(serverResultSelect+`WHERE s.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return renderServerNotFound(c)
+	}
+	if err != nil {
+		log.Println("serverPageHandler:", err)
+		return c.Status(500).SendString("internal error")
+	}
+	if s.Screenshots, err = publicScreenshots(s.ID); err != nil {
+		log.Println("serverPageHandler:", err)
+		return c.Status(500).SendString("internal error")
+	}
+	if s.Announcements, err = publicAnnouncements(s.ID); err != nil {
+		log.Println("serverPageHandler:", err)
+		return c.Status(500).SendString("internal error")
+	}
+	if s.Reviews, err = publicReviews(s.ID); err != nil {
+		log.Println("serverPageHandler:", err)
+		return c.Status(500).SendString("internal error")
+	}
+
+	return renderPage(c, fiber.StatusOK, "server.html", fiber.Map{
+		"Meta":             serverPageMeta(s),
+		"Server":           s,
+		"LongDescription":  renderMarkdown(s.LongDescription),
+		"ReportCategories": reportCategories,
+	})
+}
+
+func renderServerNotFound(c fiber.Ctx) error {
+	meta := defaultPageMeta("Server not found | mossai", c.Path())
+	meta.NoIndex = true
+
+	return renderPage(c, fiber.StatusNotFound, "server.html", fiber.Map{
+		"Meta":   meta,
+		"Server": nil,
+	})
+}
+
+func listPageHandler(c fiber.Ctx) error {
+	meta := defaultPageMeta("List your osu! private server | mossai", "/list")
+	meta.Description = "Submit your osu! private server to mossai. Listings are reviewed by an admin before they go live."
+
+	// Submissions are tied to the Discord account, so the form is only
+	// shown to signed-in visitors.
+	u, _ := getSessionUser(c)
+
+	return renderPage(c, fiber.StatusOK, "list.html", fiber.Map{
+		"Meta":      meta,
+		"User":      u,
+		"Features":  featureOptions,
+		"LinkTypes": linkTypeOptions(),
+	})
+}
+
+func tagPageHandler(c fiber.Ctx) error {
+	tag, err := url.PathUnescape(c.Params("tag"))
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if err != nil || tag == "" || len(tag) > 50 {
+		return c.Redirect().Status(fiber.StatusFound).To("/")
+	}
+
+	servers, err := queryServers(`WHERE `+tagFilterClause+` ORDER BY s.votes DESC, s.added DESC`, tagFilterArg(tag))
+	if err != nil {
+		log.Println("tagPageHandler:", err)
+		return c.Status(500).SendString("internal error")
+	}
+
+	cards, err := rankedCards(servers)
+	if err != nil {
+		log.Println("tagPageHandler:", err)
+		return c.Status(500).SendString("internal error")
+	}
+
+	meta := defaultPageMeta(fmt.Sprintf("%s osu! private servers | mossai", tag), tagPath(tag))
+	meta.Description = fmt.Sprintf("osu! private servers tagged %q, ranked by player votes on mossai.", tag)
+	if len(servers) == 0 {
+		meta.NoIndex = true
+	}
+
+	status := fiber.StatusOK
+	if len(servers) == 0 {
+		status = fiber.StatusNotFound
+	}
+
+	return renderPage(c, status, "tag.html", fiber.Map{
+		"Meta":    meta,
+		"Tag":     tag,
+		"Servers": cards,
+	})
+}
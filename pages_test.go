@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestTagPageShowsOverallRank(t *testing.T) {
+	srv := newTestServer(t)
+
+	insertTestServer(t, "Leader", "https://leader.example", 50)
+	tagged := insertTestServer(t, "Runner Up", "https://runnerup.example", 10)
+	if _, err := Database.Exec(`UPDATE servers SET tags = 'mania' WHERE id = ?`, tagged); err != nil {
+		t.Fatal(err)
+	}
+
+	status, body := testRequest(t, srv, http.MethodGet, "/tags/mania", "", nil)
+	if status != http.StatusOK {
+		t.Fatalf("tag page: status %d", status)
+	}
+	if !strings.Contains(string(body), ">#2</span>") || strings.Contains(string(body), ">#1</span>") {
+		t.Error("tag page does not show Runner Up at its overall rank #2")
+	}
+}
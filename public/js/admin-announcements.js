@@ -0,0 +1,78 @@
+import { formatDate, escapeHtml } from "./dom-utils.js";
+
+export function initAdminAnnouncements() {
+  const root = document.getElementById("admin-announcements-root");
+  if (!root) return;
+
+  const tableBody = document.getElementById("admin-announcements-table-body");
+  const emptyNotice = document.getElementById("admin-announcements-empty");
+  const errorNotice = document.getElementById("admin-announcements-error");
+
+  if (!tableBody || !emptyNotice || !errorNotice) return;
+
+  function renderTable(posts) {
+    tableBody.innerHTML = "";
+    emptyNotice.classList.toggle("hidden", posts.length > 0);
+
+    posts.forEach((a) => {
+      const tr = document.createElement("tr");
+      tr.innerHTML = `
+        <td>
+          <div class="admin-requests-server-name">
+            <a href="/servers/${encodeURIComponent(a.server_id)}">${escapeHtml(
+              a.server_name || ""
+            )}</a>
+          </div>
+        </td>
+        <td>
+          <div class="admin-requests-server-name">${escapeHtml(a.title)}</div>
+          <div class="admin-requests-description">${escapeHtml(a.body)}</div>
+        </td>
+        <td>${escapeHtml(a.author_name || "")}</td>
+        <td>${formatDate(a.created_at)}</td>
+        <td><div class="admin-requests-actions"></div></td>
+      `;
+
+      const btn = document.createElement("button");
+      btn.type = "button";
+      btn.className = a.hidden
+        ? "admin-requests-btn admin-requests-btn-approve"
+        : "admin-requests-btn admin-requests-btn-reject";
+      btn.textContent = a.hidden ? "Unhide" : "Hide";
+      btn.addEventListener("click", () => mutateAnnouncement(a.id, a.hidden ? "unhide" : "hide"));
+      tr.querySelector(".admin-requests-actions").appendChild(btn);
+
+      tableBody.appendChild(tr);
+    });
+  }
+
+  async function loadAnnouncements() {
+    errorNotice.classList.add("hidden");
+    try {
+      const res = await fetch("/api/admin/announcements", {
+        credentials: "include",
+        headers: { Accept: "application/json" },
+      });
+      if (!res.ok) throw new Error("HTTP " + res.status);
+      const data = await res.json();
+      renderTable(Array.isArray(data.announcements) ? data.announcements : []);
+    } catch (_err) {
+      errorNotice.classList.remove("hidden");
+    }
+  }
+
+  async function mutateAnnouncement(id, action) {
+    const res = await fetch(
+      `/api/admin/announcements/${encodeURIComponent(id)}/${action}`,
+      { method: "POST", credentials: "include" }
+    );
+    if (!res.ok) {
+      errorNotice.textContent = (await res.text()) || "Could not update the announcement.";
+      errorNotice.classList.remove("hidden");
+      return;
+    }
+    loadAnnouncements();
+  }
+
+  loadAnnouncements();
+}
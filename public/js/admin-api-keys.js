@@ -0,0 +1,106 @@
+import { formatDate, escapeHtml } from "./dom-utils.js";
+
+export function initAdminApiKeys() {
+  const root = document.getElementById("admin-api-keys-root");
+  if (!root) return;
+
+  const tableBody = document.getElementById("admin-api-keys-table-body");
+  const emptyNotice = document.getElementById("admin-api-keys-empty");
+  const errorNotice = document.getElementById("admin-api-keys-error");
+
+  if (!tableBody || !emptyNotice || !errorNotice) return;
+
+  function renderTable(keys, tiers) {
+    tableBody.innerHTML = "";
+    emptyNotice.classList.toggle("hidden", keys.length > 0);
+
+    keys.forEach((key) => {
+      const tr = document.createElement("tr");
+      const revoked = Boolean(key.revoked_at);
+      if (revoked) tr.classList.add("admin-requests-row-removed");
+
+      tr.innerHTML = `
+        <td>
+          <div class="admin-requests-server-name">${escapeHtml(key.name || "")}</div>
+          <div class="admin-requests-description"><code>${escapeHtml(
+            key.prefix || ""
+          )}…</code></div>
+        </td>
+        <td>${escapeHtml(key.owner_discord || "")}</td>
+        <td>${escapeHtml((key.scopes || []).join(", "))}</td>
+        <td></td>
+        <td>${key.requests_today ?? 0}</td>
+        <td>${key.requests_last_7_days ?? 0}</td>
+        <td>${key.request_count ?? 0}</td>
+        <td>${key.last_used_at ? formatDate(key.last_used_at) : "never"}</td>
+        <td></td>
+      `;
+
+      const cells = tr.querySelectorAll("td");
+      const tierCell = cells[3];
+      const actionsCell = cells[cells.length - 1];
+
+      if (revoked) {
+        tierCell.textContent = key.tier || "";
+        actionsCell.textContent = "revoked";
+      } else {
+        const select = document.createElement("select");
+        tiers.forEach((tier) => {
+          const opt = document.createElement("option");
+          opt.value = tier;
+          opt.textContent = tier;
+          opt.selected = tier === key.tier;
+          select.appendChild(opt);
+        });
+        select.addEventListener("change", () =>
+          mutateKey(key.id, "tier", { tier: select.value })
+        );
+        tierCell.appendChild(select);
+
+        const revokeBtn = document.createElement("button");
+        revokeBtn.type = "button";
+        revokeBtn.className = "admin-requests-btn admin-requests-btn-reject";
+        revokeBtn.textContent = "Revoke";
+        revokeBtn.addEventListener("click", () => {
+          if (confirm(`Revoke "${key.name}"?`)) mutateKey(key.id, "revoke");
+        });
+        actionsCell.appendChild(revokeBtn);
+      }
+
+      tableBody.appendChild(tr);
+    });
+  }
+
+  async function loadKeys() {
+    errorNotice.classList.add("hidden");
+    try {
+      const res = await fetch("/api/admin/api-keys", {
+        credentials: "include",
+        headers: { Accept: "application/json" },
+      });
+      if (!res.ok) throw new Error("HTTP " + res.status);
+      const data = await res.json();
+      renderTable(data.keys || [], data.tiers || []);
+    } catch (_err) {
+      errorNotice.classList.remove("hidden");
+    }
+  }
+
+  function mutateKey(id, action, body) {
+    fetch(`/api/admin/api-keys/${encodeURIComponent(id)}/${action}`, {
+      method: "POST",
+      credentials: "include",
+      headers: body ? { "Content-Type": "application/json" } : {},
+      body: body ? JSON.stringify(body) : undefined,
+    })
+      .then((res) => {
+        if (!res.ok) throw new Error("bad status");
+        loadKeys();
+      })
+      .catch(() => {
+        errorNotice.classList.remove("hidden");
+      });
+  }
+
+  loadKeys();
+}
@@ -0,0 +1,83 @@
+import { formatDate, escapeHtml } from "./dom-utils.js";
+
+export function initAdminAudit() {
+  const root = document.getElementById("admin-audit-root");
+  if (!root) return;
+
+  const tableBody = document.getElementById("admin-audit-table-body");
+  const emptyNotice = document.getElementById("admin-audit-empty");
+  const errorNotice = document.getElementById("admin-audit-error");
+  const filters = document.getElementById("admin-audit-filters");
+  const moreBtn = document.getElementById("admin-audit-more");
+
+  if (!tableBody || !emptyNotice || !errorNotice || !filters || !moreBtn) return;
+
+  const pageSize = 50;
+  let lastId = 0;
+
+  function snapshot(value) {
+    if (value === undefined || value === null) return "";
+    return `<pre class="admin-audit-snapshot">${escapeHtml(JSON.stringify(value, null, 2))}</pre>`;
+  }
+
+  function appendRows(entries) {
+    entries.forEach((e) => {
+      const tr = document.createElement("tr");
+      tr.innerHTML = `
+        <td>${formatDate(e.created_at)}</td>
+        <td><code>${escapeHtml(e.actor_id)}</code><div class="admin-requests-description">${escapeHtml(
+          e.ip
+        )}</div></td>
+        <td><span class="admin-requests-tag">${escapeHtml(e.action)}</span></td>
+        <td>${escapeHtml(e.target_type)} #${escapeHtml(e.target_id)}</td>
+        <td>
+          <details>
+            <summary>Before and after</summary>
+            ${snapshot(e.before) || "<em>nothing before</em>"}
+            ${snapshot(e.after) || "<em>nothing after</em>"}
+          </details>
+        </td>
+      `;
+      tableBody.appendChild(tr);
+    });
+  }
+
+  async function loadEntries(reset) {
+    errorNotice.classList.add("hidden");
+    if (reset) {
+      tableBody.innerHTML = "";
+      lastId = 0;
+    }
+
+    const params = new URLSearchParams({ limit: String(pageSize) });
+    ["actor", "action", "target_type", "target_id"].forEach((name) => {
+      const value = filters.elements[name].value.trim();
+      if (value) params.set(name, value);
+    });
+    if (lastId) params.set("before_id", String(lastId));
+
+    try {
+      const res = await fetch(`/api/admin/audit-log?${params}`, {
+        credentials: "include",
+        headers: { Accept: "application/json" },
+      });
+      if (!res.ok) throw new Error("HTTP " + res.status);
+      const data = await res.json();
+      const entries = Array.isArray(data.entries) ? data.entries : [];
+      appendRows(entries);
+      if (entries.length) lastId = entries[entries.length - 1].id;
+      emptyNotice.classList.toggle("hidden", tableBody.children.length > 0);
+      moreBtn.classList.toggle("hidden", entries.length < pageSize);
+    } catch (_err) {
+      errorNotice.classList.remove("hidden");
+    }
+  }
+
+  filters.addEventListener("submit", (e) => {
+    e.preventDefault();
+    loadEntries(true);
+  });
+  moreBtn.addEventListener("click", () => loadEntries(false));
+
+  loadEntries(true);
+}
@@ -0,0 +1,99 @@
+import { formatDate, escapeHtml } from "./dom-utils.js";
+
+export function initAdminClaims() {
+  const root = document.getElementById("admin-claims-root");
+  if (!root) return;
+
+  const tableBody = document.getElementById("admin-claims-table-body");
+  const emptyNotice = document.getElementById("admin-claims-empty");
+  const errorNotice = document.getElementById("admin-claims-error");
+
+  if (!tableBody || !emptyNotice || !errorNotice) return;
+
+  function renderTable(claims) {
+    tableBody.innerHTML = "";
+    emptyNotice.classList.toggle("hidden", claims.length > 0);
+
+    claims.forEach((claim) => {
+      const tr = document.createElement("tr");
+      tr.innerHTML = `
+        <td>
+          <div class="admin-requests-server-name">
+            <a href="/servers/${encodeURIComponent(claim.server_id)}">${escapeHtml(
+              claim.server_name || ""
+            )}</a>
+          </div>
+        </td>
+        <td>
+          ${escapeHtml(claim.username || "")}
+          <div class="admin-requests-description">${escapeHtml(claim.discord_id || "")}</div>
+          ${
+            claim.verified_domain
+              ? `<span class="verified-badge">Verified ${escapeHtml(claim.verified_domain)}</span>`
+              : `<div class="admin-requests-description">Domain not verified</div>`
+          }
+        </td>
+        <td>${escapeHtml(claim.current_owners || "none")}</td>
+        <td><div class="admin-requests-description">${escapeHtml(claim.evidence || "")}</div></td>
+        <td>${formatDate(claim.created_at)}</td>
+        <td><div class="admin-requests-actions"></div></td>
+      `;
+
+      const actions = tr.querySelector(".admin-requests-actions");
+
+      const approveBtn = document.createElement("button");
+      approveBtn.type = "button";
+      approveBtn.className = "admin-requests-btn admin-requests-btn-approve";
+      approveBtn.textContent = "Approve";
+      approveBtn.addEventListener("click", () => {
+        if (
+          confirm(
+            `Give ${claim.server_name} to ${claim.username}? The current owners lose access.`
+          )
+        ) {
+          mutateClaim(claim.id, "approve");
+        }
+      });
+
+      const rejectBtn = document.createElement("button");
+      rejectBtn.type = "button";
+      rejectBtn.className = "admin-requests-btn admin-requests-btn-reject";
+      rejectBtn.textContent = "Reject";
+      rejectBtn.addEventListener("click", () => mutateClaim(claim.id, "reject"));
+
+      actions.appendChild(approveBtn);
+      actions.appendChild(rejectBtn);
+      tableBody.appendChild(tr);
+    });
+  }
+
+  async function loadClaims() {
+    errorNotice.classList.add("hidden");
+    try {
+      const res = await fetch("/api/admin/claims", {
+        credentials: "include",
+        headers: { Accept: "application/json" },
+      });
+      if (!res.ok) throw new Error("HTTP " + res.status);
+      const data = await res.json();
+      renderTable(Array.isArray(data.claims) ? data.claims : []);
+    } catch (_err) {
+      errorNotice.classList.remove("hidden");
+    }
+  }
+
+  async function mutateClaim(id, action) {
+    const res = await fetch(
+      `/api/admin/claims/${encodeURIComponent(id)}/${action}`,
+      { method: "POST", credentials: "include" }
+    );
+    if (!res.ok) {
+      errorNotice.textContent = (await res.text()) || "Could not update the claim.";
+      errorNotice.classList.remove("hidden");
+      return;
+    }
+    loadClaims();
+  }
+
+  loadClaims();
+}
@@ -0,0 +1,50 @@
+import { formatDate, escapeHtml } from "./dom-utils.js";
+
+export function initAdminDiscordInvites() {
+  const root = document.getElementById("admin-discord-invites-root");
+  if (!root) return;
+
+  const tableBody = document.getElementById("admin-discord-invites-table-body");
+  const emptyNotice = document.getElementById("admin-discord-invites-empty");
+  const errorNotice = document.getElementById("admin-discord-invites-error");
+
+  if (!tableBody || !emptyNotice || !errorNotice) return;
+
+  function renderTable(invites) {
+    tableBody.innerHTML = "";
+    emptyNotice.classList.toggle("hidden", invites.length > 0);
+
+    invites.forEach((inv) => {
+      const tr = document.createElement("tr");
+      tr.innerHTML = `
+        <td>
+          <div class="admin-requests-server-name">
+            <a href="/servers/${encodeURIComponent(inv.server_id)}">${escapeHtml(
+              inv.server_name || ""
+            )}</a>
+          </div>
+        </td>
+        <td><code>${escapeHtml(inv.invite_code || "")}</code></td>
+        <td>${formatDate(inv.expired_at)}</td>
+      `;
+      tableBody.appendChild(tr);
+    });
+  }
+
+  async function loadInvites() {
+    errorNotice.classList.add("hidden");
+    try {
+      const res = await fetch("/api/admin/discord-invites", {
+        credentials: "include",
+        headers: { Accept: "application/json" },
+      });
+      if (!res.ok) throw new Error("HTTP " + res.status);
+      const data = await res.json();
+      renderTable(Array.isArray(data.invites) ? data.invites : []);
+    } catch (_err) {
+      errorNotice.classList.remove("hidden");
+    }
+  }
+
+  loadInvites();
+}
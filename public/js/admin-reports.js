@@ -0,0 +1,106 @@
+import { formatDate, escapeHtml, escapeAttribute } from "./dom-utils.js";
+
+export function initAdminReports() {
+  const root = document.getElementById("admin-reports-root");
+  if (!root) return;
+
+  const tableBody = document.getElementById("admin-reports-table-body");
+  const emptyNotice = document.getElementById("admin-reports-empty");
+  const errorNotice = document.getElementById("admin-reports-error");
+  const statusFilter = document.getElementById("admin-reports-status");
+
+  if (!tableBody || !emptyNotice || !errorNotice || !statusFilter) return;
+
+  let categories = [];
+  let statuses = [];
+
+  const categoryLabel = (name) => (categories.find((c) => c.Name === name) || {}).Label || name;
+
+  function renderTable(reports) {
+    tableBody.innerHTML = "";
+    emptyNotice.classList.toggle("hidden", reports.length > 0);
+
+    reports.forEach((r) => {
+      const tr = document.createElement("tr");
+      tr.innerHTML = `
+        <td>
+          <div class="admin-requests-server-name">
+            <a href="/servers/${encodeURIComponent(r.server_id)}">${escapeHtml(
+              r.server_name || ""
+            )}</a>
+          </div>
+          <div class="admin-requests-description">${r.open_reports} open</div>
+        </td>
+        <td>
+          <span class="admin-requests-tag">${escapeHtml(categoryLabel(r.category))}</span>
+          <div class="admin-requests-description">${escapeHtml(r.details || "No details.")}</div>
+          <div class="admin-requests-description">
+            ${escapeHtml(r.reporter_name)} · ${formatDate(r.created_at)}
+          </div>
+        </td>
+        <td>
+          <select class="server-form-input" name="status">
+            ${statuses
+              .map(
+                (s) =>
+                  `<option value="${escapeAttribute(s)}"${s === r.status ? " selected" : ""}>${escapeHtml(s)}</option>`
+              )
+              .join("")}
+          </select>
+          <textarea class="server-form-textarea" name="note" maxlength="1000"
+            placeholder="Note for other admins">${escapeHtml(r.admin_note || "")}</textarea>
+        </td>
+        <td>
+          <div class="admin-requests-actions">
+            <button type="button" class="admin-requests-btn admin-requests-btn-approve">Save</button>
+          </div>
+        </td>
+      `;
+
+      tr.querySelector("button").addEventListener("click", () =>
+        updateReport(r.id, {
+          status: tr.querySelector("select[name=status]").value,
+          note: tr.querySelector("textarea[name=note]").value,
+        })
+      );
+
+      tableBody.appendChild(tr);
+    });
+  }
+
+  async function loadReports() {
+    errorNotice.classList.add("hidden");
+    try {
+      const params = statusFilter.value ? `?status=${encodeURIComponent(statusFilter.value)}` : "";
+      const res = await fetch(`/api/admin/reports${params}`, {
+        credentials: "include",
+        headers: { Accept: "application/json" },
+      });
+      if (!res.ok) throw new Error("HTTP " + res.status);
+      const data = await res.json();
+      categories = Array.isArray(data.categories) ? data.categories : [];
+      statuses = Array.isArray(data.statuses) ? data.statuses : [];
+      renderTable(Array.isArray(data.reports) ? data.reports : []);
+    } catch (_err) {
+      errorNotice.classList.remove("hidden");
+    }
+  }
+
+  async function updateReport(id, body) {
+    const res = await fetch(`/api/admin/reports/${encodeURIComponent(id)}`, {
+      method: "POST",
+      credentials: "include",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify(body),
+    });
+    if (!res.ok) {
+      errorNotice.textContent = (await res.text()) || "Could not update the report.";
+      errorNotice.classList.remove("hidden");
+      return;
+    }
+    loadReports();
+  }
+
+  statusFilter.addEventListener("change", loadReports);
+  loadReports();
+}
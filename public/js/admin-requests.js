@@ -1,8 +1,11 @@
 import { escapeHtml } from "./dom-utils.js";
+import { featureFieldsHTML, readFeatures } from "./features.js";
+import { linkFieldsHTML, readLinks } from "./links.js";
 
 const adminState = {
   requestsById: new Map(),
   editingId: null,
+  rejectionReasons: [],
 };
 
 export function initAdminRequests() {
@@ -39,12 +42,16 @@ export function initAdminRequests() {
     editForm.elements["url"].value = req.url || "";
     editForm.elements["logo_url"].value = req.logo_url || "";
     editForm.elements["description"].value = req.description || "";
+    editForm.elements["long_description"].value = req.long_description || "";
+    document.getElementById("edit_features").innerHTML = featureFieldsHTML(req.features);
+    document.getElementById("edit_links").innerHTML = linkFieldsHTML(req.links);
     editForm.elements["tags"].value = (req.tags || []).join(", ");
     editForm.elements["owner_name"].value = req.owner_name || "";
     editForm.elements["owner_discord"].value = req.owner_discord || "";
 
     editError.classList.add("hidden");
     overlay.classList.remove("hidden");
+    markSeen(req);
   }
 
   function closeEditModal() {
@@ -52,6 +59,26 @@ export function initAdminRequests() {
     overlay.classList.add("hidden");
   }
 
+  function renderChanges(changes) {
+    if (!changes.length) {
+      return `<div class="admin-requests-description">No changes against the live listing.</div>`;
+    }
+
+    const rows = changes
+      .map(
+        (ch) => `
+          <tr>
+            <th>${escapeHtml(ch.field)}</th>
+            <td class="admin-requests-diff-old">${escapeHtml(ch.old || "(empty)")}</td>
+            <td class="admin-requests-diff-new">${escapeHtml(ch.new || "(empty)")}</td>
+          </tr>
+        `
+      )
+      .join("");
+
+    return `<table class="admin-requests-diff"><tbody>${rows}</tbody></table>`;
+  }
+
   function renderTable(requests) {
     tableBody.innerHTML = "";
     adminState.requestsById.clear();
@@ -69,18 +96,48 @@ export function initAdminRequests() {
       const tr = document.createElement("tr");
 
       const serverTd = document.createElement("td");
-      serverTd.innerHTML = `
-        <div class="admin-requests-server-name">${escapeHtml(
-          req.server_name || ""
-        )}</div>
-        ${
-          req.description
-            ? `<div class="admin-requests-description">${escapeHtml(
-                req.description
-              )}</div>`
-            : ""
-        }
-      `;
+      if (req.kind === "edit") {
+        serverTd.innerHTML = `
+          <div class="admin-requests-server-name">
+            <span class="admin-requests-kind">Edit</span>
+            <a href="/servers/${encodeURIComponent(req.server_id)}">${escapeHtml(
+              req.server_name || ""
+            )}</a>
+          </div>
+          ${renderChanges(req.changes || [])}
+        `;
+      } else {
+        serverTd.innerHTML = `
+          <div class="admin-requests-server-name">${escapeHtml(
+            req.server_name || ""
+          )}</div>
+          ${
+            req.description
+              ? `<div class="admin-requests-description">${escapeHtml(
+                  req.description
+                )}</div>`
+              : ""
+          }
+        `;
+      }
+      if (req.revision > (req.seen_revision || 0)) {
+        const unseen = document.createElement("div");
+        unseen.className = "admin-requests-unseen";
+        unseen.innerHTML =
+          req.unseen_changes && req.unseen_changes.length
+            ? `<div class="admin-requests-updated">Updated since you last looked</div>
+               ${renderChanges(req.unseen_changes)}`
+            : `<div class="admin-requests-updated">Not seen yet</div>`;
+
+        const seenBtn = document.createElement("button");
+        seenBtn.type = "button";
+        seenBtn.className = "admin-requests-btn";
+        seenBtn.textContent = "Mark as seen";
+        seenBtn.addEventListener("click", () => markSeen(req));
+        unseen.appendChild(seenBtn);
+
+        serverTd.appendChild(unseen);
+      }
       tr.appendChild(serverTd);
 
       const ownerTd = document.createElement("td");
@@ -129,21 +186,74 @@ export function initAdminRequests() {
       rejectBtn.type = "button";
       rejectBtn.className = "admin-requests-btn admin-requests-btn-reject";
       rejectBtn.textContent = "Reject";
-      rejectBtn.addEventListener("click", () =>
-        mutateRequest(req.id, "reject")
-      );
+      const rejectForm = rejectFormFor(req);
+      rejectBtn.addEventListener("click", () => {
+        rejectForm.classList.toggle("hidden");
+      });
 
       actionsWrap.appendChild(editBtn);
       actionsWrap.appendChild(approveBtn);
       actionsWrap.appendChild(rejectBtn);
 
       actionsTd.appendChild(actionsWrap);
+      actionsTd.appendChild(rejectForm);
       tr.appendChild(actionsTd);
 
       tableBody.appendChild(tr);
     });
   }
 
+  // rejectFormFor builds the form an admin fills in to reject req. The
+  // reason and note are shown to the submitter.
+  function rejectFormFor(req) {
+    const form = document.createElement("form");
+    form.className = "admin-reject-form hidden";
+    form.innerHTML = `
+      <select class="server-form-input" name="code" required>
+        <option value="">Why is it rejected?</option>
+        ${adminState.rejectionReasons
+          .map(
+            (r) =>
+              `<option value="${escapeHtml(r.code)}">${escapeHtml(r.label)}</option>`
+          )
+          .join("")}
+      </select>
+      <textarea class="server-form-textarea" name="reason" maxlength="500"
+        placeholder="Note for the submitter"></textarea>
+      <button type="submit" class="admin-requests-btn admin-requests-btn-reject">
+        Reject request
+      </button>
+    `;
+
+    const code = form.elements["code"];
+    const reason = form.elements["reason"];
+    code.addEventListener("change", () => {
+      reason.required = code.value === "other";
+    });
+
+    form.addEventListener("submit", (e) => {
+      e.preventDefault();
+      mutateRequest(req.id, "reject", {
+        code: code.value,
+        reason: reason.value.trim(),
+      });
+    });
+    return form;
+  }
+
+  async function loadRejectionReasons() {
+    try {
+      const res = await fetch("/api/admin/rejection-reasons", {
+        credentials: "include",
+        headers: { Accept: "application/json" },
+      });
+      if (!res.ok) return;
+      const data = await res.json();
+      adminState.rejectionReasons = Array.isArray(data.reasons) ? data.reasons : [];
+    } catch (_err) {
+    }
+  }
+
   async function loadRequests() {
     errorNotice.classList.add("hidden");
 
@@ -203,7 +313,29 @@ export function initAdminRequests() {
     errorNotice.classList.remove("hidden");
   }
 
-  function mutateRequest(id, action) {
+  // markSeen records that the admin has looked at the revision of req they
+  // were shown, so later edits are listed against it.
+  function markSeen(req) {
+    if (!(req.revision > (req.seen_revision || 0))) {
+      return Promise.resolve();
+    }
+
+    return fetch(`/admin/requests/${req.id}/seen`, {
+      method: "POST",
+      credentials: "include",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ revision: req.revision }),
+    })
+      .then((res) => {
+        if (!res.ok) throw new Error("bad status");
+        loadRequests();
+      })
+      .catch(() => {
+        errorNotice.classList.remove("hidden");
+      });
+  }
+
+  function mutateRequest(id, action, body) {
     const path =
       action === "approve"
         ? `/admin/requests/${id}/approve`
@@ -212,6 +344,8 @@ export function initAdminRequests() {
     fetch(path, {
       method: "POST",
       credentials: "include",
+      headers: body ? { "Content-Type": "application/json" } : {},
+      body: body ? JSON.stringify(body) : undefined,
     })
       .then((res) => {
         if (!res.ok) throw new Error("bad status");
@@ -242,6 +376,9 @@ export function initAdminRequests() {
       url: editForm.elements["url"].value.trim() || null,
       logo_url: editForm.elements["logo_url"].value.trim() || null,
       description: editForm.elements["description"].value.trim(),
+      long_description: editForm.elements["long_description"].value.trim(),
+      features: readFeatures(editForm),
+      links: readLinks(editForm),
       tags,
       owner_name: editForm.elements["owner_name"].value.trim(),
       owner_discord: editForm.elements["owner_discord"].value.trim(),
@@ -277,5 +414,5 @@ export function initAdminRequests() {
     }
   });
 
-  loadRequests();
+  loadRejectionReasons().then(loadRequests);
 }
@@ -0,0 +1,96 @@
+import { formatDate, escapeHtml } from "./dom-utils.js";
+
+export function initAdminReviews() {
+  const root = document.getElementById("admin-reviews-root");
+  if (!root) return;
+
+  const tableBody = document.getElementById("admin-reviews-table-body");
+  const emptyNotice = document.getElementById("admin-reviews-empty");
+  const errorNotice = document.getElementById("admin-reviews-error");
+
+  if (!tableBody || !emptyNotice || !errorNotice) return;
+
+  function renderTable(reviews) {
+    tableBody.innerHTML = "";
+    emptyNotice.classList.toggle("hidden", reviews.length > 0);
+
+    reviews.forEach((r) => {
+      const reasons = (r.report_reasons || [])
+        .map((reason) => `<li>${escapeHtml(reason)}</li>`)
+        .join("");
+
+      const tr = document.createElement("tr");
+      tr.innerHTML = `
+        <td>
+          <div class="admin-requests-server-name">
+            <a href="/servers/${encodeURIComponent(r.server_id)}">${escapeHtml(
+              r.server_name || ""
+            )}</a>
+          </div>
+        </td>
+        <td>
+          <div class="admin-requests-server-name">
+            ${"★".repeat(r.rating)}${"☆".repeat(5 - r.rating)} ${escapeHtml(r.username)}
+            ${r.hidden ? `<span class="admin-requests-tag">hidden</span>` : ""}
+          </div>
+          <div class="admin-requests-description">${escapeHtml(r.body || "No text.")}</div>
+          <div class="admin-requests-description">Posted ${formatDate(r.created_at)}</div>
+        </td>
+        <td>
+          <div>${r.reports} open report${r.reports === 1 ? "" : "s"}</div>
+          ${reasons ? `<ul class="admin-requests-description">${reasons}</ul>` : ""}
+        </td>
+        <td><div class="admin-requests-actions"></div></td>
+      `;
+
+      const actions = tr.querySelector(".admin-requests-actions");
+      const addButton = (label, action, className) => {
+        const btn = document.createElement("button");
+        btn.type = "button";
+        btn.className = `admin-requests-btn ${className}`;
+        btn.textContent = label;
+        btn.addEventListener("click", () => mutateReview(r.id, action));
+        actions.appendChild(btn);
+      };
+
+      if (r.hidden) {
+        addButton("Unhide", "unhide", "admin-requests-btn-approve");
+      } else {
+        addButton("Hide", "hide", "admin-requests-btn-reject");
+      }
+      addButton("Dismiss reports", "dismiss", "");
+
+      tableBody.appendChild(tr);
+    });
+  }
+
+  async function loadReviews() {
+    errorNotice.classList.add("hidden");
+    try {
+      const res = await fetch("/api/admin/reviews", {
+        credentials: "include",
+        headers: { Accept: "application/json" },
+      });
+      if (!res.ok) throw new Error("HTTP " + res.status);
+      const data = await res.json();
+      renderTable(Array.isArray(data.reviews) ? data.reviews : []);
+    } catch (_err) {
+      errorNotice.classList.remove("hidden");
+    }
+  }
+
+  async function mutateReview(id, action) {
+    const res = await fetch(`/api/admin/reviews/${encodeURIComponent(id)}/${action}`, {
+      method: "POST",
+      credentials: "include",
+    });
+    if (!res.ok) {
+      errorNotice.textContent = (await res.text()) || "Could not update the review.";
+      errorNotice.classList.remove("hidden");
+      return;
+    }
+    loadReviews();
+  }
+
+  loadReviews();
+}
@@ -0,0 +1,97 @@
+import { formatDate, escapeHtml, escapeAttribute } from "./dom-utils.js";
+
+export function initAdminScreenshots() {
+  const root = document.getElementById("admin-screenshots-root");
+  if (!root) return;
+
+  const tableBody = document.getElementById("admin-screenshots-table-body");
+  const emptyNotice = document.getElementById("admin-screenshots-empty");
+  const errorNotice = document.getElementById("admin-screenshots-error");
+
+  if (!tableBody || !emptyNotice || !errorNotice) return;
+
+  function captionCell(shot) {
+    if (shot.status === "approved" && shot.caption_pending) {
+      return `
+        <div class="admin-requests-description">Caption change</div>
+        <div class="admin-requests-diff-old">${escapeHtml(shot.caption || "(none)")}</div>
+        <div class="admin-requests-diff-new">${escapeHtml(shot.pending_caption || "(none)")}</div>
+      `;
+    }
+    return `<div class="admin-requests-description">${escapeHtml(shot.caption || "No caption.")}</div>`;
+  }
+
+  function renderTable(shots) {
+    tableBody.innerHTML = "";
+    emptyNotice.classList.toggle("hidden", shots.length > 0);
+
+    shots.forEach((shot) => {
+      const tr = document.createElement("tr");
+      tr.innerHTML = `
+        <td>
+          <div class="admin-requests-server-name">
+            <a href="/servers/${encodeURIComponent(shot.server_id)}">${escapeHtml(
+              shot.server_name || ""
+            )}</a>
+          </div>
+        </td>
+        <td>
+          <a href="${escapeAttribute(shot.image_url)}" target="_blank" rel="noopener">
+            <img class="admin-screenshot-thumb" src="${escapeAttribute(shot.thumb_url)}" alt="" />
+          </a>
+        </td>
+        <td>${captionCell(shot)}</td>
+        <td>${formatDate(shot.created_at)}</td>
+        <td><div class="admin-requests-actions"></div></td>
+      `;
+
+      const actions = tr.querySelector(".admin-requests-actions");
+
+      const approveBtn = document.createElement("button");
+      approveBtn.type = "button";
+      approveBtn.className = "admin-requests-btn admin-requests-btn-approve";
+      approveBtn.textContent = "Approve";
+      approveBtn.addEventListener("click", () => mutateScreenshot(shot.id, "approve"));
+
+      const rejectBtn = document.createElement("button");
+      rejectBtn.type = "button";
+      rejectBtn.className = "admin-requests-btn admin-requests-btn-reject";
+      rejectBtn.textContent = "Reject";
+      rejectBtn.addEventListener("click", () => mutateScreenshot(shot.id, "reject"));
+
+      actions.appendChild(approveBtn);
+      actions.appendChild(rejectBtn);
+      tableBody.appendChild(tr);
+    });
+  }
+
+  async function loadScreenshots() {
+    errorNotice.classList.add("hidden");
+    try {
+      const res = await fetch("/api/admin/screenshots", {
+        credentials: "include",
+        headers: { Accept: "application/json" },
+      });
+      if (!res.ok) throw new Error("HTTP " + res.status);
+      const data = await res.json();
+      renderTable(Array.isArray(data.screenshots) ? data.screenshots : []);
+    } catch (_err) {
+      errorNotice.classList.remove("hidden");
+    }
+  }
+
+  async function mutateScreenshot(id, action) {
+    const res = await fetch(
+      `/api/admin/screenshots/${encodeURIComponent(id)}/${action}`,
+      { method: "POST", credentials: "include" }
+    );
+    if (!res.ok) {
+      errorNotice.textContent = (await res.text()) || "Could not update the screenshot.";
+      errorNotice.classList.remove("hidden");
+      return;
+    }
+    loadScreenshots();
+  }
+
+  loadScreenshots();
+}
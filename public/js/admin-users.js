@@ -0,0 +1,119 @@
+import { formatDate, escapeHtml } from "./dom-utils.js";
+
+export function initAdminUsers() {
+  const root = document.getElementById("admin-users-root");
+  if (!root) return;
+
+  const tableBody = document.getElementById("admin-users-table-body");
+  const errorNotice = document.getElementById("admin-users-error");
+  const roleNotice = document.getElementById("admin-users-role");
+  const form = document.getElementById("admin-users-form");
+
+  if (!tableBody || !errorNotice || !roleNotice || !form) return;
+
+  function showError(message) {
+    errorNotice.textContent = message;
+    errorNotice.classList.remove("hidden");
+  }
+
+  function renderTable(data) {
+    const canManage = (data.me.permissions || []).includes("manage_admins");
+    const roles = data.roles || [];
+
+    roleNotice.textContent = `You are signed in as ${data.me.role}: ${
+      (data.me.permissions || []).join(", ") || "read only"
+    }.`;
+    form.classList.toggle("hidden", !canManage);
+    form.elements["role"].innerHTML = roles
+      .map((r) => `<option value="${escapeHtml(r)}">${escapeHtml(r)}</option>`)
+      .join("");
+
+    tableBody.innerHTML = "";
+    (data.admins || []).forEach((a) => {
+      const tr = document.createElement("tr");
+      tr.innerHTML = `
+        <td><code>${escapeHtml(a.discord_id)}</code></td>
+        <td></td>
+        <td>${escapeHtml((a.permissions || []).join(", ") || "read only")}</td>
+        <td>${
+          a.bootstrap
+            ? "MOSS_ADMIN_IDS"
+            : `${escapeHtml(a.added_by || "")} · ${a.created_at ? formatDate(a.created_at) : ""}`
+        }</td>
+        <td></td>
+      `;
+
+      const cells = tr.querySelectorAll("td");
+      const editable = canManage && !a.bootstrap && a.discord_id !== data.me.discord_id;
+
+      if (!editable) {
+        cells[1].textContent = a.role;
+      } else {
+        const select = document.createElement("select");
+        roles.forEach((role) => {
+          const opt = document.createElement("option");
+          opt.value = role;
+          opt.textContent = role;
+          opt.selected = role === a.role;
+          select.appendChild(opt);
+        });
+        select.addEventListener("change", () =>
+          saveAdmin("/api/admin/admins", { discord_id: a.discord_id, role: select.value })
+        );
+        cells[1].appendChild(select);
+
+        const removeBtn = document.createElement("button");
+        removeBtn.type = "button";
+        removeBtn.className = "admin-requests-btn admin-requests-btn-reject";
+        removeBtn.textContent = "Remove";
+        removeBtn.addEventListener("click", () => {
+          if (confirm(`Remove ${a.discord_id} from the admins?`)) {
+            saveAdmin(`/api/admin/admins/${encodeURIComponent(a.discord_id)}/remove`);
+          }
+        });
+        cells[4].appendChild(removeBtn);
+      }
+
+      tableBody.appendChild(tr);
+    });
+  }
+
+  async function loadAdmins() {
+    errorNotice.classList.add("hidden");
+    try {
+      const res = await fetch("/api/admin/admins", {
+        credentials: "include",
+        headers: { Accept: "application/json" },
+      });
+      if (!res.ok) throw new Error("HTTP " + res.status);
+      renderTable(await res.json());
+    } catch (_err) {
+      showError("Could not load admins.");
+    }
+  }
+
+  async function saveAdmin(url, body) {
+    const res = await fetch(url, {
+      method: "POST",
+      credentials: "include",
+      headers: { "Content-Type": "application/json" },
+      body: body ? JSON.stringify(body) : undefined,
+    });
+    if (!res.ok) {
+      showError((await res.text()) || "Could not update the admin.");
+      return;
+    }
+    loadAdmins();
+  }
+
+  form.addEventListener("submit", async (e) => {
+    e.preventDefault();
+    await saveAdmin("/api/admin/admins", {
+      discord_id: form.elements["discord_id"].value.trim(),
+      role: form.elements["role"].value,
+    });
+    form.reset();
+  });
+
+  loadAdmins();
+}
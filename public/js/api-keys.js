@@ -0,0 +1,130 @@
+import { formatDate, escapeHtml } from "./dom-utils.js";
+
+export function initApiKeys() {
+  const root = document.getElementById("api-keys-root");
+  if (!root) return;
+
+  const loginNotice = document.getElementById("api-keys-login");
+  const errorEl = document.getElementById("api-keys-error");
+  const createdEl = document.getElementById("api-keys-created");
+  const createdValueEl = document.getElementById("api-keys-created-value");
+  const formEl = document.getElementById("api-key-form");
+  const tableShell = document.getElementById("api-keys-table-shell");
+  const tableBody = document.getElementById("api-keys-table-body");
+
+  if (!formEl || !tableShell || !tableBody || !errorEl) return;
+
+  const showError = (text) => {
+    errorEl.textContent = text || "Something went wrong.";
+    errorEl.classList.remove("hidden");
+  };
+
+  function renderKeys(keys) {
+    tableBody.innerHTML = "";
+
+    keys.forEach((key) => {
+      const tr = document.createElement("tr");
+      const revoked = Boolean(key.revoked_at);
+      if (revoked) tr.classList.add("admin-requests-row-removed");
+
+      tr.innerHTML = `
+        <td>${escapeHtml(key.name || "")}</td>
+        <td><code>${escapeHtml(key.prefix || "")}…</code></td>
+        <td>${escapeHtml((key.scopes || []).join(", "))}</td>
+        <td>${escapeHtml(key.tier || "")}</td>
+        <td>${key.requests_today ?? 0} today · ${key.request_count ?? 0} total</td>
+        <td>${key.last_used_at ? formatDate(key.last_used_at) : "never"}</td>
+        <td></td>
+      `;
+
+      if (!revoked) {
+        const revokeBtn = document.createElement("button");
+        revokeBtn.type = "button";
+        revokeBtn.className = "admin-requests-btn admin-requests-btn-reject";
+        revokeBtn.textContent = "Revoke";
+        revokeBtn.addEventListener("click", () => revokeKey(key.id));
+        tr.lastElementChild.appendChild(revokeBtn);
+      } else {
+        tr.lastElementChild.textContent = "revoked";
+      }
+
+      tableBody.appendChild(tr);
+    });
+
+    tableShell.classList.toggle("hidden", keys.length === 0);
+  }
+
+  async function loadKeys() {
+    errorEl.classList.add("hidden");
+
+    try {
+      const res = await fetch("/api/keys", {
+        credentials: "include",
+        headers: { Accept: "application/json" },
+      });
+
+      if (res.status === 401) {
+        if (loginNotice) loginNotice.classList.remove("hidden");
+        formEl.classList.add("hidden");
+        return;
+      }
+      if (!res.ok) throw new Error("HTTP " + res.status);
+
+      const data = await res.json();
+      formEl.classList.remove("hidden");
+      renderKeys(Array.isArray(data.keys) ? data.keys : []);
+    } catch (_err) {
+      showError("Couldn't load your API keys.");
+    }
+  }
+
+  async function revokeKey(id) {
+    if (!confirm("Revoke this key? Applications using it will stop working.")) {
+      return;
+    }
+
+    const res = await fetch(`/api/keys/${encodeURIComponent(id)}/revoke`, {
+      method: "POST",
+      credentials: "include",
+    });
+    if (!res.ok) {
+      showError((await res.text()) || "Couldn't revoke the key.");
+      return;
+    }
+    loadKeys();
+  }
+
+  formEl.addEventListener("submit", async (e) => {
+    e.preventDefault();
+    errorEl.classList.add("hidden");
+
+    const scopes = Array.from(
+      formEl.querySelectorAll('input[name="scopes"]:checked')
+    ).map((el) => el.value);
+
+    const res = await fetch("/api/keys", {
+      method: "POST",
+      credentials: "include",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({
+        name: formEl.elements["name"].value.trim(),
+        scopes,
+      }),
+    });
+
+    if (!res.ok) {
+      showError((await res.text()) || "Couldn't create the key.");
+      return;
+    }
+
+    const data = await res.json();
+    if (createdEl && createdValueEl) {
+      createdValueEl.textContent = data.key || "";
+      createdEl.classList.remove("hidden");
+    }
+    formEl.reset();
+    loadKeys();
+  });
+
+  loadKeys();
+}
@@ -6,6 +6,7 @@ export function initAuth() {
   const menuEl = document.getElementById("nav-user-menu");
   const logoutBtn = document.getElementById("nav-logout");
   const adminBtn = document.getElementById("nav-admin");
+  const ownerBtn = document.getElementById("nav-owner");
 
   if (userContainer) {
     userContainer.classList.add("hidden");
@@ -55,6 +56,13 @@ export function initAuth() {
         avatarEl.alt = data.username || "Discord avatar";
       }
 
+      if (ownerBtn) {
+        ownerBtn.onclick = (e) => {
+          e.preventDefault();
+          window.location.href = "/owner";
+        };
+      }
+
       if (adminBtn) {
         if (data.is_admin) {
           adminBtn.classList.remove("hidden");
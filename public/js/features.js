@@ -0,0 +1,119 @@
+import { escapeAttribute } from "./dom-utils.js";
+
+// Mirrors the values accepted by the server (features.go).
+export const FEATURE_OPTIONS = {
+  modes: [
+    ["std", "osu!standard"],
+    ["taiko", "osu!taiko"],
+    ["catch", "osu!catch"],
+    ["mania", "osu!mania"],
+  ],
+  leaderboards: [
+    ["vanilla", "Vanilla"],
+    ["relax", "Relax"],
+    ["autopilot", "Autopilot"],
+    ["scorev2", "ScoreV2"],
+  ],
+  clients: [
+    ["stable", "osu!stable"],
+    ["lazer", "osu!lazer"],
+    ["mcosu", "McOsu"],
+    ["custom", "Custom"],
+  ],
+  pp_system: [
+    ["stable", "osu!stable"],
+    ["lazer", "osu!lazer"],
+    ["custom", "Custom"],
+    ["none", "None"],
+  ],
+  registration: [
+    ["open", "Open"],
+    ["invite", "Invite only"],
+    ["closed", "Closed"],
+  ],
+};
+
+export function featureLabel(value) {
+  for (const options of Object.values(FEATURE_OPTIONS)) {
+    const match = options.find(([v]) => v === value);
+    if (match) return match[1];
+  }
+  return value;
+}
+
+function checkboxes(name, selected) {
+  return FEATURE_OPTIONS[name]
+    .map(
+      ([value, label]) => `
+        <label><input type="checkbox" name="${name}" value="${value}"${
+          (selected || []).includes(value) ? " checked" : ""
+        } /> ${label}</label>`
+    )
+    .join("");
+}
+
+function select(name, current, options) {
+  return `
+    <select class="server-form-input" name="${name}">
+      <option value="">Not set</option>
+      ${options
+        .map(
+          ([value, label]) =>
+            `<option value="${escapeAttribute(value)}"${
+              value === current ? " selected" : ""
+            }>${label}</option>`
+        )
+        .join("")}
+    </select>`;
+}
+
+// featureFieldsHTML renders the structured feature inputs for a listing
+// form, filled from features.
+export function featureFieldsHTML(features) {
+  const f = features || {};
+  const unranked =
+    f.unranked_rankable === true ? "true" : f.unranked_rankable === false ? "false" : "";
+
+  return `
+    <div class="server-form-row">
+      <span class="server-form-label">Game modes</span>
+      <div class="server-form-choices">${checkboxes("modes", f.modes)}</div>
+    </div>
+    <div class="server-form-row">
+      <span class="server-form-label">Leaderboards</span>
+      <div class="server-form-choices">${checkboxes("leaderboards", f.leaderboards)}</div>
+    </div>
+    <div class="server-form-row">
+      <span class="server-form-label">Supported clients</span>
+      <div class="server-form-choices">${checkboxes("clients", f.clients)}</div>
+    </div>
+    <div class="server-form-row server-form-row-inline">
+      <span class="server-form-label">pp system</span>
+      ${select("pp_system", f.pp_system || "", FEATURE_OPTIONS.pp_system)}
+      <span class="server-form-label">Registration</span>
+      ${select("registration", f.registration || "", FEATURE_OPTIONS.registration)}
+      <span class="server-form-label">Unranked maps</span>
+      ${select("unranked_rankable", unranked, [
+        ["true", "Give pp"],
+        ["false", "Do not give pp"],
+      ])}
+    </div>
+  `;
+}
+
+// readFeatures collects the inputs rendered by featureFieldsHTML.
+export function readFeatures(form) {
+  const checked = (name) =>
+    Array.from(form.querySelectorAll(`input[name="${name}"]:checked`)).map((el) => el.value);
+  const unranked = form.elements["unranked_rankable"].value;
+
+  const features = {
+    modes: checked("modes"),
+    leaderboards: checked("leaderboards"),
+    clients: checked("clients"),
+    pp_system: form.elements["pp_system"].value,
+    registration: form.elements["registration"].value,
+  };
+  if (unranked !== "") features.unranked_rankable = unranked === "true";
+  return features;
+}
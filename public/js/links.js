@@ -0,0 +1,34 @@
+import { escapeAttribute } from "./dom-utils.js";
+
+// Mirrors the link types accepted by the server (links.go).
+export const LINK_TYPES = [
+  ["discord", "Discord", "https://discord.gg/invite-code"],
+  ["github", "GitHub", "https://github.com/owner/repo"],
+  ["twitter", "Twitter / X", "https://x.com/handle"],
+  ["youtube", "YouTube", "https://youtube.com/@channel"],
+  ["donate", "Donate", "https://ko-fi.com/yourserver"],
+];
+
+// linkFieldsHTML renders one input per link type, filled from links.
+export function linkFieldsHTML(links) {
+  const byType = new Map((links || []).map((l) => [l.type, l.url]));
+  return `
+    <div class="server-form-row server-form-row-inline">
+      ${LINK_TYPES.map(
+        ([type, label, placeholder]) => `
+          <span class="server-form-label">${label}</span>
+          <input class="server-form-input" type="url" name="link_${type}" maxlength="300"
+            placeholder="${escapeAttribute(placeholder)}"
+            value="${escapeAttribute(byType.get(type) || "")}" />`
+      ).join("")}
+    </div>
+  `;
+}
+
+// readLinks collects the inputs rendered by linkFieldsHTML.
+export function readLinks(form) {
+  return LINK_TYPES.map(([type]) => ({
+    type,
+    url: form.elements[`link_${type}`].value.trim(),
+  })).filter((l) => l.url);
+}
@@ -2,10 +2,21 @@
 
 import { initTheme } from "./theme.js";
 import { initAuth } from "./auth.js";
-import { initLeaderboard, handleVoteClick } from "./leaderboard.js";
+import { handleVoteClick } from "./leaderboard.js";
 import { initServerDetail } from "./server-detail.js";
 import { initListPage } from "./list.js";
 import { initAdminRequests } from "./admin-requests.js";
+import { initAdminApiKeys } from "./admin-api-keys.js";
+import { initAdminClaims } from "./admin-claims.js";
+import { initAdminScreenshots } from "./admin-screenshots.js";
+import { initAdminDiscordInvites } from "./admin-discord-invites.js";
+import { initAdminAnnouncements } from "./admin-announcements.js";
+import { initAdminReviews } from "./admin-reviews.js";
+import { initAdminReports } from "./admin-reports.js";
+import { initAdminUsers } from "./admin-users.js";
+import { initAdminAudit } from "./admin-audit.js";
+import { initApiKeys } from "./api-keys.js";
+import { initOwnerDashboard } from "./owner.js";
 
 document.addEventListener("DOMContentLoaded", () => {
   initTheme();
@@ -14,16 +25,28 @@ document.addEventListener("DOMContentLoaded", () => {
   const adminRoot = document.getElementById("admin-requests-root");
   const detailRoot = document.getElementById("server-detail");
   const listForm = document.getElementById("server-request-form");
-  const serverGridEl = document.getElementById("server-grid");
+  const apiKeysRoot = document.getElementById("api-keys-root");
+  const ownerRoot = document.getElementById("owner-root");
 
   if (adminRoot) {
     initAdminRequests();
+    initAdminClaims();
+    initAdminScreenshots();
+    initAdminDiscordInvites();
+    initAdminAnnouncements();
+    initAdminReviews();
+    initAdminReports();
+    initAdminUsers();
+    initAdminAudit();
+    initAdminApiKeys();
+  } else if (apiKeysRoot) {
+    initApiKeys();
+  } else if (ownerRoot) {
+    initOwnerDashboard();
   } else if (detailRoot) {
     initServerDetail();
   } else if (listForm) {
     initListPage();
-  } else if (serverGridEl) {
-    initLeaderboard();
   }
 });
 
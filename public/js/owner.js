@@ -0,0 +1,836 @@
+import { escapeHtml, escapeAttribute, formatDate } from "./dom-utils.js";
+import { featureFieldsHTML, readFeatures } from "./features.js";
+import { linkFieldsHTML, readLinks } from "./links.js";
+
+export function initOwnerDashboard() {
+  const root = document.getElementById("owner-root");
+  if (!root) return;
+
+  const loginNotice = document.getElementById("owner-login");
+  const errorEl = document.getElementById("owner-error");
+  const successEl = document.getElementById("owner-success");
+  const emptyEl = document.getElementById("owner-empty");
+  const listEl = document.getElementById("owner-servers");
+  const invitesEl = document.getElementById("owner-invites");
+  const claimsEl = document.getElementById("owner-claims");
+  const requestsEl = document.getElementById("owner-requests");
+  const requestsListEl = document.getElementById("owner-requests-list");
+
+  if (!errorEl || !listEl) return;
+
+  const showError = (text) => {
+    errorEl.textContent = text || "Something went wrong.";
+    errorEl.classList.remove("hidden");
+  };
+
+  async function post(path, body) {
+    errorEl.classList.add("hidden");
+    const res = await fetch(path, {
+      method: "POST",
+      credentials: "include",
+      headers: body ? { "Content-Type": "application/json" } : {},
+      body: body ? JSON.stringify(body) : undefined,
+    });
+    if (!res.ok) {
+      showError((await res.text()) || "Something went wrong.");
+      return false;
+    }
+    return true;
+  }
+
+  // bindLogoUpload uploads a picked file straight away and puts the hosted
+  // URL into the form's logo_url field.
+  function bindLogoUpload(form) {
+    const fileInput = form.elements["logo_file"];
+    if (!fileInput) return;
+
+    fileInput.addEventListener("change", async () => {
+      const file = fileInput.files && fileInput.files[0];
+      if (!file) return;
+      errorEl.classList.add("hidden");
+
+      const body = new FormData();
+      body.append("logo", file);
+      const res = await fetch("/api/logos", {
+        method: "POST",
+        credentials: "include",
+        body,
+      });
+      if (!res.ok) {
+        showError((await res.text()) || "Couldn't upload the logo.");
+        fileInput.value = "";
+        return;
+      }
+      const data = await res.json();
+      form.elements["logo_url"].value = data.logo_url || "";
+    });
+  }
+
+  function renderTeam(server) {
+    const isOwner = server.role === "owner";
+    const wrap = document.createElement("div");
+    wrap.className = "owner-team";
+
+    const owners = (server.owners || [])
+      .map(
+        (o) => `
+          <li class="owner-team-member">
+            <span>${escapeHtml(o.username || o.discord_id)}</span>
+            <span class="admin-requests-tag">${escapeHtml(o.role)}</span>
+            ${
+              isOwner || o.discord_id === server.viewer_id
+                ? `<button type="button" class="admin-requests-btn admin-requests-btn-reject"
+                     data-remove="${escapeAttribute(o.discord_id)}">Remove</button>`
+                : ""
+            }
+          </li>
+        `
+      )
+      .join("");
+
+    const invites = (server.invites || [])
+      .map(
+        (inv) => `
+          <li class="owner-team-member">
+            <span>${escapeHtml(inv.discord_id)}</span>
+            <span class="admin-requests-tag">${
+              inv.kind === "transfer" ? "transfer" : escapeHtml(inv.role)
+            } · invited</span>
+            <button type="button" class="admin-requests-btn"
+              data-cancel="${escapeAttribute(inv.id)}">Cancel</button>
+          </li>
+        `
+      )
+      .join("");
+
+    wrap.innerHTML = `
+      <h3 class="owner-team-title">Team</h3>
+      <ul class="owner-team-list">${owners}${invites}</ul>
+      ${
+        isOwner
+          ? `<form class="owner-invite-form">
+               <input class="server-form-input" name="discord_id" required
+                 inputmode="numeric" placeholder="Discord user ID" />
+               <select class="server-form-input" name="role">
+                 <option value="manager">Manager</option>
+                 <option value="owner">Owner</option>
+                 <option value="transfer">Transfer ownership</option>
+               </select>
+               <button class="btn-secondary" type="submit">Invite</button>
+             </form>
+             <div class="server-form-helper">
+               Managers can propose listing edits. Owners can also manage the
+               team. A transfer makes them an owner and you a manager once they
+               accept.
+             </div>`
+          : ""
+      }
+    `;
+
+    wrap.querySelectorAll("[data-remove]").forEach((btn) => {
+      btn.addEventListener("click", async () => {
+        const target = btn.dataset.remove;
+        const self = target === server.viewer_id;
+        if (!confirm(self ? "Leave this server's team?" : "Remove this person from the team?")) {
+          return;
+        }
+        const ok = await post(
+          `/api/owner/servers/${encodeURIComponent(server.id)}/owners/${encodeURIComponent(target)}/remove`
+        );
+        if (ok) loadServers();
+      });
+    });
+
+    wrap.querySelectorAll("[data-cancel]").forEach((btn) => {
+      btn.addEventListener("click", async () => {
+        const ok = await post(
+          `/api/owner/servers/${encodeURIComponent(server.id)}/invites/${encodeURIComponent(btn.dataset.cancel)}/cancel`
+        );
+        if (ok) loadServers();
+      });
+    });
+
+    const inviteForm = wrap.querySelector(".owner-invite-form");
+    if (inviteForm) {
+      inviteForm.addEventListener("submit", async (e) => {
+        e.preventDefault();
+        const discordId = inviteForm.elements["discord_id"].value.trim();
+        const role = inviteForm.elements["role"].value;
+        const base = `/api/owner/servers/${encodeURIComponent(server.id)}`;
+        const ok =
+          role === "transfer"
+            ? await post(`${base}/transfer`, { discord_id: discordId })
+            : await post(`${base}/invites`, { discord_id: discordId, role });
+        if (ok) loadServers();
+      });
+    }
+
+    return wrap;
+  }
+
+  function renderInvites(invites) {
+    if (!invitesEl) return;
+    invitesEl.innerHTML = "";
+    invitesEl.classList.toggle("hidden", invites.length === 0);
+
+    invites.forEach((inv) => {
+      const row = document.createElement("div");
+      row.className = "notice owner-invite";
+      const what =
+        inv.kind === "transfer"
+          ? "to take over ownership of"
+          : `to join as ${escapeHtml(inv.role)} of`;
+      row.innerHTML = `
+        <span>You were invited ${what} <strong>${escapeHtml(inv.server_name)}</strong>.</span>
+        <button type="button" class="admin-requests-btn admin-requests-btn-approve">Accept</button>
+        <button type="button" class="admin-requests-btn admin-requests-btn-reject">Decline</button>
+      `;
+      const [acceptBtn, declineBtn] = row.querySelectorAll("button");
+      acceptBtn.addEventListener("click", async () => {
+        if (await post(`/api/owner/invites/${encodeURIComponent(inv.id)}/accept`)) {
+          loadServers();
+        }
+      });
+      declineBtn.addEventListener("click", async () => {
+        if (await post(`/api/owner/invites/${encodeURIComponent(inv.id)}/decline`)) {
+          loadServers();
+        }
+      });
+      invitesEl.appendChild(row);
+    });
+  }
+
+  function renderClaims(claims) {
+    if (!claimsEl) return;
+    claimsEl.innerHTML = "";
+    // Approved claims already show up as servers below.
+    const open = claims.filter((cl) => cl.status !== "approved");
+    claimsEl.classList.toggle("hidden", open.length === 0);
+
+    open.forEach((cl) => {
+      const row = document.createElement("div");
+      row.className = "notice owner-invite";
+      const state =
+        cl.status === "pending" ? "is waiting for review" : "was rejected";
+      row.innerHTML = `
+        <span>Your claim for <strong>${escapeHtml(cl.server_name)}</strong> ${state}
+        (${formatDate(cl.created_at)}).</span>
+      `;
+      // Verifying the domain backs the claim up for the admin reviewing it.
+      if (cl.status === "pending") {
+        row.appendChild(
+          renderVerification(
+            `/api/owner/claims/${encodeURIComponent(cl.id)}/verification`,
+            cl.verified_domain
+          )
+        );
+      }
+      claimsEl.appendChild(row);
+    });
+  }
+
+  // renderVerification walks through proving control of a domain against
+  // the verification endpoint at base, for a listing or a claim.
+  function renderVerification(base, verifiedDomain) {
+    const wrap = document.createElement("div");
+    wrap.className = "owner-verification";
+
+    if (verifiedDomain) {
+      wrap.innerHTML = `<span class="verified-badge">Verified owner of ${escapeHtml(
+        verifiedDomain
+      )}</span>`;
+      return wrap;
+    }
+
+    wrap.innerHTML = `
+      <div>
+        <button type="button" class="btn-secondary owner-verify-start">Verify domain</button>
+      </div>
+      <div class="owner-verify-steps hidden"></div>
+    `;
+    const steps = wrap.querySelector(".owner-verify-steps");
+
+    function showSteps(v) {
+      steps.innerHTML = `
+        <span>Prove you control <strong>${escapeHtml(v.domain)}</strong> with either:</span>
+        <span>a DNS TXT record on ${escapeHtml(v.domain)}: <code>${escapeHtml(v.txt_record)}</code></span>
+        <span>or a file at <code>${escapeHtml(v.well_known_url)}</code> containing <code>${escapeHtml(v.token)}</code></span>
+        ${
+          v.last_error
+            ? `<span class="notice notice-error">Last check failed: ${escapeHtml(v.last_error)}</span>`
+            : ""
+        }
+        <div><button type="button" class="btn-secondary owner-verify-check">Check now</button></div>
+      `;
+      steps.classList.remove("hidden");
+      steps.querySelector(".owner-verify-check").addEventListener("click", async () => {
+        errorEl.classList.add("hidden");
+        const res = await fetch(`${base}/check`, {
+          method: "POST",
+          credentials: "include",
+          headers: { Accept: "application/json" },
+        });
+        if (res.ok) {
+          loadServers();
+        } else if (res.status === 422) {
+          showSteps(await res.json());
+        } else {
+          showError((await res.text()) || "Couldn't check the domain.");
+        }
+      });
+    }
+
+    wrap.querySelector(".owner-verify-start").addEventListener("click", async () => {
+      errorEl.classList.add("hidden");
+      const res = await fetch(base, {
+        credentials: "include",
+        headers: { Accept: "application/json" },
+      });
+      if (!res.ok) {
+        showError((await res.text()) || "Couldn't start verification.");
+        return;
+      }
+      showSteps(await res.json());
+    });
+
+    return wrap;
+  }
+
+  // renderScreenshots manages the gallery. New screenshots and caption
+  // changes wait for an admin; the order applies straight away.
+  function renderScreenshots(server) {
+    const shots = server.screenshots || [];
+    const base = `/api/owner/servers/${encodeURIComponent(server.id)}/screenshots`;
+    const wrap = document.createElement("div");
+    wrap.className = "owner-screenshots";
+
+    const statusLabel = (s) => {
+      if (s.status === "approved" && s.caption_pending) return "caption in review";
+      if (s.status === "approved") return "live";
+      if (s.status === "rejected") return "rejected";
+      return "in review";
+    };
+
+    const items = shots
+      .map(
+        (s, i) => `
+          <li class="owner-screenshot" data-id="${escapeAttribute(s.id)}">
+            <img src="${escapeAttribute(s.thumb_url)}" alt="" />
+            <div class="owner-screenshot-body">
+              <span class="admin-requests-tag">${statusLabel(s)}</span>
+              <input class="server-form-input" name="caption" maxlength="200"
+                placeholder="Caption"
+                value="${escapeAttribute(s.caption_pending ? s.pending_caption : s.caption)}"
+                ${s.status === "rejected" ? "disabled" : ""} />
+              <div class="owner-screenshot-actions">
+                ${
+                  s.status === "rejected"
+                    ? ""
+                    : `<button type="button" class="admin-requests-btn" data-action="caption">Save caption</button>`
+                }
+                <button type="button" class="admin-requests-btn" data-action="up" ${i === 0 ? "disabled" : ""}>↑</button>
+                <button type="button" class="admin-requests-btn" data-action="down" ${
+                  i === shots.length - 1 ? "disabled" : ""
+                }>↓</button>
+                <button type="button" class="admin-requests-btn admin-requests-btn-reject" data-action="remove">Remove</button>
+              </div>
+            </div>
+          </li>
+        `
+      )
+      .join("");
+
+    const active = shots.filter((s) => s.status !== "rejected").length;
+
+    wrap.innerHTML = `
+      <h4 class="owner-team-title">Screenshots (${active}/8)</h4>
+      ${items ? `<ul class="owner-screenshot-list">${items}</ul>` : ""}
+      ${
+        active < 8
+          ? `<form class="owner-screenshot-form">
+               <input class="server-form-input" type="file" name="screenshot" required
+                 accept="image/png,image/jpeg,image/webp" />
+               <input class="server-form-input" name="caption" maxlength="200" placeholder="Caption (optional)" />
+               <button type="submit" class="btn-secondary">Upload</button>
+             </form>
+             <div class="server-form-helper">PNG, JPEG or WebP up to 5 MB. Screenshots appear once an admin approves them.</div>`
+          : ""
+      }
+    `;
+
+    wrap.querySelectorAll(".owner-screenshot").forEach((li) => {
+      const id = Number(li.dataset.id);
+      li.querySelectorAll("button[data-action]").forEach((btn) => {
+        btn.addEventListener("click", async () => {
+          let ok = false;
+          const action = btn.dataset.action;
+          if (action === "caption") {
+            ok = await post(`${base}/${id}`, { caption: li.querySelector("input[name=caption]").value.trim() });
+          } else if (action === "remove") {
+            if (!confirm("Remove this screenshot?")) return;
+            ok = await post(`${base}/${id}/remove`);
+          } else {
+            const ids = shots.map((s) => s.id);
+            const from = ids.indexOf(id);
+            const to = action === "up" ? from - 1 : from + 1;
+            [ids[from], ids[to]] = [ids[to], ids[from]];
+            ok = await post(`${base}/order`, { ids });
+          }
+          if (ok) loadServers();
+        });
+      });
+    });
+
+    const form = wrap.querySelector(".owner-screenshot-form");
+    if (form) {
+      form.addEventListener("submit", async (e) => {
+        e.preventDefault();
+        errorEl.classList.add("hidden");
+        const res = await fetch(base, {
+          method: "POST",
+          credentials: "include",
+          body: new FormData(form),
+        });
+        if (!res.ok) {
+          showError((await res.text()) || "Couldn't upload the screenshot.");
+          return;
+        }
+        loadServers();
+      });
+    }
+
+    return wrap;
+  }
+
+  // renderAnnouncements lists the team's posts and a form for a new one.
+  // Start and end are optional; together they make the post an event.
+  function renderAnnouncements(server) {
+    const posts = server.announcements || [];
+    const base = `/api/owner/servers/${encodeURIComponent(server.id)}/announcements`;
+    const wrap = document.createElement("div");
+    wrap.className = "owner-announcements";
+
+    const items = posts
+      .map(
+        (a) => `
+          <li data-id="${escapeAttribute(a.id)}">
+            <strong>${escapeHtml(a.title)}</strong>
+            ${a.hidden ? `<span class="admin-requests-tag">hidden by an admin</span>` : ""}
+            <span class="server-form-helper">
+              ${escapeHtml(a.starts_at ? formatDate(a.starts_at) : `posted ${formatDate(a.created_at)}`)}
+            </span>
+            <button type="button" class="admin-requests-btn admin-requests-btn-reject" data-action="remove">Remove</button>
+          </li>
+        `
+      )
+      .join("");
+
+    wrap.innerHTML = `
+      <h4 class="owner-team-title">Announcements</h4>
+      ${items ? `<ul class="owner-team-list">${items}</ul>` : ""}
+      <form class="server-form owner-announcement-form">
+        <input class="server-form-input" name="title" required maxlength="120" placeholder="Title" />
+        <textarea class="server-form-textarea" name="body" required maxlength="2000"
+          placeholder="What's happening? Markdown is supported."></textarea>
+        <div class="server-form-row server-form-row-inline">
+          <span class="server-form-label">Starts</span>
+          <input class="server-form-input" type="datetime-local" name="starts_at" />
+          <span class="server-form-label">Ends</span>
+          <input class="server-form-input" type="datetime-local" name="ends_at" />
+        </div>
+        <button type="submit" class="btn-secondary">Post announcement</button>
+      </form>
+      <div class="server-form-helper">Posts go live straight away. A server can post 3 a day.</div>
+    `;
+
+    wrap.querySelectorAll("button[data-action=remove]").forEach((btn) => {
+      btn.addEventListener("click", async () => {
+        if (!confirm("Remove this announcement?")) return;
+        const id = btn.closest("li").dataset.id;
+        if (await post(`${base}/${encodeURIComponent(id)}/remove`)) loadServers();
+      });
+    });
+
+    const form = wrap.querySelector(".owner-announcement-form");
+    const isoTime = (v) => (v ? new Date(v).toISOString() : "");
+    form.addEventListener("submit", async (e) => {
+      e.preventDefault();
+      const ok = await post(base, {
+        title: form.elements["title"].value.trim(),
+        body: form.elements["body"].value.trim(),
+        starts_at: isoTime(form.elements["starts_at"].value),
+        ends_at: isoTime(form.elements["ends_at"].value),
+      });
+      if (ok) loadServers();
+    });
+
+    return wrap;
+  }
+
+  // renderReviews lists the server's reviews. The team can answer each
+  // review once.
+  function renderReviews(server) {
+    const reviews = server.reviews || [];
+    const base = `/api/owner/servers/${encodeURIComponent(server.id)}/reviews`;
+    const wrap = document.createElement("div");
+    wrap.className = "owner-reviews";
+
+    const items = reviews
+      .map(
+        (r) => `
+          <li data-id="${escapeAttribute(r.id)}">
+            <span class="server-review-stars">${"★".repeat(r.rating)}${"☆".repeat(5 - r.rating)}</span>
+            <strong>${escapeHtml(r.username)}</strong>
+            <span class="server-form-helper">${escapeHtml(formatDate(r.created_at))}</span>
+            ${r.body ? `<p class="server-review-body">${escapeHtml(r.body)}</p>` : ""}
+            ${
+              r.reply
+                ? `<div class="server-review-reply"><p>${escapeHtml(r.reply)}</p></div>`
+                : `<form class="owner-review-reply">
+                     <textarea class="server-form-textarea" name="reply" required maxlength="1000"
+                       placeholder="Reply publicly (one reply per review)"></textarea>
+                     <button type="submit" class="admin-requests-btn">Reply</button>
+                   </form>`
+            }
+          </li>
+        `
+      )
+      .join("");
+
+    wrap.innerHTML = `
+      <h4 class="owner-team-title">Reviews${
+        server.rating_count ? ` (${Number(server.rating).toFixed(1)} ★ from ${server.rating_count})` : ""
+      }</h4>
+      ${items ? `<ul class="owner-team-list">${items}</ul>` : `<div class="server-form-helper">No reviews yet.</div>`}
+    `;
+
+    wrap.querySelectorAll(".owner-review-reply").forEach((form) => {
+      form.addEventListener("submit", async (e) => {
+        e.preventDefault();
+        const id = form.closest("li").dataset.id;
+        const ok = await post(`${base}/${encodeURIComponent(id)}/reply`, {
+          reply: form.elements["reply"].value.trim(),
+        });
+        if (ok) loadServers();
+      });
+    });
+
+    return wrap;
+  }
+
+  function renderRequestActions(r) {
+    const wrap = document.createElement("div");
+    wrap.className = "owner-request-actions";
+    const base = `/api/owner/requests/${encodeURIComponent(r.id)}`;
+
+    if (r.kind === "new") {
+      wrap.innerHTML = `
+        <button type="button" class="admin-requests-btn owner-request-edit">Edit</button>
+        <form class="server-form owner-edit-form hidden">
+          <div class="server-form-row">
+            <label class="server-form-label">Server name <span>*</span></label>
+            <input class="server-form-input" name="server_name" required maxlength="80"
+              value="${escapeAttribute(r.server_name || "")}" />
+          </div>
+          <div class="server-form-row">
+            <label class="server-form-label">Website URL</label>
+            <input class="server-form-input" type="url" name="url" maxlength="200"
+              value="${escapeAttribute(r.url || "")}" />
+          </div>
+          <div class="server-form-row">
+            <label class="server-form-label">Short description</label>
+            <textarea class="server-form-textarea" name="description" maxlength="250">${escapeHtml(r.description || "")}</textarea>
+          </div>
+          <div class="server-form-row">
+            <label class="server-form-label">Full description</label>
+            <textarea class="server-form-textarea server-form-textarea-long" name="long_description" maxlength="10000">${escapeHtml(r.long_description || "")}</textarea>
+          </div>
+          ${featureFieldsHTML(r.features)}
+          ${linkFieldsHTML(r.links)}
+          <div class="server-form-row">
+            <label class="server-form-label">Tags</label>
+            <input class="server-form-input" name="tags" maxlength="200"
+              value="${escapeAttribute(r.tags || "")}" />
+          </div>
+          <div class="server-form-row">
+            <label class="server-form-label">Logo URL</label>
+            <input class="server-form-input" type="text" inputmode="url" name="logo_url" maxlength="300"
+              value="${escapeAttribute(r.logo_url || "")}" />
+            <input class="server-form-input" type="file" name="logo_file"
+              accept="image/png,image/jpeg,image/webp,image/gif" />
+          </div>
+          <div class="server-form-actions">
+            <button class="server-form-submit" type="submit">Save request</button>
+          </div>
+        </form>
+      `;
+
+      const form = wrap.querySelector("form");
+      bindLogoUpload(form);
+      wrap.querySelector(".owner-request-edit").addEventListener("click", () => {
+        form.classList.toggle("hidden");
+      });
+      form.addEventListener("submit", async (e) => {
+        e.preventDefault();
+        const ok = await post(base, {
+          server_name: form.elements["server_name"].value.trim(),
+          url: form.elements["url"].value.trim(),
+          description: form.elements["description"].value.trim(),
+          long_description: form.elements["long_description"].value.trim(),
+          features: readFeatures(form),
+          links: readLinks(form),
+          tags: form.elements["tags"].value
+            .split(",")
+            .map((t) => t.trim())
+            .filter(Boolean),
+          logo_url: form.elements["logo_url"].value.trim(),
+        });
+        if (ok) loadServers();
+      });
+    }
+
+    const withdrawBtn = document.createElement("button");
+    withdrawBtn.type = "button";
+    withdrawBtn.className = "admin-requests-btn admin-requests-btn-reject";
+    withdrawBtn.textContent = "Withdraw";
+    withdrawBtn.addEventListener("click", async () => {
+      if (!confirm(`Withdraw your request for ${r.server_name}?`)) return;
+      if (await post(`${base}/withdraw`)) loadServers();
+    });
+    wrap.prepend(withdrawBtn);
+
+    return wrap;
+  }
+
+  function renderRequests(requests) {
+    if (!requestsEl || !requestsListEl) return;
+    requestsListEl.innerHTML = "";
+    requestsEl.classList.toggle("hidden", requests.length === 0);
+
+    const labels = {
+      pending: "Waiting for review",
+      approved: "Approved",
+      rejected: "Rejected",
+      withdrawn: "Withdrawn",
+    };
+
+    requests.forEach((r) => {
+      const row = document.createElement("div");
+      row.className = `notice owner-invite owner-request-${r.status}`;
+      const what = r.kind === "edit" ? "Edit to" : "New listing";
+      row.innerHTML = `
+        <span>${what} <strong>${escapeHtml(r.server_name)}</strong>
+        (${formatDate(r.created_at)})</span>
+        <span class="owner-request-status">${escapeHtml(labels[r.status] || r.status)}</span>
+        ${
+          r.decision_label || r.decision_reason
+            ? `<span class="owner-request-reason">${escapeHtml(
+                [r.decision_label, r.decision_reason].filter(Boolean).join(": ")
+              )}</span>`
+            : ""
+        }
+      `;
+      if (r.status === "pending") {
+        row.appendChild(renderRequestActions(r));
+      }
+      requestsListEl.appendChild(row);
+    });
+  }
+
+  function renderServer(server) {
+    const card = document.createElement("article");
+    card.className = "owner-server";
+
+    const pending = server.pending_edit;
+    const pendingHtml = pending
+      ? `<div class="notice">
+           An edit you proposed on ${escapeHtml(formatDate(pending.created_at))}
+           is waiting for review. Saving again replaces it.
+         </div>`
+      : "";
+
+    // Pre-fill the form with the pending proposal so owners can refine it.
+    const draft = pending || server;
+
+    card.innerHTML = `
+      <header class="owner-server-header">
+        <h2 class="owner-server-name">
+          <a href="/servers/${encodeURIComponent(server.id)}">${escapeHtml(server.server_name || "")}</a>
+        </h2>
+        <button type="button" class="btn-secondary owner-edit-toggle">Edit listing</button>
+      </header>
+
+      <div class="server-metrics-row">
+        <div class="metric">
+          <span class="metric-label">Rank</span>
+          <span class="metric-value">#${server.rank ?? "–"}</span>
+        </div>
+        <div class="metric">
+          <span class="metric-label">Votes</span>
+          <span class="metric-value">${server.votes ?? 0}</span>
+        </div>
+        <div class="metric">
+          <span class="metric-label">Last 24h</span>
+          <span class="metric-value">${server.votes_today ?? 0}</span>
+        </div>
+        <div class="metric">
+          <span class="metric-label">Last 7 days</span>
+          <span class="metric-value">${server.votes_last_7_days ?? 0}</span>
+        </div>
+      </div>
+
+      ${pendingHtml}
+      ${
+        server.discord?.expired
+          ? `<div class="notice notice-error">
+               The Discord invite on this listing has expired or was revoked.
+               Add a new invite link so visitors can join.
+             </div>`
+          : ""
+      }
+
+      <form class="server-form owner-edit-form hidden">
+        <div class="server-form-row">
+          <label class="server-form-label">Server name <span>*</span></label>
+          <input class="server-form-input" name="server_name" required maxlength="80"
+            value="${escapeAttribute(draft.server_name || "")}" />
+        </div>
+        <div class="server-form-row">
+          <label class="server-form-label">Website URL</label>
+          <input class="server-form-input" type="url" name="url" maxlength="200"
+            value="${escapeAttribute(draft.url || "")}" />
+        </div>
+        <div class="server-form-row">
+          <label class="server-form-label">Short description</label>
+          <textarea class="server-form-textarea" name="description" maxlength="250">${escapeHtml(draft.description || "")}</textarea>
+        </div>
+        <div class="server-form-row">
+          <label class="server-form-label">Full description</label>
+          <textarea class="server-form-textarea server-form-textarea-long" name="long_description" maxlength="10000">${escapeHtml(draft.long_description || "")}</textarea>
+          <div class="server-form-helper">Markdown, shown on your server page.</div>
+        </div>
+        ${featureFieldsHTML(draft.features)}
+        ${linkFieldsHTML(draft.links)}
+        <div class="server-form-row">
+          <label class="server-form-label">Tags</label>
+          <input class="server-form-input" name="tags" maxlength="200"
+            value="${escapeAttribute(draft.tags || "")}" />
+          <div class="server-form-helper">Comma separated, for example: relax, autopilot.</div>
+        </div>
+        <div class="server-form-row">
+          <label class="server-form-label">Logo URL</label>
+          <input class="server-form-input" type="text" inputmode="url" name="logo_url" maxlength="300"
+            value="${escapeAttribute(draft.logo_url || "")}" />
+          <input class="server-form-input" type="file" name="logo_file"
+            accept="image/png,image/jpeg,image/webp,image/gif" />
+          <div class="server-form-helper">Or upload a PNG, JPEG, WebP or GIF up to 2 MB.</div>
+        </div>
+        <div class="server-form-actions">
+          <button class="server-form-submit" type="submit">Submit for review</button>
+        </div>
+      </form>
+    `;
+
+    if (server.role === "owner" && (server.url || server.verified_domain)) {
+      card.appendChild(
+        renderVerification(
+          `/api/owner/servers/${encodeURIComponent(server.id)}/verification`,
+          server.verified_domain
+        )
+      );
+    }
+    card.appendChild(renderScreenshots(server));
+    card.appendChild(renderAnnouncements(server));
+    card.appendChild(renderReviews(server));
+    card.appendChild(renderTeam(server));
+
+    const form = card.querySelector(".owner-edit-form");
+    bindLogoUpload(form);
+    card.querySelector(".owner-edit-toggle").addEventListener("click", () => {
+      form.classList.toggle("hidden");
+    });
+
+    form.addEventListener("submit", async (e) => {
+      e.preventDefault();
+      errorEl.classList.add("hidden");
+      if (successEl) successEl.classList.add("hidden");
+
+      const tags = form.elements["tags"].value
+        .split(",")
+        .map((t) => t.trim())
+        .filter(Boolean);
+
+      const res = await fetch(
+        `/api/owner/servers/${encodeURIComponent(server.id)}/edits`,
+        {
+          method: "POST",
+          credentials: "include",
+          headers: { "Content-Type": "application/json" },
+          body: JSON.stringify({
+            server_name: form.elements["server_name"].value.trim(),
+            url: form.elements["url"].value.trim(),
+            description: form.elements["description"].value.trim(),
+            long_description: form.elements["long_description"].value.trim(),
+            features: readFeatures(form),
+            links: readLinks(form),
+            tags,
+            logo_url: form.elements["logo_url"].value.trim(),
+          }),
+        }
+      );
+
+      if (!res.ok) {
+        showError((await res.text()) || "Couldn't save your changes.");
+        return;
+      }
+
+      if (successEl) successEl.classList.remove("hidden");
+      loadServers();
+    });
+
+    return card;
+  }
+
+  async function loadServers() {
+    try {
+      const opts = {
+        credentials: "include",
+        headers: { Accept: "application/json" },
+      };
+      const [res, meRes, invitesRes, claimsRes, requestsRes] = await Promise.all([
+        fetch("/api/owner/servers", opts),
+        fetch("/auth/me", opts),
+        fetch("/api/owner/invites", opts),
+        fetch("/api/owner/claims", opts),
+        fetch("/api/owner/requests", opts),
+      ]);
+
+      if (res.status === 401) {
+        if (loginNotice) loginNotice.classList.remove("hidden");
+        return;
+      }
+      if (!res.ok || !meRes.ok || !invitesRes.ok || !claimsRes.ok || !requestsRes.ok) throw new Error("HTTP " + res.status);
+
+      const data = await res.json();
+      const me = await meRes.json();
+      const invitesData = await invitesRes.json();
+      const claimsData = await claimsRes.json();
+      const requestsData = await requestsRes.json();
+      const servers = Array.isArray(data.servers) ? data.servers : [];
+
+      renderInvites(Array.isArray(invitesData.invites) ? invitesData.invites : []);
+      renderClaims(Array.isArray(claimsData.claims) ? claimsData.claims : []);
+      renderRequests(Array.isArray(requestsData.requests) ? requestsData.requests : []);
+
+      listEl.innerHTML = "";
+      servers.forEach((s) =>
+        listEl.appendChild(renderServer({ ...s, viewer_id: me.discord_id }))
+      );
+      if (emptyEl) emptyEl.classList.toggle("hidden", servers.length > 0);
+    } catch (_err) {
+      showError("Couldn't load your servers.");
+    }
+  }
+
+  loadServers();
+}
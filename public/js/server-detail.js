@@ -1,16 +1,13 @@
-import { formatDate, escapeAttribute, getServerIdFromPath } from "./dom-utils.js";
+import { getServerIdFromPath } from "./dom-utils.js";
 
 export function initServerDetail() {
   const root = document.getElementById("server-detail");
   if (!root) return;
 
-  const loading = document.getElementById("server-detail-loading");
   const error = document.getElementById("server-detail-error");
-  const body = document.getElementById("server-detail-body");
 
   const id = getServerIdFromPath();
   if (!id) {
-    if (loading) loading.classList.add("hidden");
     if (error) {
       error.textContent = "Invalid server URL.";
       error.classList.remove("hidden");
@@ -19,39 +16,9 @@ export function initServerDetail() {
   }
 
   initAdminRemoveButton(id);
-
-  if (loading) loading.classList.remove("hidden");
-  if (error) error.classList.add("hidden");
-  if (body) body.classList.add("hidden");
-
-  fetch(`/server/${encodeURIComponent(id)}`, {
-    headers: { Accept: "application/json" },
-    credentials: "include",
-  })
-    .then(async (res) => {
-      if (!res.ok) {
-        const text = await res.text();
-        if (loading) loading.classList.add("hidden");
-        if (error) {
-          error.textContent = text || "Couldn't load this server.";
-          error.classList.remove("hidden");
-        }
-        return;
-      }
-
-      const data = await res.json();
-      renderServerDetail(data);
-
-      if (loading) loading.classList.add("hidden");
-      if (body) body.classList.remove("hidden");
-    })
-    .catch(() => {
-      if (loading) loading.classList.add("hidden");
-      if (error) {
-        error.textContent = "Couldn't load this server.";
-        error.classList.remove("hidden");
-      }
-    });
+  initClaimForm(id);
+  initReviews(id);
+  initReportForm(id);
 
   const backBtn = document.getElementById("server-detail-back");
   if (backBtn) {
@@ -61,69 +28,6 @@ export function initServerDetail() {
   }
 }
 
-function renderServerDetail(server) {
-  const name = server.server_name || server.name || "";
-  const owner = server.owner || server.owner_name || "Unknown";
-  const description = server.description || "";
-  const votes = server.votes ?? 0;
-  const added = server.added || server.created_at || "";
-  const addedFormatted = formatDate(added);
-  const id = server.id ?? "";
-  const url = server.url || "";
-
-  const nameEl = document.getElementById("server-detail-name");
-  const ownerEl = document.getElementById("server-detail-owner");
-  const ownerShortEl = document.getElementById("server-detail-owner-short");
-  const addedEl = document.getElementById("server-detail-added");
-  const listedEl = document.getElementById("server-detail-listed");
-  const descEl = document.getElementById("server-detail-description");
-  const votesEl = document.getElementById("server-detail-votes");
-  const logoEl = document.getElementById("server-detail-logo");
-  const websiteEl = document.getElementById("server-detail-website");
-  const voteButton = document.getElementById("server-detail-vote");
-
-  if (nameEl) nameEl.textContent = name;
-  if (ownerEl) ownerEl.textContent = "Owner: " + owner;
-  if (ownerShortEl) ownerShortEl.textContent = owner;
-  if (addedEl) addedEl.textContent = "Added " + addedFormatted;
-  if (listedEl) listedEl.textContent = addedFormatted;
-  if (votesEl) votesEl.textContent = String(votes);
-  if (descEl) {
-    descEl.textContent =
-      description || "No description has been provided yet.";
-  }
-
-  if (logoEl) {
-    let logoUrl = server.logo_url || server.logo || "";
-    const normalizedName = String(name).trim().toLowerCase();
-    if (!logoUrl && normalizedName === "m1pposu") {
-      logoUrl = "/static/m1pplogo.png";
-    }
-    if (logoUrl) {
-      logoEl.innerHTML = `<img src="${escapeAttribute(
-        logoUrl
-      )}" alt="${escapeAttribute(name)} logo" />`;
-    } else {
-      logoEl.textContent = "";
-    }
-  }
-
-  if (websiteEl) {
-    if (url) {
-      websiteEl.href = url;
-      websiteEl.classList.remove("hidden");
-    } else {
-      websiteEl.href = "#";
-      websiteEl.classList.add("hidden");
-    }
-  }
-
-  if (voteButton) {
-    voteButton.setAttribute("data-server-id", String(id));
-    voteButton.setAttribute("data-server-name", name || "this server");
-  }
-}
-
 function initAdminRemoveButton(serverIdFromPath) {
   const removeBtn = document.getElementById("server-remove-btn");
   if (!removeBtn) return;
@@ -193,3 +97,176 @@ function initAdminRemoveButton(serverIdFromPath) {
 
   tryWire();
 }
+
+function initClaimForm(serverId) {
+  const root = document.getElementById("server-claim");
+  const toggle = document.getElementById("server-claim-toggle");
+  const form = document.getElementById("server-claim-form");
+  const status = document.getElementById("server-claim-status");
+  if (!root || !toggle || !form || !status) return;
+
+  fetch("/auth/me", { headers: { Accept: "application/json" } })
+    .then((res) => res.json())
+    .then((data) => {
+      if (data && data.authenticated) root.classList.remove("hidden");
+    })
+    .catch(() => {});
+
+  toggle.addEventListener("click", () => {
+    form.classList.toggle("hidden");
+  });
+
+  form.addEventListener("submit", async (e) => {
+    e.preventDefault();
+    status.classList.add("hidden");
+    status.classList.remove("notice-error", "notice-success");
+
+    const res = await fetch(`/api/servers/${encodeURIComponent(serverId)}/claims`, {
+      method: "POST",
+      credentials: "include",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ evidence: form.elements["evidence"].value.trim() }),
+    });
+
+    if (!res.ok) {
+      status.textContent = (await res.text()) || "Couldn't submit your claim.";
+      status.classList.add("notice-error");
+    } else {
+      status.textContent =
+        "Your claim was sent to the admins. You can follow it on your servers page.";
+      status.classList.add("notice-success");
+      form.reset();
+      form.classList.add("hidden");
+    }
+    status.classList.remove("hidden");
+  });
+}
+
+// initReviews shows the review form and report buttons to signed-in
+// visitors and fills the form with their existing review.
+function initReviews(serverId) {
+  const form = document.getElementById("server-review-form");
+  const status = document.getElementById("server-review-status");
+  const removeBtn = document.getElementById("server-review-remove");
+  if (!form || !status || !removeBtn) return;
+
+  const base = `/api/servers/${encodeURIComponent(serverId)}/reviews`;
+
+  const showStatus = (text, ok) => {
+    status.textContent = text;
+    status.classList.remove("hidden", "notice-error", "notice-success");
+    status.classList.add(ok ? "notice-success" : "notice-error");
+  };
+
+  fetch("/auth/me", { headers: { Accept: "application/json" } })
+    .then((res) => res.json())
+    .then(async (data) => {
+      if (!data || !data.authenticated) return;
+      form.classList.remove("hidden");
+      document
+        .querySelectorAll(".server-review-report")
+        .forEach((btn) => btn.classList.remove("hidden"));
+
+      const res = await fetch(`${base}/mine`, { credentials: "include" });
+      if (!res.ok) return;
+      const mine = await res.json();
+      form.elements["rating"].value = String(mine.rating);
+      form.elements["body"].value = mine.body || "";
+      removeBtn.classList.remove("hidden");
+    })
+    .catch(() => {});
+
+  form.addEventListener("submit", async (e) => {
+    e.preventDefault();
+    const res = await fetch(base, {
+      method: "POST",
+      credentials: "include",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({
+        rating: Number(form.elements["rating"].value),
+        body: form.elements["body"].value.trim(),
+      }),
+    });
+    if (!res.ok) {
+      showStatus((await res.text()) || "Couldn't save your review.", false);
+      return;
+    }
+    window.location.reload();
+  });
+
+  removeBtn.addEventListener("click", async () => {
+    if (!confirm("Delete your review?")) return;
+    const res = await fetch(`${base}/remove`, { method: "POST", credentials: "include" });
+    if (!res.ok) {
+      showStatus((await res.text()) || "Couldn't delete your review.", false);
+      return;
+    }
+    window.location.reload();
+  });
+
+  document.querySelectorAll(".server-review-report").forEach((btn) => {
+    btn.addEventListener("click", async () => {
+      const review = btn.closest("[data-review-id]");
+      const reason = prompt("Why should the admins look at this review?");
+      if (reason === null) return;
+      const res = await fetch(`/api/reviews/${encodeURIComponent(review.dataset.reviewId)}/report`, {
+        method: "POST",
+        credentials: "include",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ reason: reason.trim() }),
+      });
+      if (!res.ok) {
+        showStatus((await res.text()) || "Couldn't send your report.", false);
+        return;
+      }
+      btn.disabled = true;
+      showStatus("Thanks, the admins will take a look.", true);
+    });
+  });
+}
+
+function initReportForm(serverId) {
+  const root = document.getElementById("server-report");
+  const toggle = document.getElementById("server-report-toggle");
+  const form = document.getElementById("server-report-form");
+  const status = document.getElementById("server-report-status");
+  if (!root || !toggle || !form || !status) return;
+
+  fetch("/auth/me", { headers: { Accept: "application/json" } })
+    .then((res) => res.json())
+    .then((data) => {
+      if (data && data.authenticated) root.classList.remove("hidden");
+    })
+    .catch(() => {});
+
+  toggle.addEventListener("click", () => {
+    form.classList.toggle("hidden");
+  });
+
+  form.addEventListener("submit", async (e) => {
+    e.preventDefault();
+    status.classList.add("hidden");
+    status.classList.remove("notice-error", "notice-success");
+
+    const res = await fetch(`/api/servers/${encodeURIComponent(serverId)}/reports`, {
+      method: "POST",
+      credentials: "include",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({
+        category: form.elements["category"].value,
+        details: form.elements["details"].value.trim(),
+      }),
+    });
+
+    if (!res.ok) {
+      status.textContent = (await res.text()) || "Couldn't send your report.";
+      status.classList.add("notice-error");
+    } else {
+      status.textContent = "Thanks, the admins will look into it.";
+      status.classList.add("notice-success");
+      form.reset();
+      form.classList.add("hidden");
+    }
+    status.classList.remove("hidden");
+  });
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// rateLimiter is a fixed-window counter keyed by caller (API key or IP).
+type rateLimiter struct {
+	mu        sync.Mutex
+	window    time.Duration
+	windows   map[string]*rateWindow
+	lastSweep time.Time
+}
+
+func newRateLimiter(window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		window:  window,
+		windows: make(map[string]*rateWindow),
+	}
+}
+
+// allow counts one hit for key against limit and reports whether it is
+// allowed, how many hits remain and when the current window resets.
+func (l *rateLimiter) allow(key string, limit int) (bool, int, time.Time) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > l.window {
+		for k, w := range l.windows {
+			if now.Sub(w.start) >= l.window {
+				delete(l.windows, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.window {
+		w = &rateWindow{start: now}
+		l.windows[key] = w
+	}
+
+	reset := w.start.Add(l.window)
+	if w.count >= limit {
+		return false, 0, reset
+	}
+	w.count++
+	return true, limit - w.count, reset
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// defaultRejectionReasons are offered when MOSS_REJECTION_REASONS is unset.
+var defaultRejectionReasons = []RejectionReason{
+	{Code: "offline", Label: "The server was offline when we checked it"},
+	{Code: "incomplete", Label: "The listing is missing required details"},
+	{Code: "duplicate", Label: "This server is already listed"},
+	{Code: "not_private_server", Label: "This isn't an osu! private server"},
+	{Code: "inappropriate", Label: "The listing has inappropriate content"},
+	{Code: "other", Label: "Something else"},
+}
+
+// rejectionReasons returns the canned reasons admins pick from when they
+// reject a request. MOSS_REJECTION_REASONS replaces the defaults with
+// "code=Label" entries separated by semicolons.
+func rejectionReasons() []RejectionReason {
+	raw := strings.TrimSpace(os.Getenv("MOSS_REJECTION_REASONS"))
+	if raw == "" {
+		return defaultRejectionReasons
+	}
+
+	reasons := make([]RejectionReason, 0, 8)
+	for _, entry := range strings.Split(raw, ";") {
+		code, label, ok := strings.Cut(entry, "=")
+		code, label = strings.TrimSpace(code), strings.TrimSpace(label)
+		if !ok || code == "" || label == "" {
+			if strings.TrimSpace(entry) != "" {
+				log.Printf("MOSS_REJECTION_REASONS: ignoring %q", entry)
+			}
+			continue
+		}
+		reasons = append(reasons, RejectionReason{Code: code, Label: label})
+	}
+	if len(reasons) == 0 {
+		return defaultRejectionReasons
+	}
+	return reasons
+}
+
+// rejectionReasonLabel returns the label of a canned reason. Codes that were
+// dropped from the configuration since are shown as they are.
+func rejectionReasonLabel(code string) string {
+	for _, r := range rejectionReasons() {
+		if r.Code == code {
+			return r.Label
+		}
+	}
+	return code
+}
+
+func validRejectionCode(code string) bool {
+	for _, r := range rejectionReasons() {
+		if r.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// formatRejection joins a request's canned reason and the admin's note.
+func formatRejection(r *ServerRequest) string {
+	switch {
+	case r.DecisionLabel != "" && r.DecisionReason != "":
+		return r.DecisionLabel + ": " + r.DecisionReason
+	case r.DecisionLabel != "":
+		return r.DecisionLabel
+	default:
+		return r.DecisionReason
+	}
+}
+
+func getAdminRejectionReasonsHandler(c fiber.Ctx) error {
+	if _, err := requireAdmin(c); err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{"reasons": rejectionReasons()})
+}
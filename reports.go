@@ -0,0 +1,351 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	maxReportDetails        = 2000
+	maxReportNote           = 1000
+	defaultReportThreshold  = 3
+	reportSubmissionsPerDay = 10
+)
+
+// reportCategory is one reason a listing can be reported for.
+type reportCategory struct {
+	Name, Label string
+}
+
+// reportCategories are the accepted categories, in display order.
+var reportCategories = []reportCategory{
+	{"scam", "Scam or fraud"},
+	{"malware", "Malware in the client or downloads"},
+	{"impersonation", "Impersonates another server"},
+	{"inappropriate", "Inappropriate content"},
+	{"other", "Something else"},
+}
+
+// reportStatuses are the states a report moves through in the admin queue.
+var reportStatuses = []string{"open", "investigating", "resolved", "dismissed"}
+
+var reportLimiter = newRateLimiter(24 * time.Hour)
+
+func validReportCategory(name string) bool {
+	for _, c := range reportCategories {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// reportThreshold is how many open reports a listing collects before the
+// admins are pinged. MOSS_REPORT_THRESHOLD overrides it.
+func reportThreshold() int {
+	if v := strings.TrimSpace(os.Getenv("MOSS_REPORT_THRESHOLD")); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultReportThreshold
+}
+
+const serverReportSelect = `
+	SELECT sr.id,
+	       sr.server_id,
+	       COALESCE(s.server_name, ''),
+	       sr.reporter_id,
+	       sr.reporter_name,
+	       sr.category,
+	       COALESCE(sr.details, ''),
+	       sr.status,
+	       COALESCE(sr.admin_note, ''),
+	       sr.created_at,
+	       COALESCE(strftime('%Y-%m-%dT%H:%M:%SZ', sr.updated_at), ''),
+	       (
+	           SELECT COUNT(*)
+	           FROM server_reports o
+	           WHERE o.server_id = sr.server_id AND o.status IN ('open', 'investigating')
+	       ) AS open_reports
+	FROM server_reports sr
+	LEFT JOIN servers s
+	  ON s.id = sr.server_id
+`
+
+func scanServerReport(row rowScanner) (ServerReport, error) {
+	var r ServerReport
+	err := row.Scan(
+		&r.ID,
+		&r.ServerID,
+		&r.ServerName,
+		&r.ReporterID,
+		&r.ReporterName,
+		&r.Category,
+		&r.Details,
+		&r.Status,
+		&r.AdminNote,
+		&r.CreatedAt,
+		&r.UpdatedAt,
+		&r.OpenReports,
+	)
+	return r, err
+}
+
+func queryServerReports(where string, args ...any) ([]ServerReport, error) {
+	rows, err := Database.Query(serverReportSelect+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]ServerReport, 0, 16)
+	for rows.Next() {
+		r, err := scanServerReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+const duplicateReportMessage = "You already reported this listing. The admins are looking into it."
+
+// markReportThreshold records that a listing's open reports reached the
+// threshold. It returns true only for the report that got it there, so the
+// admins are pinged once until clearReportThreshold resets the mark.
+func markReportThreshold(serverID int) (bool, error) {
+	res, err := Database.Exec(`
+		UPDATE servers
+		SET threshold_notified_at = datetime('now')
+		WHERE id = ?
+		  AND threshold_notified_at IS NULL
+		  AND (
+		      SELECT COUNT(*) FROM server_reports
+		      WHERE server_id = servers.id AND status IN ('open', 'investigating')
+		  ) >= ?
+	`, serverID, reportThreshold())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// clearReportThreshold resets the mark once a listing's open reports drop
+// below the threshold, so a later wave of reports pings the admins again.
+func clearReportThreshold(serverID int) error {
+	_, err := Database.Exec(`
+		UPDATE servers
+		SET threshold_notified_at = NULL
+		WHERE id = ?
+		  AND threshold_notified_at IS NOT NULL
+		  AND (
+		      SELECT COUNT(*) FROM server_reports
+		      WHERE server_id = servers.id AND status IN ('open', 'investigating')
+		  ) < ?
+	`, serverID, reportThreshold())
+	return err
+}
+
+// postServerReportHandler files an abuse report against a listing. A user
+// has one report per listing; once the admins close it, reporting again
+// reopens it.
+func postServerReportHandler(c fiber.Ctx) error {
+	u, ok := getSessionUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).SendString("Sign in with Discord to report a listing.")
+	}
+	id, err := existingServerID(c)
+	if err != nil {
+		return err
+	}
+
+	type reportPayload struct {
+		Category string `json:"category"`
+		Details  string `json:"details"`
+	}
+
+	var payload reportPayload
+	if err := c.Bind().Body(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid json body")
+	}
+	category := strings.ToLower(strings.TrimSpace(payload.Category))
+	if !validReportCategory(category) {
+		return c.Status(fiber.StatusBadRequest).SendString("Pick what the report is about.")
+	}
+	details := strings.TrimSpace(payload.Details)
+	if category == "other" && details == "" {
+		return c.Status(fiber.StatusBadRequest).SendString("Describe the problem so an admin can check it.")
+	}
+	if utf8.RuneCountInString(details) > maxReportDetails {
+		return c.Status(fiber.StatusBadRequest).SendString(
+			fmt.Sprintf("Details must be at most %d characters.", maxReportDetails),
+		)
+	}
+
+	// A duplicate is turned away before it counts against the daily limit.
+	var open bool
+	if err := Database.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM server_reports
+			WHERE server_id = ? AND reporter_id = ? AND status IN ('open', 'investigating')
+		)
+	`, id, u.DiscordID).Scan(&open); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to check reports")
+	}
+	if open {
+		return c.Status(fiber.StatusConflict).SendString(duplicateReportMessage)
+	}
+
+	if allowed, _, _ := reportLimiter.allow(u.DiscordID, reportSubmissionsPerDay); !allowed {
+		return c.Status(fiber.StatusTooManyRequests).SendString("Too many reports, try again later.")
+	}
+
+	res, err := Database.Exec(`
+		INSERT INTO server_reports (
+			server_id, reporter_id, reporter_name, category, details, status, created_at
+		)
+		VALUES (?, ?, ?, ?, ?, 'open', datetime('now'))
+		ON CONFLICT(server_id, reporter_id) DO UPDATE SET
+			reporter_name = excluded.reporter_name,
+			category      = excluded.category,
+			details       = excluded.details,
+			status        = 'open',
+			admin_note    = NULL,
+			created_at    = excluded.created_at,
+			updated_at    = NULL,
+			updated_by    = NULL
+		WHERE server_reports.status IN ('resolved', 'dismissed')
+	`, id, u.DiscordID, u.Username, category, nullEmpty(details))
+	if err != nil {
+		log.Println("insert server_report:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to save report")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return c.Status(fiber.StatusConflict).SendString(duplicateReportMessage)
+	}
+
+	notify, err := markReportThreshold(id)
+	if err != nil {
+		log.Println("markReportThreshold:", err)
+	}
+	if notify {
+		r, err := scanServerReport(Database.QueryRow(serverReportSelect+`
+			WHERE sr.server_id = ? AND sr.reporter_id = ?
+		`, id, u.DiscordID))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("failed to load report")
+		}
+		notifyServerReportThreshold(&r)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"ok": true})
+}
+
+// getAdminReportsHandler lists abuse reports. By default only open and
+// investigating ones are shown; ?status= picks one status or "all".
+func getAdminReportsHandler(c fiber.Ctx) error {
+	if _, err := requireAdmin(c); err != nil {
+		return err
+	}
+
+	where := `WHERE sr.status IN ('open', 'investigating')`
+	args := []any{}
+	switch status := strings.TrimSpace(c.Query("status")); {
+	case status == "all":
+		where = ""
+	case containsString(reportStatuses, status):
+		where = `WHERE sr.status = ?`
+		args = append(args, status)
+	case status != "":
+		return c.Status(fiber.StatusBadRequest).SendString("unknown status")
+	}
+
+	reports, err := queryServerReports(where+`
+		ORDER BY open_reports DESC, sr.server_id, sr.created_at
+	`, args...)
+	if err != nil {
+		log.Println("getAdminReportsHandler:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load reports")
+	}
+
+	return c.JSON(fiber.Map{
+		"reports":    reports,
+		"categories": reportCategories,
+		"statuses":   reportStatuses,
+	})
+}
+
+// postAdminUpdateReportHandler moves a report to another status and/or
+// sets the admins' note on it.
+func postAdminUpdateReportHandler(c fiber.Ctx) error {
+	admin, err := requireAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	type updatePayload struct {
+		Status string  `json:"status"`
+		Note   *string `json:"note"`
+	}
+
+	var payload updatePayload
+	if err := c.Bind().Body(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid json body")
+	}
+	if payload.Status != "" && !containsString(reportStatuses, payload.Status) {
+		return c.Status(fiber.StatusBadRequest).SendString("unknown status")
+	}
+	note := ""
+	if payload.Note != nil {
+		note = strings.TrimSpace(*payload.Note)
+		if utf8.RuneCountInString(note) > maxReportNote {
+			return c.Status(fiber.StatusBadRequest).SendString(
+				fmt.Sprintf("Notes must be at most %d characters.", maxReportNote),
+			)
+		}
+	}
+
+	before := auditRow(queryServerReports, `WHERE sr.id = ?`, c.Params("id"))
+
+	res, err := Database.Exec(`
+		UPDATE server_reports
+		SET status     = COALESCE(NULLIF(?, ''), status),
+		    admin_note = CASE WHEN ? THEN NULLIF(?, '') ELSE admin_note END,
+		    updated_at = datetime('now'),
+		    updated_by = ?
+		WHERE id = ?
+	`, payload.Status, payload.Note != nil, note, admin.DiscordID, c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to update report")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return c.Status(fiber.StatusNotFound).SendString("report not found")
+	}
+
+	r, err := scanServerReport(Database.QueryRow(serverReportSelect+`WHERE sr.id = ?`, c.Params("id")))
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).SendString("report not found")
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load report")
+	}
+
+	if err := clearReportThreshold(r.ServerID); err != nil {
+		log.Println("clearReportThreshold:", err)
+	}
+
+	recordAdminAction(c, "report.update", "report", r.ID, before, r)
+
+	return c.JSON(r)
+}
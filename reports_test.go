@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func fileReport(t *testing.T, srv *httptest.Server, serverID int, reporterID string) int {
+	t.Helper()
+
+	status, _ := testRequest(t, srv, http.MethodPost, "/api/servers/"+strconv.Itoa(serverID)+"/reports",
+		testSessionToken(t, reporterID), map[string]string{"category": "scam"})
+	return status
+}
+
+func TestReportThresholdPingsOncePerCrossing(t *testing.T) {
+	srv := newTestServer(t)
+	discord := useDiscordStandIn(t)
+	t.Setenv("MOSS_REPORT_THRESHOLD", "2")
+	admin := testSessionToken(t, testAdminID)
+
+	id := insertTestServer(t, "Reported", "https://reported.example", 0)
+	webhooks := func() int {
+		_, n, _ := discord.counts()
+		return n
+	}
+
+	for i, want := range []int{0, 1, 1, 1} {
+		if status := fileReport(t, srv, id, "20000000000000000"+strconv.Itoa(i)); status != http.StatusCreated {
+			t.Fatalf("report %d: status %d", i, status)
+		}
+		if got := webhooks(); got != want {
+			t.Fatalf("after report %d: %d pings, want %d", i, got, want)
+		}
+	}
+
+	// Dismissing all but one report re-arms the alert.
+	rows, err := Database.Query(`SELECT id FROM server_reports WHERE server_id = ? ORDER BY id LIMIT 3`, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for rows.Next() {
+		var reportID int
+		rows.Scan(&reportID)
+		ids = append(ids, reportID)
+	}
+	rows.Close()
+	for _, reportID := range ids {
+		status, body := testRequest(t, srv, http.MethodPost, "/api/admin/reports/"+strconv.Itoa(reportID), admin,
+			map[string]string{"status": "dismissed"})
+		if status != http.StatusOK {
+			t.Fatalf("dismiss: %d %s", status, body)
+		}
+	}
+	if got := webhooks(); got != 1 {
+		t.Fatalf("%d pings after dismissing, want 1", got)
+	}
+
+	if status := fileReport(t, srv, id, "200000000000000010"); status != http.StatusCreated {
+		t.Fatalf("new report: status %d", status)
+	}
+	if got := webhooks(); got != 2 {
+		t.Fatalf("%d pings after the listing crossed the threshold again, want 2", got)
+	}
+}
+
+func TestDuplicateReportsDoNotCountAgainstTheLimit(t *testing.T) {
+	srv := newTestServer(t)
+	const reporter = "200000000000000100"
+
+	first := insertTestServer(t, "First", "https://first.example", 0)
+	if status := fileReport(t, srv, first, reporter); status != http.StatusCreated {
+		t.Fatalf("report: status %d", status)
+	}
+	for i := 0; i < reportSubmissionsPerDay+5; i++ {
+		if status := fileReport(t, srv, first, reporter); status != http.StatusConflict {
+			t.Fatalf("duplicate %d: status %d, want 409", i, status)
+		}
+	}
+
+	second := insertTestServer(t, "Second", "https://second.example", 0)
+	if status := fileReport(t, srv, second, reporter); status != http.StatusCreated {
+		t.Fatalf("report after duplicates: status %d, want 201", status)
+	}
+}
@@ -0,0 +1,505 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	maxReviewBody       = 2000
+	maxReviewReply      = 1000
+	maxReviewReport     = 500
+	reviewsOnPage       = 50
+	reviewPostsPerHour  = 10
+	defaultReviewMinAge = 30 * 24 * time.Hour
+
+	// reviewMinVoteAge is how long before reviewing a younger account must
+	// have voted for the server.
+	reviewMinVoteAge = 24 * time.Hour
+
+	// discordEpoch is the start of Discord's snowflake clock, in Unix
+	// milliseconds.
+	discordEpoch = 1420070400000
+)
+
+var reviewLimiter = newRateLimiter(time.Hour)
+
+// reviewMinAccountAge is how old a Discord account must be to review a
+// server without having voted for it. MOSS_REVIEW_MIN_ACCOUNT_DAYS
+// overrides it.
+func reviewMinAccountAge() time.Duration {
+	if v := strings.TrimSpace(os.Getenv("MOSS_REVIEW_MIN_ACCOUNT_DAYS")); v != "" {
+		if days, err := strconv.Atoi(v); err == nil && days >= 0 {
+			return time.Duration(days) * 24 * time.Hour
+		}
+	}
+	return defaultReviewMinAge
+}
+
+// discordAccountCreated reads the creation time encoded in a Discord id.
+func discordAccountCreated(discordID string) (time.Time, bool) {
+	id, err := strconv.ParseUint(discordID, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(int64(id>>22) + discordEpoch), true
+}
+
+// canReview reports whether u may review a server: the account has to be
+// old enough, or it must have voted for the server while signed in at least
+// reviewMinVoteAge ago. Both make brigading with fresh accounts harder.
+func canReview(u *SessionUser, serverID int) (bool, error) {
+	if created, ok := discordAccountCreated(u.DiscordID); ok && time.Since(created) >= reviewMinAccountAge() {
+		return true, nil
+	}
+
+	var voted bool
+	err := Database.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM votes
+			WHERE server = ? AND discord_id = ? AND last_vote <= ?
+		)
+	`, serverID, u.DiscordID, time.Now().UTC().Add(-reviewMinVoteAge).Format(time.DateTime)).Scan(&voted)
+	return voted, err
+}
+
+// stars draws a rating as filled and empty stars.
+func stars(rating int) string {
+	rating = max(0, min(rating, 5))
+	return strings.Repeat("★", rating) + strings.Repeat("☆", 5-rating)
+}
+
+const reviewSelect = `
+	SELECT r.id,
+	       r.server_id,
+	       COALESCE(s.server_name, ''),
+	       r.username,
+	       r.rating,
+	       COALESCE(r.body, ''),
+	       r.created_at,
+	       COALESCE(strftime('%Y-%m-%dT%H:%M:%SZ', r.updated_at), ''),
+	       COALESCE(r.reply, ''),
+	       COALESCE(r.reply_by, ''),
+	       COALESCE(strftime('%Y-%m-%dT%H:%M:%SZ', r.replied_at), ''),
+	       r.hidden_at IS NOT NULL,
+	       (SELECT COUNT(*) FROM review_reports rr WHERE rr.review_id = r.id AND rr.resolved_at IS NULL),
+	       COALESCE((
+	           SELECT group_concat(rr.reason, char(10))
+	           FROM review_reports rr
+	           WHERE rr.review_id = r.id AND rr.resolved_at IS NULL AND rr.reason != ''
+	       ), '')
+	FROM server_reviews r
+	LEFT JOIN servers s
+	  ON s.id = r.server_id
+`
+
+func scanReview(row rowScanner) (Review, error) {
+	var (
+		r       Review
+		reasons string
+	)
+	err := row.Scan(
+		&r.ID,
+		&r.ServerID,
+		&r.ServerName,
+		&r.Username,
+		&r.Rating,
+		&r.Body,
+		&r.CreatedAt,
+		&r.UpdatedAt,
+		&r.Reply,
+		&r.ReplyBy,
+		&r.RepliedAt,
+		&r.Hidden,
+		&r.Reports,
+		&reasons,
+	)
+	if reasons != "" {
+		r.ReportReasons = strings.Split(reasons, "\n")
+	}
+	return r, err
+}
+
+func queryReviews(where string, args ...any) ([]Review, error) {
+	rows, err := Database.Query(reviewSelect+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Review, 0, 16)
+	for rows.Next() {
+		r, err := scanReview(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// publicReviews returns a server's visible reviews, newest first, without
+// moderation fields.
+func publicReviews(serverID int) ([]Review, error) {
+	reviews, err := queryReviews(`
+		WHERE r.server_id = ? AND r.hidden_at IS NULL
+		ORDER BY r.created_at DESC, r.id DESC
+		LIMIT ?
+	`, serverID, reviewsOnPage)
+	if err != nil {
+		return nil, err
+	}
+	for i := range reviews {
+		reviews[i] = withoutReports(reviews[i])
+		reviews[i].ServerName = ""
+	}
+	return reviews, nil
+}
+
+// withoutReports strips what admins see about reports, which neither the
+// author nor the server's team should learn.
+func withoutReports(r Review) Review {
+	r.Reports = 0
+	r.ReportReasons = nil
+	return r
+}
+
+// existingServerID reads the :id route parameter of a listing that exists.
+func existingServerID(c fiber.Ctx) (int, error) {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id <= 0 {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "invalid server id")
+	}
+	var exists int
+	err = Database.QueryRow(`SELECT 1 FROM servers WHERE id = ?`, id).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return 0, fiber.NewError(fiber.StatusNotFound, "server not found")
+	}
+	if err != nil {
+		return 0, fiber.NewError(fiber.StatusInternalServerError, "failed to load server")
+	}
+	return id, nil
+}
+
+// postReviewHandler creates or replaces the signed-in user's review of a
+// server. Editing keeps the owners' reply and any moderation state.
+func postReviewHandler(c fiber.Ctx) error {
+	u, ok := getSessionUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).SendString("Sign in with Discord to review a server.")
+	}
+	id, err := existingServerID(c)
+	if err != nil {
+		return err
+	}
+
+	type reviewPayload struct {
+		Rating int    `json:"rating"`
+		Body   string `json:"body"`
+	}
+
+	var payload reviewPayload
+	if err := c.Bind().Body(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid json body")
+	}
+	if payload.Rating < 1 || payload.Rating > 5 {
+		return c.Status(fiber.StatusBadRequest).SendString("rating must be between 1 and 5")
+	}
+	body := strings.TrimSpace(payload.Body)
+	if utf8.RuneCountInString(body) > maxReviewBody {
+		return c.Status(fiber.StatusBadRequest).SendString(
+			fmt.Sprintf("Reviews must be at most %d characters.", maxReviewBody),
+		)
+	}
+
+	role, err := serverRole(u.DiscordID, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to check role")
+	}
+	if role != "" {
+		return c.Status(fiber.StatusForbidden).SendString("You can't review a server you help run.")
+	}
+
+	eligible, err := canReview(u, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to check eligibility")
+	}
+	if !eligible {
+		return c.Status(fiber.StatusForbidden).SendString(fmt.Sprintf(
+			"To review a server your Discord account must be at least %d days old, or you must have voted for it while signed in at least a day ago.",
+			int(reviewMinAccountAge().Hours()/24),
+		))
+	}
+
+	if allowed, _, _ := reviewLimiter.allow(u.DiscordID, reviewPostsPerHour); !allowed {
+		return c.Status(fiber.StatusTooManyRequests).SendString("Too many reviews, try again later.")
+	}
+
+	_, err = Database.Exec(`
+		INSERT INTO server_reviews (server_id, discord_id, username, rating, body, created_at)
+		VALUES (?, ?, ?, ?, ?, datetime('now'))
+		ON CONFLICT(server_id, discord_id) DO UPDATE SET
+			username   = excluded.username,
+			rating     = excluded.rating,
+			body       = excluded.body,
+			updated_at = datetime('now')
+	`, id, u.DiscordID, u.Username, payload.Rating, nullEmpty(body))
+	if err != nil {
+		log.Println("insert server_review:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to save review")
+	}
+
+	r, err := scanReview(Database.QueryRow(reviewSelect+`
+		WHERE r.server_id = ? AND r.discord_id = ?
+	`, id, u.DiscordID))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load review")
+	}
+
+	return c.JSON(withoutReports(r))
+}
+
+// getMyReviewHandler returns the signed-in user's review of a server so the
+// form can be filled in.
+func getMyReviewHandler(c fiber.Ctx) error {
+	u, ok := getSessionUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).SendString("unauthorized")
+	}
+
+	r, err := scanReview(Database.QueryRow(reviewSelect+`
+		WHERE r.server_id = ? AND r.discord_id = ?
+	`, c.Params("id"), u.DiscordID))
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).SendString("no review")
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load review")
+	}
+
+	return c.JSON(withoutReports(r))
+}
+
+func postRemoveReviewHandler(c fiber.Ctx) error {
+	u, ok := getSessionUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).SendString("unauthorized")
+	}
+
+	res, err := Database.Exec(`
+		DELETE FROM server_reviews WHERE server_id = ? AND discord_id = ?
+	`, c.Params("id"), u.DiscordID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to remove review")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return c.Status(fiber.StatusNotFound).SendString("review not found")
+	}
+
+	return c.JSON(fiber.Map{"ok": true})
+}
+
+// postReviewReportHandler flags a review for the admins. Each user can
+// report a review once.
+func postReviewReportHandler(c fiber.Ctx) error {
+	u, ok := getSessionUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).SendString("Sign in with Discord to report a review.")
+	}
+
+	type reportPayload struct {
+		Reason string `json:"reason"`
+	}
+
+	var payload reportPayload
+	if err := c.Bind().Body(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid json body")
+	}
+	reason := strings.TrimSpace(payload.Reason)
+	if utf8.RuneCountInString(reason) > maxReviewReport {
+		return c.Status(fiber.StatusBadRequest).SendString(
+			fmt.Sprintf("Reasons must be at most %d characters.", maxReviewReport),
+		)
+	}
+
+	var author string
+	err := Database.QueryRow(`
+		SELECT discord_id FROM server_reviews WHERE id = ? AND hidden_at IS NULL
+	`, c.Params("id")).Scan(&author)
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).SendString("review not found")
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load review")
+	}
+	if author == u.DiscordID {
+		return c.Status(fiber.StatusBadRequest).SendString("You can't report your own review.")
+	}
+
+	res, err := Database.Exec(`
+		INSERT INTO review_reports (review_id, reporter_id, reason, created_at)
+		VALUES (?, ?, ?, datetime('now'))
+		ON CONFLICT(review_id, reporter_id) DO NOTHING
+	`, c.Params("id"), u.DiscordID, reason)
+	if err != nil {
+		log.Println("insert review_report:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to save report")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return c.Status(fiber.StatusConflict).SendString("You already reported this review.")
+	}
+
+	return c.JSON(fiber.Map{"ok": true})
+}
+
+// postOwnerReviewReplyHandler lets a server's team answer a review. Each
+// review gets one reply.
+func postOwnerReviewReplyHandler(c fiber.Ctx) error {
+	u, id, err := requireServerRole(c, roleOwner, roleManager)
+	if err != nil {
+		return err
+	}
+
+	type replyPayload struct {
+		Reply string `json:"reply"`
+	}
+
+	var payload replyPayload
+	if err := c.Bind().Body(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid json body")
+	}
+	reply := strings.TrimSpace(payload.Reply)
+	if reply == "" {
+		return c.Status(fiber.StatusBadRequest).SendString("reply is required")
+	}
+	if utf8.RuneCountInString(reply) > maxReviewReply {
+		return c.Status(fiber.StatusBadRequest).SendString(
+			fmt.Sprintf("Replies must be at most %d characters.", maxReviewReply),
+		)
+	}
+
+	res, err := Database.Exec(`
+		UPDATE server_reviews
+		SET reply      = ?,
+		    reply_by   = ?,
+		    replied_at = datetime('now')
+		WHERE id = ? AND server_id = ? AND reply IS NULL
+	`, reply, u.Username, c.Params("reviewId"), id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to save reply")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		var exists int
+		err := Database.QueryRow(`
+			SELECT 1 FROM server_reviews WHERE id = ? AND server_id = ?
+		`, c.Params("reviewId"), id).Scan(&exists)
+		if err == sql.ErrNoRows {
+			return c.Status(fiber.StatusNotFound).SendString("review not found")
+		}
+		return c.Status(fiber.StatusConflict).SendString("This review already has a reply.")
+	}
+
+	return c.JSON(fiber.Map{"ok": true})
+}
+
+// getAdminReviewsHandler lists reviews with open reports, most reported
+// first.
+func getAdminReviewsHandler(c fiber.Ctx) error {
+	if _, err := requireAdmin(c); err != nil {
+		return err
+	}
+
+	reviews, err := queryReviews(`
+		WHERE EXISTS (
+			SELECT 1 FROM review_reports rr WHERE rr.review_id = r.id AND rr.resolved_at IS NULL
+		)
+		ORDER BY (
+			SELECT COUNT(*) FROM review_reports rr WHERE rr.review_id = r.id AND rr.resolved_at IS NULL
+		) DESC, r.created_at ASC
+	`)
+	if err != nil {
+		log.Println("getAdminReviewsHandler:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load reviews")
+	}
+
+	return c.JSON(fiber.Map{"reviews": reviews})
+}
+
+// moderateReview hides or restores a review, or with action "dismiss"
+// keeps it as it is. Every decision resolves the review's open reports.
+func moderateReview(c fiber.Ctx, action string) error {
+	admin, err := requireAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	before := auditRow(queryReviews, `WHERE r.id = ?`, c.Params("id"))
+
+	tx, err := Database.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to start transaction")
+	}
+	defer tx.Rollback()
+
+	if action != "dismiss" {
+		query := `
+			UPDATE server_reviews SET hidden_at = datetime('now'), hidden_by = ?
+			WHERE id = ? AND hidden_at IS NULL
+		`
+		args := []any{admin.DiscordID, c.Params("id")}
+		if action == "unhide" {
+			query = `
+				UPDATE server_reviews SET hidden_at = NULL, hidden_by = NULL
+				WHERE id = ? AND hidden_at IS NOT NULL
+			`
+			args = args[1:]
+		}
+		res, err := tx.Exec(query, args...)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("failed to update review")
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return c.Status(fiber.StatusNotFound).SendString("review not found or already updated")
+		}
+	}
+
+	res, err := tx.Exec(`
+		UPDATE review_reports
+		SET resolved_at = datetime('now'), resolved_by = ?
+		WHERE review_id = ? AND resolved_at IS NULL
+	`, admin.DiscordID, c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to resolve reports")
+	}
+	if n, _ := res.RowsAffected(); n == 0 && action == "dismiss" {
+		return c.Status(fiber.StatusNotFound).SendString("no open reports for this review")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to commit transaction")
+	}
+
+	recordAdminAction(c, "review."+action, "review", c.Params("id"), before, auditRow(queryReviews, `WHERE r.id = ?`, c.Params("id")))
+
+	return c.JSON(fiber.Map{"ok": true})
+}
+
+func postAdminHideReviewHandler(c fiber.Ctx) error {
+	return moderateReview(c, "hide")
+}
+
+func postAdminUnhideReviewHandler(c fiber.Ctx) error {
+	return moderateReview(c, "unhide")
+}
+
+func postAdminDismissReviewReportsHandler(c fiber.Ctx) error {
+	return moderateReview(c, "dismiss")
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// youngDiscordID returns a snowflake for an account created just now.
+func youngDiscordID() string {
+	return strconv.FormatUint(uint64(time.Now().UnixMilli()-discordEpoch)<<22, 10)
+}
+
+func voteAs(t *testing.T, srv *httptest.Server, serverID int, session, ip string) {
+	t.Helper()
+
+	form := url.Values{"name": {"player"}}
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/server/"+strconv.Itoa(serverID)+"/vote", strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Forwarded-For", ip)
+	if session != "" {
+		req.AddCookie(&http.Cookie{Name: "mossai_session", Value: session})
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("vote: status %d", resp.StatusCode)
+	}
+}
+
+func TestReviewEligibilityFollowsTheAccountsVotes(t *testing.T) {
+	srv := newTestServer(t)
+	reviewer := testSessionToken(t, youngDiscordID())
+	serverID := insertTestServer(t, "Reviewed", "https://reviewed.example", 0)
+	reviewPath := "/api/servers/" + strconv.Itoa(serverID) + "/reviews"
+	review := map[string]any{"rating": 5, "body": "Great pp system."}
+
+	backdateVotes := func() {
+		t.Helper()
+		if _, err := Database.Exec(`UPDATE votes SET last_vote = datetime('now', '-2 days')`); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// An old vote from the same address doesn't vouch for the account.
+	voteAs(t, srv, serverID, "", "203.0.113.20")
+	backdateVotes()
+	if status, _ := testRequest(t, srv, http.MethodPost, reviewPath, reviewer, review); status != http.StatusForbidden {
+		t.Fatalf("review after an anonymous vote: status %d, want 403", status)
+	}
+
+	// Nor does a vote cast just before reviewing.
+	voteAs(t, srv, serverID, reviewer, "203.0.113.21")
+	if status, _ := testRequest(t, srv, http.MethodPost, reviewPath, reviewer, review); status != http.StatusForbidden {
+		t.Fatalf("review right after voting: status %d, want 403", status)
+	}
+
+	backdateVotes()
+	if status, body := testRequest(t, srv, http.MethodPost, reviewPath, reviewer, review); status/100 != 2 {
+		t.Fatalf("review a day after voting: %d %s", status, body)
+	}
+}
+
+func TestRatingSortWeighsReviewCount(t *testing.T) {
+	srv := newTestServer(t)
+
+	addReviews := func(serverID int, ratings ...int) {
+		t.Helper()
+		for i, rating := range ratings {
+			if _, err := Database.Exec(`
+				INSERT INTO server_reviews (server_id, discord_id, username, rating, created_at)
+				VALUES (?, ?, 'reviewer', ?, datetime('now'))
+			`, serverID, "3000000000000000"+strconv.Itoa(10+i), rating); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	single := insertTestServer(t, "Single", "https://single.example", 0)
+	addReviews(single, 5)
+	many := insertTestServer(t, "Many", "https://many.example", 0)
+	addReviews(many, 5, 5, 5, 5, 4, 4, 5, 5, 4, 5)
+	poor := insertTestServer(t, "Poor", "https://poor.example", 0)
+	addReviews(poor, 1, 2, 1)
+	unrated := insertTestServer(t, "Unrated", "https://unrated.example", 0)
+
+	status, body := testRequest(t, srv, http.MethodGet, "/leaderboard?sort=rating", "", nil)
+	if status != http.StatusOK {
+		t.Fatalf("leaderboard: %d %s", status, body)
+	}
+	var servers []ServerResult
+	if err := json.Unmarshal(body, &servers); err != nil {
+		t.Fatal(err)
+	}
+	var got []int
+	for _, s := range servers {
+		got = append(got, s.ID)
+	}
+	want := []int{many, single, unrated, poor}
+	if len(got) != len(want) {
+		t.Fatalf("order = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("order = %v, want %v", got, want)
+		}
+	}
+}
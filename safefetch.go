@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	maxRemoteImageBytes = 4 << 20
+	maxImageDimension   = 4096
+)
+
+var errBlockedAddress = errors.New("address is not publicly routable")
+
+// blockedPrefixes are ranges that netip does not classify as private but
+// that must never be reached from a user-supplied URL.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	// 6to4 and Teredo tunnel to an embedded IPv4 address, which may be an
+	// internal one.
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("2001::/32"),
+}
+
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() ||
+		addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsUnspecified() ||
+		addr.IsMulticast() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// safeDialControl runs after DNS resolution, so it also covers names that
+// resolve (or rebind) to internal addresses.
+func safeDialControl(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !isPublicAddr(addr) {
+		return fmt.Errorf("%s: %w", host, errBlockedAddress)
+	}
+	return nil
+}
+
+// newSafeHTTPClient returns a client for fetching user-supplied URLs. It
+// refuses to connect to loopback, private and other internal addresses,
+// ignores proxy settings and caps redirects.
+func newSafeHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: safeDialControl,
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: 5 * time.Second,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 3 {
+				return errors.New("too many redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return errors.New("redirect to unsupported scheme")
+			}
+			return nil
+		},
+	}
+}
+
+var safeHTTPClient = newSafeHTTPClient(10 * time.Second)
+
+// fetchRemoteBytes downloads rawURL with safeHTTPClient, reading at most
+// limit bytes.
+func fetchRemoteBytes(ctx context.Context, rawURL string, limit int64) ([]byte, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, "", fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("User-Agent", "mossai (+"+getBaseURL()+")")
+
+	resp, err := safeHTTPClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if resp.ContentLength > limit {
+		return nil, "", fmt.Errorf("response too large (%d bytes)", resp.ContentLength)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(data)) > limit {
+		return nil, "", fmt.Errorf("response larger than %d bytes", limit)
+	}
+
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+// decodeImage checks the header of data before decoding it so oversized
+// images are rejected without allocating their pixels.
+func decodeImage(data []byte) (image.Image, string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("not a supported image: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 ||
+		cfg.Width > maxImageDimension || cfg.Height > maxImageDimension {
+		return nil, "", fmt.Errorf("image dimensions %dx%d out of range", cfg.Width, cfg.Height)
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("decode %s: %w", format, err)
+	}
+	return img, format, nil
+}
+
+// loadLogoImage resolves a logo_url to an image. Hosted logos and paths
+// under /static/ are read from disk; anything else is fetched with
+// safeHTTPClient.
+func loadLogoImage(ctx context.Context, logoURL string) (image.Image, error) {
+	logoURL = strings.TrimSpace(logoURL)
+	if logoURL == "" {
+		return nil, errors.New("no logo")
+	}
+
+	if isHostedLogoURL(logoURL) {
+		data, err := os.ReadFile(filepath.Join(logoDir(), strings.TrimPrefix(logoURL, hostedLogoPrefix)))
+		if err != nil {
+			return nil, err
+		}
+		img, _, err := decodeImage(data)
+		return img, err
+	}
+
+	if strings.HasPrefix(logoURL, "/static/") {
+		rel := filepath.Clean(strings.TrimPrefix(logoURL, "/static/"))
+		if rel == "." || strings.HasPrefix(rel, "..") || filepath.IsAbs(rel) {
+			return nil, errors.New("invalid static path")
+		}
+		data, err := os.ReadFile(filepath.Join("./public", rel))
+		if err != nil {
+			return nil, err
+		}
+		img, _, err := decodeImage(data)
+		return img, err
+	}
+
+	data, _, err := fetchRemoteBytes(ctx, logoURL, maxRemoteImageBytes)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := decodeImage(data)
+	return img, err
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"1.1.1.1", true},
+		{"93.184.216.34", true},
+		{"2606:4700:4700::1111", true},
+
+		// loopback
+		{"127.0.0.1", false},
+		{"127.10.0.1", false},
+		{"::1", false},
+		{"::ffff:127.0.0.1", false},
+
+		// RFC 1918 and unique local
+		{"10.0.0.1", false},
+		{"172.16.5.4", false},
+		{"172.31.255.255", false},
+		{"192.168.1.1", false},
+		{"::ffff:192.168.1.1", false},
+		{"fd00::1", false},
+
+		// link-local, including cloud metadata endpoints
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+
+		// other internal ranges
+		{"0.0.0.0", false},
+		{"::", false},
+		{"100.64.0.1", false},
+		{"198.18.0.1", false},
+		{"224.0.0.1", false},
+		{"64:ff9b::7f00:1", false},
+
+		// 6to4 and Teredo wrapping internal IPv4 addresses
+		{"2002:7f00:1::1", false},
+		{"2002:c0a8:101::1", false},
+		{"2001:0:4136:e378:8000:63bf:3fff:fdd2", false},
+	}
+
+	for _, tt := range tests {
+		if got := isPublicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("isPublicAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestFetchRemoteBytesRefusesInternalAddresses(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Write([]byte("internal"))
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	for _, rawURL := range []string{
+		srv.URL,
+		"http://localhost:" + port + "/",
+		"http://[::1]:1/",
+		"http://10.0.0.1:1/",
+		"http://169.254.169.254/latest/meta-data/",
+	} {
+		_, _, err := fetchRemoteBytes(context.Background(), rawURL, 1024)
+		if !errors.Is(err, errBlockedAddress) {
+			t.Errorf("fetch %s: error = %v, want errBlockedAddress", rawURL, err)
+		}
+	}
+	if hits != 0 {
+		t.Fatalf("internal server was reached %d times", hits)
+	}
+
+	if _, _, err := fetchRemoteBytes(context.Background(), "file:///etc/passwd", 1024); err == nil {
+		t.Fatal("fetched a file:// URL")
+	}
+}
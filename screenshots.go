@@ -0,0 +1,658 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"golang.org/x/image/draw"
+)
+
+const (
+	maxScreenshots           = 8
+	maxScreenshotBytes       = 5 << 20
+	maxScreenshotCaption     = 200
+	minScreenshotWidth       = 320
+	minScreenshotHeight      = 180
+	screenshotFullWidth      = 1600
+	screenshotThumbWidth     = 480
+	screenshotJPEGQuality    = 85
+	screenshotUploadsPerHour = 20
+
+	// hostedScreenshotPrefix is the route screenshots are served from, and
+	// pendingScreenshotPrefix the one unreviewed uploads are shown to the
+	// server's team and admins from.
+	hostedScreenshotPrefix  = "/screenshots/"
+	pendingScreenshotPrefix = hostedScreenshotPrefix + "pending/"
+)
+
+var allowedScreenshotTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/webp": true,
+}
+
+var screenshotUploadLimiter = newRateLimiter(time.Hour)
+
+// screenshotDir is where screenshots are written; MOSS_SCREENSHOT_DIR
+// overrides it.
+func screenshotDir() string {
+	if v := strings.TrimSpace(os.Getenv("MOSS_SCREENSHOT_DIR")); v != "" {
+		return v
+	}
+	return "./data/screenshots"
+}
+
+// pendingScreenshotDir holds uploads until they are approved. It sits next
+// to screenshotDir rather than in it, so the public mount never serves them.
+func pendingScreenshotDir() string {
+	return filepath.Clean(screenshotDir()) + "-pending"
+}
+
+// screenshotFile returns the file a stored screenshot URL points at.
+func screenshotFile(u string) (string, bool) {
+	if name, ok := strings.CutPrefix(u, pendingScreenshotPrefix); ok {
+		return filepath.Join(pendingScreenshotDir(), filepath.Base(name)), true
+	}
+	if name, ok := strings.CutPrefix(u, hostedScreenshotPrefix); ok {
+		return filepath.Join(screenshotDir(), filepath.Base(name)), true
+	}
+	return "", false
+}
+
+const screenshotSelect = `
+	SELECT sc.id,
+	       sc.server_id,
+	       COALESCE(s.server_name, ''),
+	       sc.image_url,
+	       sc.thumb_url,
+	       COALESCE(sc.caption, ''),
+	       COALESCE(sc.pending_caption, ''),
+	       sc.pending_caption IS NOT NULL,
+	       sc.position,
+	       sc.status,
+	       sc.uploaded_by,
+	       sc.created_at
+	FROM server_screenshots sc
+	LEFT JOIN servers s
+	  ON s.id = sc.server_id
+`
+
+func scanScreenshot(row rowScanner) (Screenshot, error) {
+	var sc Screenshot
+	err := row.Scan(
+		&sc.ID,
+		&sc.ServerID,
+		&sc.ServerName,
+		&sc.ImageURL,
+		&sc.ThumbURL,
+		&sc.Caption,
+		&sc.PendingCaption,
+		&sc.CaptionPending,
+		&sc.Position,
+		&sc.Status,
+		&sc.UploadedBy,
+		&sc.CreatedAt,
+	)
+	return sc, err
+}
+
+func queryScreenshots(where string, args ...any) ([]Screenshot, error) {
+	rows, err := Database.Query(screenshotSelect+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shots := make([]Screenshot, 0, maxScreenshots)
+	for rows.Next() {
+		sc, err := scanScreenshot(rows)
+		if err != nil {
+			return nil, err
+		}
+		shots = append(shots, sc)
+	}
+	return shots, rows.Err()
+}
+
+// publicScreenshots returns the approved gallery of a server in display
+// order. Moderation fields are left out.
+func publicScreenshots(serverID int) ([]Screenshot, error) {
+	shots, err := queryScreenshots(`
+		WHERE sc.server_id = ? AND sc.status = 'approved'
+		ORDER BY sc.position, sc.id
+	`, serverID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range shots {
+		shots[i] = Screenshot{
+			ID:       shots[i].ID,
+			ImageURL: shots[i].ImageURL,
+			ThumbURL: shots[i].ThumbURL,
+			Caption:  shots[i].Caption,
+			Position: shots[i].Position,
+		}
+	}
+	return shots, nil
+}
+
+// decodeScreenshot sniffs and decodes an uploaded screenshot.
+func decodeScreenshot(data []byte) (image.Image, error) {
+	if len(data) > maxScreenshotBytes {
+		return nil, fmt.Errorf("screenshot must be at most %d MB", maxScreenshotBytes>>20)
+	}
+	if !allowedScreenshotTypes[http.DetectContentType(data)] {
+		return nil, errors.New("screenshot must be a PNG, JPEG or WebP image")
+	}
+
+	img, _, err := decodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	if b.Dx() < minScreenshotWidth || b.Dy() < minScreenshotHeight {
+		return nil, fmt.Errorf("screenshot must be at least %dx%d pixels", minScreenshotWidth, minScreenshotHeight)
+	}
+	return img, nil
+}
+
+// fitWidth scales img down to width, keeping its aspect ratio. Smaller
+// images are copied at their own size. JPEG has no alpha, so transparent
+// areas are flattened onto white.
+func fitWidth(img image.Image, width int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > width {
+		w, h = width, max(1, width*b.Dy()/b.Dx())
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Over, nil)
+	return dst
+}
+
+// storeScreenshot re-encodes img as a full size JPEG and a thumbnail, named
+// after the content of the full size copy, and returns both URLs. They are
+// written to pendingScreenshotDir until the screenshot is approved.
+func storeScreenshot(img image.Image) (string, string, error) {
+	encode := func(width int) ([]byte, error) {
+		var buf bytes.Buffer
+		err := jpeg.Encode(&buf, fitWidth(img, width), &jpeg.Options{Quality: screenshotJPEGQuality})
+		return buf.Bytes(), err
+	}
+
+	full, err := encode(screenshotFullWidth)
+	if err != nil {
+		return "", "", err
+	}
+	thumb, err := encode(screenshotThumbWidth)
+	if err != nil {
+		return "", "", err
+	}
+
+	sum := sha256.Sum256(full)
+	hash := hex.EncodeToString(sum[:16])
+
+	dir := pendingScreenshotDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", "", err
+	}
+	if err := writeFileAtomic(filepath.Join(dir, hash+".jpg"), full); err != nil {
+		return "", "", err
+	}
+	if err := writeFileAtomic(filepath.Join(dir, hash+"-thumb.jpg"), thumb); err != nil {
+		return "", "", err
+	}
+
+	return pendingScreenshotPrefix + hash + ".jpg", pendingScreenshotPrefix + hash + "-thumb.jpg", nil
+}
+
+// publishScreenshot copies a pending file into screenshotDir and returns its
+// public URL. URLs that are already public are returned as they are.
+func publishScreenshot(u string) (string, error) {
+	name, ok := strings.CutPrefix(u, pendingScreenshotPrefix)
+	if !ok {
+		return u, nil
+	}
+	src, _ := screenshotFile(u)
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return "", err
+	}
+
+	dir := screenshotDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	name = filepath.Base(name)
+	if err := writeFileAtomic(filepath.Join(dir, name), data); err != nil {
+		return "", err
+	}
+	return hostedScreenshotPrefix + name, nil
+}
+
+// removeUnusedScreenshotFiles deletes the files behind urls once no
+// screenshot that isn't rejected refers to them. Names are content hashes,
+// so the same file can back several uploads.
+func removeUnusedScreenshotFiles(urls ...string) {
+	for _, u := range urls {
+		path, ok := screenshotFile(u)
+		if !ok {
+			continue
+		}
+		var used bool
+		if err := Database.QueryRow(`
+			SELECT EXISTS (
+				SELECT 1 FROM server_screenshots
+				WHERE (image_url = ? OR thumb_url = ?) AND status != 'rejected'
+			)
+		`, u, u).Scan(&used); err != nil {
+			log.Println("removeUnusedScreenshotFiles:", err)
+			continue
+		}
+		if used {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Println("removeUnusedScreenshotFiles:", err)
+		}
+	}
+}
+
+// getPendingScreenshotHandler serves an unreviewed upload to admins and to
+// the team of a server it was uploaded to.
+func getPendingScreenshotHandler(c fiber.Ctx) error {
+	u, ok := getSessionUser(c)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).SendString("not found")
+	}
+	url := pendingScreenshotPrefix + c.Params("name")
+
+	allowed := isAdminDiscordID(u.DiscordID)
+	if !allowed {
+		err := Database.QueryRow(`
+			SELECT EXISTS (
+				SELECT 1
+				FROM server_screenshots sc
+				JOIN server_owners o
+				  ON o.server_id = sc.server_id
+				WHERE (sc.image_url = ? OR sc.thumb_url = ?)
+				  AND sc.status = 'pending'
+				  AND o.discord_id = ?
+				  AND o.role IN (?, ?)
+			)
+		`, url, url, u.DiscordID, roleOwner, roleManager).Scan(&allowed)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("failed to load screenshot")
+		}
+	}
+	if !allowed {
+		return c.Status(fiber.StatusNotFound).SendString("not found")
+	}
+
+	path, _ := screenshotFile(url)
+	if _, err := os.Stat(path); err != nil {
+		return c.Status(fiber.StatusNotFound).SendString("not found")
+	}
+	c.Set("Cache-Control", "private, no-cache")
+	return c.SendFile(path)
+}
+
+func validScreenshotCaption(caption string) error {
+	if len([]rune(caption)) > maxScreenshotCaption {
+		return fmt.Errorf("Caption must be at most %d characters.", maxScreenshotCaption)
+	}
+	return nil
+}
+
+// loadOwnerScreenshot loads a screenshot of the server the caller manages.
+func loadOwnerScreenshot(c fiber.Ctx) (*SessionUser, *Screenshot, error) {
+	u, id, err := requireServerRole(c, roleOwner, roleManager)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sc, err := scanScreenshot(Database.QueryRow(screenshotSelect+`
+		WHERE sc.id = ? AND sc.server_id = ?
+	`, c.Params("shotId"), id))
+	if err == sql.ErrNoRows {
+		return nil, nil, fiber.NewError(fiber.StatusNotFound, "screenshot not found")
+	}
+	if err != nil {
+		return nil, nil, fiber.NewError(fiber.StatusInternalServerError, "failed to load screenshot")
+	}
+	return u, &sc, nil
+}
+
+// postOwnerScreenshotHandler adds a screenshot to a server's gallery. It
+// stays hidden until an admin approves it.
+func postOwnerScreenshotHandler(c fiber.Ctx) error {
+	u, id, err := requireServerRole(c, roleOwner, roleManager)
+	if err != nil {
+		return err
+	}
+
+	if allowed, _, _ := screenshotUploadLimiter.allow(u.DiscordID, screenshotUploadsPerHour); !allowed {
+		return c.Status(fiber.StatusTooManyRequests).SendString("Too many uploads, try again later.")
+	}
+
+	caption := strings.TrimSpace(c.FormValue("caption"))
+	if err := validScreenshotCaption(caption); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	var count int
+	err = Database.QueryRow(`
+		SELECT COUNT(*)
+		FROM server_screenshots
+		WHERE server_id = ? AND status != 'rejected'
+	`, id).Scan(&count)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to count screenshots")
+	}
+	if count >= maxScreenshots {
+		return c.Status(fiber.StatusConflict).SendString(
+			fmt.Sprintf("A listing can have at most %d screenshots.", maxScreenshots),
+		)
+	}
+
+	fh, err := c.FormFile("screenshot")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("attach an image as the screenshot")
+	}
+	if fh.Size > maxScreenshotBytes {
+		return c.Status(fiber.StatusBadRequest).SendString(
+			fmt.Sprintf("screenshot must be at most %d MB", maxScreenshotBytes>>20),
+		)
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("failed to read upload")
+	}
+	data, err := io.ReadAll(io.LimitReader(f, maxScreenshotBytes+1))
+	f.Close()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("failed to read upload")
+	}
+
+	img, err := decodeScreenshot(data)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	imageURL, thumbURL, err := storeScreenshot(img)
+	if err != nil {
+		log.Println("storeScreenshot:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to store screenshot")
+	}
+
+	res, err := Database.Exec(`
+		INSERT INTO server_screenshots (
+			server_id, image_url, thumb_url, caption, position, status, uploaded_by, created_at
+		)
+		VALUES (
+			?, ?, ?, ?,
+			COALESCE((SELECT MAX(position) FROM server_screenshots WHERE server_id = ?), 0) + 1,
+			'pending', ?, datetime('now')
+		)
+	`, id, imageURL, thumbURL, nullEmpty(caption), id, u.DiscordID)
+	if err != nil {
+		log.Println("insert server_screenshot:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to save screenshot")
+	}
+
+	shotID, err := res.LastInsertId()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to get screenshot id")
+	}
+
+	sc, err := scanScreenshot(Database.QueryRow(screenshotSelect+`WHERE sc.id = ?`, shotID))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load screenshot")
+	}
+
+	notifyNewScreenshot(&sc, u)
+
+	return c.Status(fiber.StatusCreated).JSON(sc)
+}
+
+// postOwnerScreenshotCaptionHandler changes a caption. Captions of approved
+// screenshots are reviewed before they replace the live one.
+func postOwnerScreenshotCaptionHandler(c fiber.Ctx) error {
+	_, sc, err := loadOwnerScreenshot(c)
+	if err != nil {
+		return err
+	}
+
+	type captionPayload struct {
+		Caption string `json:"caption"`
+	}
+
+	var payload captionPayload
+	if err := c.Bind().Body(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid json body")
+	}
+
+	caption := strings.TrimSpace(payload.Caption)
+	if err := validScreenshotCaption(caption); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	switch sc.Status {
+	case "pending":
+		_, err = Database.Exec(`
+			UPDATE server_screenshots SET caption = ? WHERE id = ?
+		`, nullEmpty(caption), sc.ID)
+	case "approved":
+		if caption == sc.Caption {
+			_, err = Database.Exec(`
+				UPDATE server_screenshots SET pending_caption = NULL WHERE id = ?
+			`, sc.ID)
+		} else {
+			_, err = Database.Exec(`
+				UPDATE server_screenshots SET pending_caption = ? WHERE id = ?
+			`, caption, sc.ID)
+		}
+	default:
+		return c.Status(fiber.StatusConflict).SendString("This screenshot was rejected; upload a new one instead.")
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to update caption")
+	}
+
+	return c.JSON(fiber.Map{"ok": true})
+}
+
+// postOwnerScreenshotOrderHandler sets the gallery order from a list of
+// screenshot ids. Ordering is not reviewed.
+func postOwnerScreenshotOrderHandler(c fiber.Ctx) error {
+	_, id, err := requireServerRole(c, roleOwner, roleManager)
+	if err != nil {
+		return err
+	}
+
+	type orderPayload struct {
+		IDs []int `json:"ids"`
+	}
+
+	var payload orderPayload
+	if err := c.Bind().Body(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid json body")
+	}
+	if len(payload.IDs) == 0 || len(payload.IDs) > maxScreenshots*2 {
+		return c.Status(fiber.StatusBadRequest).SendString("ids is required")
+	}
+
+	tx, err := Database.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	for i, shotID := range payload.IDs {
+		res, err := tx.Exec(`
+			UPDATE server_screenshots SET position = ? WHERE id = ? AND server_id = ?
+		`, i+1, shotID, id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("failed to reorder screenshots")
+		}
+		if affected, _ := res.RowsAffected(); affected == 0 {
+			return c.Status(fiber.StatusBadRequest).SendString(
+				fmt.Sprintf("screenshot %d does not belong to this server", shotID),
+			)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to commit transaction")
+	}
+
+	return c.JSON(fiber.Map{"ok": true})
+}
+
+func postOwnerScreenshotRemoveHandler(c fiber.Ctx) error {
+	_, sc, err := loadOwnerScreenshot(c)
+	if err != nil {
+		return err
+	}
+
+	if _, err := Database.Exec(`DELETE FROM server_screenshots WHERE id = ?`, sc.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to remove screenshot")
+	}
+	removeUnusedScreenshotFiles(sc.ImageURL, sc.ThumbURL)
+
+	return c.JSON(fiber.Map{"ok": true})
+}
+
+// getAdminScreenshotsHandler lists new screenshots and caption changes
+// waiting for review.
+func getAdminScreenshotsHandler(c fiber.Ctx) error {
+	if _, err := requireAdmin(c); err != nil {
+		return err
+	}
+
+	shots, err := queryScreenshots(`
+		WHERE sc.status = 'pending' OR sc.pending_caption IS NOT NULL
+		ORDER BY sc.created_at ASC
+	`)
+	if err != nil {
+		log.Println("getAdminScreenshotsHandler:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load screenshots")
+	}
+
+	return c.JSON(fiber.Map{"screenshots": shots})
+}
+
+func loadReviewableScreenshot(c fiber.Ctx) (*Screenshot, error) {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id <= 0 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid screenshot id")
+	}
+
+	sc, err := scanScreenshot(Database.QueryRow(screenshotSelect+`
+		WHERE sc.id = ? AND (sc.status = 'pending' OR sc.pending_caption IS NOT NULL)
+	`, id))
+	if err == sql.ErrNoRows {
+		return nil, fiber.NewError(fiber.StatusNotFound, "screenshot not found or already processed")
+	}
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to load screenshot")
+	}
+	return &sc, nil
+}
+
+func postAdminApproveScreenshotHandler(c fiber.Ctx) error {
+	admin, err := requireAdmin(c)
+	if err != nil {
+		return err
+	}
+	sc, err := loadReviewableScreenshot(c)
+	if err != nil {
+		return err
+	}
+
+	imageURL, err := publishScreenshot(sc.ImageURL)
+	if err != nil {
+		log.Println("publishScreenshot:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to publish screenshot")
+	}
+	thumbURL, err := publishScreenshot(sc.ThumbURL)
+	if err != nil {
+		log.Println("publishScreenshot:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to publish screenshot")
+	}
+
+	_, err = Database.Exec(`
+		UPDATE server_screenshots
+		SET status          = 'approved',
+		    image_url       = ?,
+		    thumb_url       = ?,
+		    caption         = CASE WHEN pending_caption IS NULL THEN caption ELSE NULLIF(pending_caption, '') END,
+		    pending_caption = NULL,
+		    decided_at      = datetime('now'),
+		    decided_by      = ?
+		WHERE id = ?
+	`, imageURL, thumbURL, admin.DiscordID, sc.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to approve screenshot")
+	}
+	if imageURL != sc.ImageURL || thumbURL != sc.ThumbURL {
+		removeUnusedScreenshotFiles(sc.ImageURL, sc.ThumbURL)
+	}
+
+	recordAdminAction(c, "screenshot.approve", "screenshot", sc.ID, sc, auditRow(queryScreenshots, `WHERE sc.id = ?`, sc.ID))
+
+	return c.JSON(fiber.Map{"ok": true})
+}
+
+// postAdminRejectScreenshotHandler rejects a new screenshot, or only the
+// proposed caption of one that is already live.
+func postAdminRejectScreenshotHandler(c fiber.Ctx) error {
+	admin, err := requireAdmin(c)
+	if err != nil {
+		return err
+	}
+	sc, err := loadReviewableScreenshot(c)
+	if err != nil {
+		return err
+	}
+
+	status := "rejected"
+	if sc.Status == "approved" {
+		status = "approved"
+	}
+
+	_, err = Database.Exec(`
+		UPDATE server_screenshots
+		SET status          = ?,
+		    pending_caption = NULL,
+		    decided_at      = datetime('now'),
+		    decided_by      = ?
+		WHERE id = ?
+	`, status, admin.DiscordID, sc.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to reject screenshot")
+	}
+	if status == "rejected" {
+		removeUnusedScreenshotFiles(sc.ImageURL, sc.ThumbURL)
+	}
+
+	recordAdminAction(c, "screenshot.reject", "screenshot", sc.ID, sc, auditRow(queryScreenshots, `WHERE sc.id = ?`, sc.ID))
+
+	return c.JSON(fiber.Map{"ok": true})
+}
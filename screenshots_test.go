@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+)
+
+// testScreenshot encodes a 640x360 PNG filled with c.
+func testScreenshot(t *testing.T, c color.NRGBA) []byte {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, 640, 360))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func uploadScreenshot(t *testing.T, srv *httptest.Server, serverID int, session string, data []byte) Screenshot {
+	t.Helper()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("screenshot", "shot.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(data)
+	mw.Close()
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/owner/servers/"+strconv.Itoa(serverID)+"/screenshots", &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.AddCookie(&http.Cookie{Name: "mossai_session", Value: session})
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	out, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("upload: %d %s", resp.StatusCode, out)
+	}
+	var sc Screenshot
+	if err := json.Unmarshal(out, &sc); err != nil {
+		t.Fatal(err)
+	}
+	return sc
+}
+
+func TestPendingScreenshotsArePrivate(t *testing.T) {
+	srv := newTestServer(t)
+	const ownerID = "100000000000000060"
+	owner := testSessionToken(t, ownerID)
+	admin := testSessionToken(t, testAdminID)
+
+	serverID := insertTestServer(t, "Gallery", "https://gallery.example", 0)
+	if _, err := Database.Exec(`
+		INSERT INTO server_owners (server_id, discord_id, username, role, added_at)
+		VALUES (?, ?, 'owner', 'owner', datetime('now'))
+	`, serverID, ownerID); err != nil {
+		t.Fatal(err)
+	}
+
+	approved := uploadScreenshot(t, srv, serverID, owner, testScreenshot(t, color.NRGBA{}))
+	rejected := uploadScreenshot(t, srv, serverID, owner, testScreenshot(t, color.NRGBA{R: 200, A: 255}))
+
+	public := hostedScreenshotPrefix + approved.ImageURL[len(pendingScreenshotPrefix):]
+	for _, tt := range []struct {
+		path, session string
+		want          int
+	}{
+		{public, "", http.StatusNotFound},
+		{approved.ImageURL, "", http.StatusNotFound},
+		{approved.ImageURL, testSessionToken(t, "100000000000000061"), http.StatusNotFound},
+		{approved.ImageURL, owner, http.StatusOK},
+		{approved.ThumbURL, admin, http.StatusOK},
+	} {
+		if status, _ := testRequest(t, srv, http.MethodGet, tt.path, tt.session, nil); status != tt.want {
+			t.Errorf("GET %s: status %d, want %d", tt.path, status, tt.want)
+		}
+	}
+
+	// Transparent areas come out white rather than black.
+	_, thumb := testRequest(t, srv, http.MethodGet, approved.ThumbURL, owner, nil)
+	img, err := jpeg.Decode(bytes.NewReader(thumb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, g, b, _ := img.At(10, 10).RGBA(); r>>8 < 240 || g>>8 < 240 || b>>8 < 240 {
+		t.Errorf("transparent pixel encoded as %d,%d,%d", r>>8, g>>8, b>>8)
+	}
+
+	if status, body := testRequest(t, srv, http.MethodPost, "/api/admin/screenshots/"+strconv.Itoa(approved.ID)+"/approve", admin, nil); status != http.StatusOK {
+		t.Fatalf("approve: %d %s", status, body)
+	}
+	shots, err := publicScreenshots(serverID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shots) != 1 || shots[0].ImageURL != public {
+		t.Fatalf("gallery after approval = %+v", shots)
+	}
+	if status, _ := testRequest(t, srv, http.MethodGet, public, "", nil); status != http.StatusOK {
+		t.Fatalf("approved screenshot: status %d", status)
+	}
+	if path, _ := screenshotFile(approved.ImageURL); fileExists(path) {
+		t.Error("pending copy kept after approval")
+	}
+
+	if status, body := testRequest(t, srv, http.MethodPost, "/api/admin/screenshots/"+strconv.Itoa(rejected.ID)+"/reject", admin, nil); status != http.StatusOK {
+		t.Fatalf("reject: %d %s", status, body)
+	}
+	for _, u := range []string{rejected.ImageURL, rejected.ThumbURL} {
+		if path, _ := screenshotFile(u); fileExists(path) {
+			t.Errorf("%s kept after rejection", u)
+		}
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const feedEntryLimit = 50
+
+func parseDBTime(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// sendCacheable sends body with an ETag derived from it and a Last-Modified
+// of modified, answering 304 when the client's ETag still matches.
+// If-Modified-Since is not honoured: modified only tracks live servers, so a
+// removal changes the body without moving it.
+func sendCacheable(c fiber.Ctx, contentType string, modified time.Time, body []byte) error {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	c.Set("ETag", etag)
+	c.Set("Cache-Control", "public, max-age=900")
+	if !modified.IsZero() {
+		c.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	if c.Get("If-None-Match") == etag {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	c.Set("Content-Type", contentType)
+	return c.Send(body)
+}
+
+func tagPath(tag string) string {
+	return "/tags/" + url.PathEscape(strings.ToLower(strings.TrimSpace(tag)))
+}
+
+func robotsHandler(c fiber.Ctx) error {
+	body := strings.Join([]string{
+		"User-agent: *",
+		"Disallow: /admin",
+		"Disallow: /api/",
+		"",
+		"Sitemap: " + getBaseURL() + "/sitemap.xml",
+		"",
+	}, "\n")
+
+	c.Set("Cache-Control", "public, max-age=86400")
+	c.Type("txt", "utf-8")
+	return c.SendString(body)
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+func sitemapHandler(c fiber.Ctx) error {
+	rows, err := Database.Query(`
+		SELECT id,
+		       COALESCE(tags, ''),
+		       COALESCE(updated_at, added)
+		FROM servers
+		ORDER BY id
+	`)
+	if err != nil {
+		log.Println("sitemap query error:", err)
+		return c.Status(500).SendString("internal error")
+	}
+	defer rows.Close()
+
+	base := getBaseURL()
+	var latest time.Time
+	serverURLs := make([]sitemapURL, 0, 32)
+	tagModified := make(map[string]time.Time)
+
+	for rows.Next() {
+		var (
+			id       int
+			tags     string
+			modified string
+		)
+		if err := rows.Scan(&id, &tags, &modified); err != nil {
+			return c.Status(500).SendString(err.Error())
+		}
+
+		t, _ := parseDBTime(modified)
+		if t.After(latest) {
+			latest = t
+		}
+
+		entry := sitemapURL{Loc: fmt.Sprintf("%s/servers/%d", base, id)}
+		if !t.IsZero() {
+			entry.LastMod = t.Format(time.RFC3339)
+		}
+		serverURLs = append(serverURLs, entry)
+
+		for _, tag := range splitCSV(strings.ToLower(tags)) {
+			if t.After(tagModified[tag]) {
+				tagModified[tag] = t
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return c.Status(500).SendString(err.Error())
+	}
+
+	set := sitemapURLSet{XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+
+	home := sitemapURL{Loc: base + "/"}
+	if !latest.IsZero() {
+		home.LastMod = latest.Format(time.RFC3339)
+	}
+	set.URLs = append(set.URLs, home, sitemapURL{Loc: base + "/list"})
+	set.URLs = append(set.URLs, serverURLs...)
+
+	tags := make([]string, 0, len(tagModified))
+	for tag := range tagModified {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		entry := sitemapURL{Loc: base + tagPath(tag)}
+		if t := tagModified[tag]; !t.IsZero() {
+			entry.LastMod = t.Format(time.RFC3339)
+		}
+		set.URLs = append(set.URLs, entry)
+	}
+
+	body, err := xml.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return c.Status(500).SendString("failed to build sitemap")
+	}
+
+	return sendCacheable(c, "application/xml; charset=utf-8", latest, append([]byte(xml.Header), body...))
+}
+
+type feedEntry struct {
+	ID          int
+	PublishedAt time.Time
+	Server      ServerResult
+}
+
+// loadFeedEntries returns the most recently approved servers that are still
+// listed, newest first.
+func loadFeedEntries() ([]feedEntry, error) {
+	rows, err := Database.Query(`
+		SELECT f.id, f.server_id, f.published_at
+		FROM server_feed f
+		JOIN servers s
+		  ON s.id = f.server_id
+		ORDER BY f.published_at DESC, f.id DESC
+		LIMIT ?
+	`, feedEntryLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]feedEntry, 0, feedEntryLimit)
+	ids := make([]any, 0, feedEntryLimit)
+
+	for rows.Next() {
+		var (
+			e         feedEntry
+			published string
+		)
+		if err := rows.Scan(&e.ID, &e.Server.ID, &published); err != nil {
+			return nil, err
+		}
+		e.PublishedAt, _ = parseDBTime(published)
+		entries = append(entries, e)
+		ids = append(ids, e.Server.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return entries, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	servers, err := queryServers(`WHERE s.id IN (`+placeholders+`)`, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int]ServerResult, len(servers))
+	for _, s := range servers {
+		byID[s.ID] = s
+	}
+
+	out := entries[:0]
+	for _, e := range entries {
+		if s, ok := byID[e.Server.ID]; ok {
+			e.Server = s
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func feedSummary(s ServerResult) string {
+	if s.Description != "" {
+		return s.Description
+	}
+	return s.ServerName + " is now listed on mossai."
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Summary    string         `xml:"summary"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"feed"`
+	XMLNS    string      `xml:"xmlns,attr"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle"`
+	ID       string      `xml:"id"`
+	Links    []atomLink  `xml:"link"`
+	Updated  string      `xml:"updated"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+func atomFeedHandler(c fiber.Ctx) error {
+	entries, err := loadFeedEntries()
+	if err != nil {
+		log.Println("atom feed query error:", err)
+		return c.Status(500).SendString("internal error")
+	}
+
+	base := getBaseURL()
+	var latest time.Time
+	if len(entries) > 0 {
+		latest = entries[0].PublishedAt
+	}
+
+	// Atom requires <updated>; an empty feed reports the epoch.
+	updated := latest
+	if updated.IsZero() {
+		updated = time.Unix(0, 0).UTC()
+	}
+
+	feed := atomFeed{
+		XMLNS:    "http://www.w3.org/2005/Atom",
+		Title:    "mossai - newly listed servers",
+		Subtitle: "osu! private servers that were just approved on mossai.",
+		ID:       base + "/feed.atom",
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: base + "/feed.atom"},
+			{Rel: "alternate", Type: "text/html", Href: base + "/"},
+		},
+		Updated: updated.Format(time.RFC3339),
+	}
+
+	for _, e := range entries {
+		link := buildServerURL(int64(e.Server.ID))
+		entry := atomEntry{
+			Title:     e.Server.ServerName,
+			ID:        fmt.Sprintf("%s#listing-%d", link, e.ID),
+			Link:      atomLink{Rel: "alternate", Type: "text/html", Href: link},
+			Published: e.PublishedAt.Format(time.RFC3339),
+			Updated:   e.PublishedAt.Format(time.RFC3339),
+			Summary:   feedSummary(e.Server),
+		}
+		for _, tag := range splitCSV(e.Server.Tags) {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	body, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return c.Status(500).SendString("failed to build feed")
+	}
+
+	return sendCacheable(c, "application/atom+xml; charset=utf-8", latest, append([]byte(xml.Header), body...))
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Description string   `xml:"description"`
+	Categories  []string `xml:"category"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+func rssFeedHandler(c fiber.Ctx) error {
+	entries, err := loadFeedEntries()
+	if err != nil {
+		log.Println("rss feed query error:", err)
+		return c.Status(500).SendString("internal error")
+	}
+
+	var latest time.Time
+	if len(entries) > 0 {
+		latest = entries[0].PublishedAt
+	}
+
+	feed := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       "mossai - newly listed servers",
+			Link:        getBaseURL() + "/",
+			Description: "osu! private servers that were just approved on mossai.",
+		},
+	}
+	if !latest.IsZero() {
+		feed.Channel.LastBuildDate = latest.Format(time.RFC1123Z)
+	}
+
+	for _, e := range entries {
+		link := buildServerURL(int64(e.Server.ID))
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       e.Server.ServerName,
+			Link:        link,
+			GUID:        rssGUID{Value: fmt.Sprintf("%s#listing-%d", link, e.ID)},
+			PubDate:     e.PublishedAt.Format(time.RFC1123Z),
+			Description: feedSummary(e.Server),
+			Categories:  splitCSV(e.Server.Tags),
+		})
+	}
+
+	body, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return c.Status(500).SendString("failed to build feed")
+	}
+
+	return sendCacheable(c, "application/rss+xml; charset=utf-8", latest, append([]byte(xml.Header), body...))
+}
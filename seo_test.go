@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSitemapChangesAfterRemoval(t *testing.T) {
+	srv := newTestServer(t)
+
+	insertTestServer(t, "Kept", "https://kept.example", 10)
+	removed := insertTestServer(t, "Removed", "https://removed.example", 5)
+
+	get := func(header, value string) (*http.Response, string) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/sitemap.xml", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, string(body)
+	}
+
+	first, _ := get("", "")
+	etag, modified := first.Header.Get("ETag"), first.Header.Get("Last-Modified")
+	if etag == "" || modified == "" {
+		t.Fatalf("ETag %q, Last-Modified %q", etag, modified)
+	}
+	if resp, _ := get("If-None-Match", etag); resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("unchanged sitemap: status %d, want 304", resp.StatusCode)
+	}
+
+	if _, err := Database.Exec(`DELETE FROM servers WHERE id = ?`, removed); err != nil {
+		t.Fatal(err)
+	}
+	path := "/servers/" + strconv.Itoa(removed) + "<"
+
+	for _, h := range [][2]string{{"If-None-Match", etag}, {"If-Modified-Since", modified}} {
+		resp, body := get(h[0], h[1])
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s after a removal: status %d, want 200", h[0], resp.StatusCode)
+		}
+		if strings.Contains(body, path) {
+			t.Fatalf("%s after a removal: sitemap still lists the server", h[0])
+		}
+	}
+}
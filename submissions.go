@@ -0,0 +1,348 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const requestRevisionSelect = `
+	SELECT revision,
+	       server_name,
+	       COALESCE(url, ''),
+	       COALESCE(description, ''),
+	       COALESCE(long_description, ''),
+	       COALESCE(tags, ''),
+	       COALESCE(features, ''),
+	       COALESCE(links, ''),
+	       COALESCE(logo_url, ''),
+	       edited_by,
+	       created_at
+	FROM server_request_revisions
+`
+
+func scanRequestRevision(row rowScanner) (RequestRevision, error) {
+	var (
+		rv       RequestRevision
+		features string
+		links    string
+	)
+	err := row.Scan(
+		&rv.Revision,
+		&rv.ServerName,
+		&rv.URL,
+		&rv.Description,
+		&rv.LongDescription,
+		&rv.Tags,
+		&features,
+		&links,
+		&rv.LogoURL,
+		&rv.EditedBy,
+		&rv.CreatedAt,
+	)
+	rv.Features = decodeFeatures(features)
+	rv.Links = decodeLinks(links)
+	return rv, err
+}
+
+// recordRequestRevision snapshots the current state of a request as its
+// next revision. editedBy is the Discord ID of whoever made the change.
+func recordRequestRevision(requestID int, editedBy string) {
+	_, err := Database.Exec(`
+		INSERT INTO server_request_revisions (
+			request_id, revision, server_name, url, description, long_description, tags, features,
+			links, logo_url, edited_by, created_at
+		)
+		SELECT r.id,
+		       COALESCE((SELECT MAX(revision) FROM server_request_revisions WHERE request_id = r.id), 0) + 1,
+		       r.server_name, r.url, r.description, r.long_description, r.tags, r.features,
+		       r.links, r.logo_url, ?, datetime('now')
+		FROM server_requests r
+		WHERE r.id = ?
+	`, editedBy, requestID)
+	if err != nil {
+		log.Println("recordRequestRevision:", err)
+	}
+}
+
+// loadUnseenRequestChanges fills in r.SeenRevision, the revision the admin
+// last marked as seen, and r.UnseenChanges, what changed since then.
+// Viewing the queue doesn't mark anything; see postAdminRequestSeenHandler.
+func loadUnseenRequestChanges(adminID string, r *ServerRequest) error {
+	err := Database.QueryRow(`
+		SELECT revision
+		FROM admin_request_views
+		WHERE admin_discord_id = ? AND request_id = ?
+	`, adminID, r.ID).Scan(&r.SeenRevision)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	seen := r.SeenRevision
+	if seen == 0 || seen >= r.Revision {
+		return nil
+	}
+	old, err := scanRequestRevision(Database.QueryRow(requestRevisionSelect+`
+		WHERE request_id = ? AND revision = ?
+	`, r.ID, seen))
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	r.UnseenChanges = serverEditChanges(ServerResult{
+		ServerName:      old.ServerName,
+		URL:             old.URL,
+		Description:     old.Description,
+		LongDescription: old.LongDescription,
+		Tags:            old.Tags,
+		Features:        old.Features,
+		Links:           old.Links,
+		LogoURL:         old.LogoURL,
+	}, r)
+	return nil
+}
+
+// postAdminRequestSeenHandler records that the admin has looked at the
+// given revision of a request. Later revisions are then listed as changes
+// against it.
+func postAdminRequestSeenHandler(c fiber.Ctx) error {
+	admin, err := requireAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid request id")
+	}
+
+	var body struct {
+		Revision int `json:"revision"`
+	}
+	if err := c.Bind().Body(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid json body")
+	}
+
+	var exists bool
+	if err := Database.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM server_request_revisions WHERE request_id = ? AND revision = ?)
+	`, id, body.Revision).Scan(&exists); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load revisions")
+	}
+	if !exists {
+		return c.Status(fiber.StatusNotFound).SendString("revision not found")
+	}
+
+	// Marking an older revision, e.g. from a stale tab, never rewinds.
+	if _, err := Database.Exec(`
+		INSERT INTO admin_request_views (admin_discord_id, request_id, revision, viewed_at)
+		VALUES (?, ?, ?, datetime('now'))
+		ON CONFLICT(admin_discord_id, request_id) DO UPDATE SET
+			revision  = MAX(revision, excluded.revision),
+			viewed_at = excluded.viewed_at
+	`, admin.DiscordID, id, body.Revision); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to save")
+	}
+
+	return c.JSON(fiber.Map{"ok": true})
+}
+
+// loadOwnPendingRequest loads a pending request filed by the signed-in user.
+func loadOwnPendingRequest(c fiber.Ctx) (*SessionUser, *ServerRequest, error) {
+	u, ok := getSessionUser(c)
+	if !ok {
+		return nil, nil, fiber.NewError(fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id <= 0 {
+		return nil, nil, fiber.NewError(fiber.StatusBadRequest, "invalid request id")
+	}
+
+	r, err := scanServerRequest(Database.QueryRow(serverRequestSelect+`
+		WHERE id = ? AND owner_discord = ?
+	`, id, u.DiscordID))
+	if err == sql.ErrNoRows {
+		return nil, nil, fiber.NewError(fiber.StatusNotFound, "request not found")
+	}
+	if err != nil {
+		return nil, nil, fiber.NewError(fiber.StatusInternalServerError, "failed to load request")
+	}
+	if r.Status != "pending" {
+		return nil, nil, fiber.NewError(fiber.StatusConflict, "This request has already been reviewed.")
+	}
+
+	return u, &r, nil
+}
+
+// postOwnerRequestEditHandler lets a submitter fix their own pending listing
+// request. Edit proposals for live listings are changed from the dashboard
+// instead.
+func postOwnerRequestEditHandler(c fiber.Ctx) error {
+	u, r, err := loadOwnPendingRequest(c)
+	if err != nil {
+		return err
+	}
+	if r.Kind != requestKindNew {
+		return c.Status(fiber.StatusBadRequest).SendString("Change edit proposals from your servers page.")
+	}
+
+	type requestPayload struct {
+		ServerName      string         `json:"server_name"`
+		URL             string         `json:"url"`
+		LogoURL         string         `json:"logo_url"`
+		Description     string         `json:"description"`
+		LongDescription string         `json:"long_description"`
+		Tags            []string       `json:"tags"`
+		Features        ServerFeatures `json:"features"`
+		Links           []ServerLink   `json:"links"`
+	}
+
+	var payload requestPayload
+	if err := c.Bind().Body(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid json body")
+	}
+
+	clean := make([]string, 0, len(payload.Tags))
+	for _, t := range payload.Tags {
+		t = strings.TrimSpace(t)
+		if t != "" {
+			clean = append(clean, t)
+		}
+	}
+
+	next := *r
+	next.ServerName = strings.TrimSpace(payload.ServerName)
+	next.URL = strings.TrimSpace(payload.URL)
+	next.LogoURL = strings.TrimSpace(payload.LogoURL)
+	next.Description = strings.TrimSpace(payload.Description)
+	next.LongDescription = strings.TrimSpace(payload.LongDescription)
+	next.Tags = strings.Join(clean, ",")
+	if next.Features, err = normalizeFeatures(payload.Features); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	if next.Links, err = normalizeLinks(payload.Links); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	if next.ServerName == "" {
+		return c.Status(fiber.StatusBadRequest).SendString("server_name is required")
+	}
+	if len(next.Description) > MaxDescriptionLength {
+		return c.Status(fiber.StatusBadRequest).SendString(
+			fmt.Sprintf("Description must be at most %d characters.", MaxDescriptionLength),
+		)
+	}
+	if len(next.LongDescription) > MaxLongDescriptionLength {
+		return c.Status(fiber.StatusBadRequest).SendString(
+			fmt.Sprintf("Long description must be at most %d characters.", MaxLongDescriptionLength),
+		)
+	}
+	if !validListingURL(next.URL) || !validLogoURL(next.LogoURL) {
+		return c.Status(fiber.StatusBadRequest).SendString("url and logo_url must be http(s) links")
+	}
+
+	before := ServerResult{
+		ServerName:      r.ServerName,
+		URL:             r.URL,
+		Description:     r.Description,
+		LongDescription: r.LongDescription,
+		Tags:            r.Tags,
+		Features:        r.Features,
+		Links:           r.Links,
+		LogoURL:         r.LogoURL,
+	}
+	if len(serverEditChanges(before, &next)) == 0 {
+		return c.Status(fiber.StatusBadRequest).SendString("nothing to change")
+	}
+
+	res, err := Database.Exec(`
+		UPDATE server_requests
+		SET
+			server_name = ?,
+			url         = ?,
+			logo_url    = ?,
+			description = ?,
+			long_description = ?,
+			tags        = ?,
+			features    = ?,
+			links       = ?
+		WHERE id = ? AND status = 'pending'
+	`,
+		next.ServerName,
+		nullEmpty(next.URL),
+		nullEmpty(next.LogoURL),
+		nullEmpty(next.Description),
+		nullEmpty(next.LongDescription),
+		nullEmpty(next.Tags),
+		encodeFeatures(next.Features),
+		encodeLinks(next.Links),
+		r.ID,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to update request")
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return c.Status(fiber.StatusConflict).SendString("This request has already been reviewed.")
+	}
+
+	recordRequestRevision(r.ID, u.DiscordID)
+
+	return c.JSON(fiber.Map{"ok": true})
+}
+
+func postOwnerRequestWithdrawHandler(c fiber.Ctx) error {
+	_, r, err := loadOwnPendingRequest(c)
+	if err != nil {
+		return err
+	}
+
+	res, err := Database.Exec(`
+		UPDATE server_requests
+		SET status = 'withdrawn'
+		WHERE id = ? AND status = 'pending'
+	`, r.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to withdraw request")
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return c.Status(fiber.StatusConflict).SendString("This request has already been reviewed.")
+	}
+
+	return c.JSON(fiber.Map{"ok": true})
+}
+
+func getAdminRequestRevisionsHandler(c fiber.Ctx) error {
+	if _, err := requireAdmin(c); err != nil {
+		return err
+	}
+
+	rows, err := Database.Query(requestRevisionSelect+`
+		WHERE request_id = ?
+		ORDER BY revision DESC
+	`, c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load revisions")
+	}
+	defer rows.Close()
+
+	revisions := make([]RequestRevision, 0, 4)
+	for rows.Next() {
+		rv, err := scanRequestRevision(rows)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("failed to read revision")
+		}
+		revisions = append(revisions, rv)
+	}
+	if err := rows.Err(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load revisions")
+	}
+
+	return c.JSON(fiber.Map{"revisions": revisions})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestViewingRequestsDoesNotMarkThemSeen(t *testing.T) {
+	srv := newTestServer(t)
+	const ownerID = "100000000000000050"
+	owner := testSessionToken(t, ownerID)
+	admin := testSessionToken(t, testAdminID)
+
+	res, err := Database.Exec(`
+		INSERT INTO server_requests (server_name, url, description, tags, owner_name, owner_discord, status, created_at)
+		VALUES ('Pending', 'https://pending.example', 'First draft', 'pp', 'owner', ?, 'pending', datetime('now'))
+	`, ownerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id64, _ := res.LastInsertId()
+	id := int(id64)
+	recordRequestRevision(id, ownerID)
+	base := "/admin/requests/" + strconv.Itoa(id)
+
+	queue := func() ServerRequest {
+		t.Helper()
+		status, body := testRequest(t, srv, http.MethodGet, "/admin/requests/data", admin, nil)
+		if status != http.StatusOK {
+			t.Fatalf("queue: %d %s", status, body)
+		}
+		var requests []ServerRequest
+		if err := json.Unmarshal(body, &requests); err != nil {
+			t.Fatal(err)
+		}
+		return requests[0]
+	}
+	edit := func(description string) {
+		t.Helper()
+		status, body := testRequest(t, srv, http.MethodPost, "/api/owner/requests/"+strconv.Itoa(id), owner,
+			map[string]any{"server_name": "Pending", "url": "https://pending.example", "description": description, "tags": []string{"pp"}})
+		if status != http.StatusOK {
+			t.Fatalf("owner edit: %d %s", status, body)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if r := queue(); r.Revision != 1 || r.SeenRevision != 0 {
+			t.Fatalf("view %d: revision %d, seen %d", i, r.Revision, r.SeenRevision)
+		}
+	}
+
+	if status, _ := testRequest(t, srv, http.MethodPost, base+"/seen", admin, map[string]int{"revision": 5}); status != http.StatusNotFound {
+		t.Fatalf("marking a missing revision: status %d, want 404", status)
+	}
+	if status, body := testRequest(t, srv, http.MethodPost, base+"/seen", admin, map[string]int{"revision": 1}); status != http.StatusOK {
+		t.Fatalf("seen: %d %s", status, body)
+	}
+
+	edit("Second draft")
+	r := queue()
+	if r.SeenRevision != 1 || len(r.UnseenChanges) != 1 || r.UnseenChanges[0].New != "Second draft" {
+		t.Fatalf("after an edit: seen %d, changes %+v", r.SeenRevision, r.UnseenChanges)
+	}
+	if again := queue(); len(again.UnseenChanges) != 1 {
+		t.Fatal("viewing the queue cleared the unseen changes")
+	}
+
+	// A stale tab marking an older revision doesn't rewind.
+	testRequest(t, srv, http.MethodPost, base+"/seen", admin, map[string]int{"revision": r.Revision})
+	testRequest(t, srv, http.MethodPost, base+"/seen", admin, map[string]int{"revision": 1})
+	if r := queue(); r.SeenRevision != r.Revision || len(r.UnseenChanges) != 0 {
+		t.Fatalf("after marking the latest: seen %d of %d, changes %+v", r.SeenRevision, r.Revision, r.UnseenChanges)
+	}
+}
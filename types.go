@@ -1,31 +1,30 @@
 package main
 
-const MaxDescriptionLength = 250
+import "mossai/api"
 
-type ServerResult struct {
-	ID          int    `json:"id"`
-	ServerName  string `json:"server_name"`
-	URL         string `json:"url"`
-	Description string `json:"description"`
-	Tags        string `json:"tags"`
-	LogoURL     string `json:"logo_url"`
-	Status      string `json:"status"`
-	Online      int    `json:"online"`
-	Registered  int    `json:"registered"`
-	Votes       int    `json:"votes"`
-	Added       string `json:"added"`
-	Owner       string `json:"owner"`
-}
+const MaxDescriptionLength = 250
 
-type ServerRequest struct {
-	ID           int    `json:"id"`
-	ServerName   string `json:"server_name"`
-	URL          string `json:"url"`
-	Description  string `json:"description"`
-	Tags         string `json:"tags"`
-	OwnerName    string `json:"owner_name"`
-	OwnerDiscord string `json:"owner_discord"`
-	Status       string `json:"status"`
-	CreatedAt    string `json:"created_at"`
-	LogoURL      string `json:"logo_url"`
-}
+type (
+	ServerResult         = api.ServerResult
+	ServerRequest        = api.ServerRequest
+	APIKey               = api.APIKey
+	VoteCheck            = api.VoteCheck
+	OwnerServer          = api.OwnerServer
+	FieldChange          = api.FieldChange
+	ServerOwner          = api.ServerOwner
+	OwnerInvite          = api.OwnerInvite
+	ServerClaim          = api.ServerClaim
+	DomainVerification   = api.DomainVerification
+	RequestRevision      = api.RequestRevision
+	Screenshot           = api.Screenshot
+	ServerFeatures       = api.ServerFeatures
+	ServerLink           = api.ServerLink
+	DiscordCommunity     = api.DiscordCommunity
+	ExpiredDiscordInvite = api.ExpiredDiscordInvite
+	Announcement         = api.Announcement
+	Review               = api.Review
+	ServerReport         = api.ServerReport
+	AdminUser            = api.AdminUser
+	AuditEntry           = api.AuditEntry
+	RejectionReason      = api.RejectionReason
+)
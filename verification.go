@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	verificationTXTPrefix  = "mossai-verification="
+	verificationWellKnown  = "/.well-known/mossai-verification.txt"
+	maxWellKnownBytes      = 4 << 10
+	domainRecheckInterval  = 6 * time.Hour
+	domainCheckTimeout     = 15 * time.Second
+	maxVerificationFailure = 2
+)
+
+var (
+	errVerificationNotFound = errors.New("verification token not found")
+	errVerificationMoved    = errors.New("the listing no longer uses this domain")
+)
+
+// txtResolver is the part of net.Resolver the verifier needs.
+type txtResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// domainVerifier checks that a listing's domain publishes its verification
+// token. The DNS and HTTP lookups are injected; tests answer them from memory.
+type domainVerifier struct {
+	resolver txtResolver
+	fetch    func(ctx context.Context, rawURL string, limit int64) ([]byte, string, error)
+}
+
+var defaultDomainVerifier = &domainVerifier{
+	resolver: net.DefaultResolver,
+	fetch:    fetchRemoteBytes,
+}
+
+// check looks for token in the domain's TXT records, then in its well-known
+// file, and returns the method that found it.
+func (v *domainVerifier) check(ctx context.Context, domain, token string) (string, error) {
+	want := verificationTXTPrefix + token
+
+	records, dnsErr := v.resolver.LookupTXT(ctx, domain)
+	for _, rec := range records {
+		if strings.TrimSpace(rec) == want {
+			return "dns", nil
+		}
+	}
+
+	body, _, httpErr := v.fetch(ctx, "https://"+domain+verificationWellKnown, maxWellKnownBytes)
+	if httpErr == nil {
+		for _, line := range strings.Split(string(body), "\n") {
+			line = strings.TrimSpace(line)
+			if line == token || line == want {
+				return "http", nil
+			}
+		}
+	}
+
+	if dnsErr != nil && httpErr != nil {
+		return "", fmt.Errorf("dns: %v; http: %v", dnsErr, httpErr)
+	}
+	return "", errVerificationNotFound
+}
+
+// listingDomain returns the lower-cased host of a listing URL.
+func listingDomain(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+}
+
+func generateVerificationToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func loadDomainVerification(serverID int) (*DomainVerification, error) {
+	var v DomainVerification
+	err := Database.QueryRow(`
+		SELECT server_id,
+		       domain,
+		       token,
+		       COALESCE(method, ''),
+		       COALESCE(verified_at, ''),
+		       COALESCE(last_checked_at, ''),
+		       COALESCE(last_error, '')
+		FROM domain_verifications
+		WHERE server_id = ?
+	`, serverID).Scan(
+		&v.ServerID,
+		&v.Domain,
+		&v.Token,
+		&v.Method,
+		&v.VerifiedAt,
+		&v.LastCheckedAt,
+		&v.LastError,
+	)
+	if err != nil {
+		return nil, err
+	}
+	setVerificationInstructions(&v)
+	return &v, nil
+}
+
+// setVerificationInstructions fills in where v's token has to be published.
+func setVerificationInstructions(v *DomainVerification) {
+	v.TXTRecord = verificationTXTPrefix + v.Token
+	v.WellKnownURL = "https://" + v.Domain + verificationWellKnown
+}
+
+// ensureDomainVerification returns the server's verification, issuing a new
+// token when there is none yet or the listing moved to another domain.
+func ensureDomainVerification(serverID int, domain string) (*DomainVerification, error) {
+	v, err := loadDomainVerification(serverID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if v != nil && v.Domain == domain {
+		return v, nil
+	}
+
+	token, err := generateVerificationToken()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = Database.Exec(`
+		INSERT INTO domain_verifications (server_id, domain, token, created_at)
+		VALUES (?, ?, ?, datetime('now'))
+		ON CONFLICT(server_id) DO UPDATE SET
+			domain          = excluded.domain,
+			token           = excluded.token,
+			method          = NULL,
+			verified_at     = NULL,
+			last_checked_at = NULL,
+			last_error      = NULL,
+			failures        = 0,
+			created_at      = excluded.created_at
+	`, serverID, domain, token)
+	if err != nil {
+		return nil, err
+	}
+	return loadDomainVerification(serverID)
+}
+
+// runDomainCheck checks one server and records the outcome. A verified
+// domain keeps its badge through a single failed check so a DNS hiccup does
+// not strip it. A verification for a domain the listing has since left is
+// dropped instead of badging the listing again.
+func runDomainCheck(ctx context.Context, v *domainVerifier, dv *DomainVerification) error {
+	ctx, cancel := context.WithTimeout(ctx, domainCheckTimeout)
+	defer cancel()
+
+	method, checkErr := v.check(ctx, dv.Domain, dv.Token)
+	if checkErr == nil {
+		tx, err := Database.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var rawURL string
+		if err := tx.QueryRow(`SELECT COALESCE(url, '') FROM servers WHERE id = ?`, dv.ServerID).Scan(&rawURL); err != nil {
+			return err
+		}
+		if listingDomain(rawURL) != dv.Domain {
+			if _, err := tx.Exec(`
+				DELETE FROM domain_verifications WHERE server_id = ? AND token = ?
+			`, dv.ServerID, dv.Token); err != nil {
+				return err
+			}
+			if err := tx.Commit(); err != nil {
+				return err
+			}
+			return errVerificationMoved
+		}
+
+		if _, err := tx.Exec(`
+			UPDATE domain_verifications
+			SET method          = ?,
+			    verified_at     = COALESCE(verified_at, datetime('now')),
+			    last_checked_at = datetime('now'),
+			    last_error      = NULL,
+			    failures        = 0
+			WHERE server_id = ? AND token = ?
+		`, method, dv.ServerID, dv.Token); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`
+			UPDATE servers SET verified_domain = ? WHERE id = ?
+		`, dv.Domain, dv.ServerID); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	tx, err := Database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var failures int
+	err = tx.QueryRow(`
+		UPDATE domain_verifications
+		SET last_checked_at = datetime('now'),
+		    last_error      = ?,
+		    failures        = failures + 1
+		WHERE server_id = ? AND token = ?
+		RETURNING failures
+	`, checkErr.Error(), dv.ServerID, dv.Token).Scan(&failures)
+	if err == sql.ErrNoRows {
+		return checkErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if dv.VerifiedAt == "" || failures >= maxVerificationFailure {
+		if _, err := tx.Exec(`
+			UPDATE domain_verifications SET verified_at = NULL, method = NULL WHERE server_id = ?
+		`, dv.ServerID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`
+			UPDATE servers SET verified_domain = NULL WHERE id = ?
+		`, dv.ServerID); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return checkErr
+}
+
+// recheckVerifiedDomains re-runs the check for every verified server that
+// was last checked more than interval ago.
+func recheckVerifiedDomains(ctx context.Context, v *domainVerifier, interval time.Duration) {
+	rows, err := Database.Query(`
+		SELECT server_id
+		FROM domain_verifications
+		WHERE verified_at IS NOT NULL
+		  AND (last_checked_at IS NULL OR last_checked_at < datetime('now', ?))
+	`, fmt.Sprintf("-%d seconds", int(interval.Seconds())))
+	if err != nil {
+		log.Println("recheck domains:", err)
+		return
+	}
+	ids := make([]int, 0, 16)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		dv, err := loadDomainVerification(id)
+		if err != nil {
+			continue
+		}
+		if err := runDomainCheck(ctx, v, dv); err != nil {
+			log.Printf("recheck domain %s (server %d): %v", dv.Domain, id, err)
+		}
+	}
+}
+
+// startDomainRechecks rechecks verified domains in the background for the
+// lifetime of the process.
+func startDomainRechecks(v *domainVerifier, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval / 4)
+		defer ticker.Stop()
+		for range ticker.C {
+			recheckVerifiedDomains(context.Background(), v, interval)
+		}
+	}()
+}
+
+// getOwnerVerificationHandler returns the token the owner has to publish,
+// issuing one on first use.
+func getOwnerVerificationHandler(c fiber.Ctx) error {
+	_, id, err := requireServerRole(c, roleOwner)
+	if err != nil {
+		return err
+	}
+
+	var rawURL string
+	if err := Database.QueryRow(`SELECT COALESCE(url, '') FROM servers WHERE id = ?`, id).Scan(&rawURL); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load server")
+	}
+
+	domain := listingDomain(rawURL)
+	if domain == "" {
+		return c.Status(fiber.StatusBadRequest).SendString("Add a website link to the listing before verifying its domain.")
+	}
+
+	dv, err := ensureDomainVerification(id, domain)
+	if err != nil {
+		log.Println("ensureDomainVerification:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load verification")
+	}
+
+	return c.JSON(dv)
+}
+
+func postOwnerVerificationCheckHandler(c fiber.Ctx) error {
+	_, id, err := requireServerRole(c, roleOwner)
+	if err != nil {
+		return err
+	}
+
+	dv, err := loadDomainVerification(id)
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).SendString("request a verification token first")
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load verification")
+	}
+
+	checkErr := runDomainCheck(c.Context(), defaultDomainVerifier, dv)
+	if checkErr == errVerificationMoved {
+		return c.Status(fiber.StatusConflict).SendString("The listing moved to another domain. Request a new token.")
+	}
+
+	if dv, err = loadDomainVerification(id); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load verification")
+	}
+	if checkErr != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(dv)
+	}
+	return c.JSON(dv)
+}
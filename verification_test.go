@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestDomainVerifierCheck(t *testing.T) {
+	const token = "abc123"
+	wellKnown := "https://example.com" + verificationWellKnown
+
+	tests := []struct {
+		name       string
+		domain     fakeDomain
+		wantMethod string
+		wantErr    error
+	}{
+		{
+			name:       "dns txt",
+			domain:     fakeDomain{txt: map[string][]string{"example.com": {"v=spf1 -all", " mossai-verification=abc123 "}}},
+			wantMethod: "dns",
+		},
+		{
+			name:       "well-known file with the bare token",
+			domain:     fakeDomain{wellKnown: map[string]string{wellKnown: "\nabc123\n"}},
+			wantMethod: "http",
+		},
+		{
+			name:       "well-known file with the txt record",
+			domain:     fakeDomain{wellKnown: map[string]string{wellKnown: "mossai-verification=abc123"}},
+			wantMethod: "http",
+		},
+		{
+			name: "other token",
+			domain: fakeDomain{
+				txt:       map[string][]string{"example.com": {"mossai-verification=other"}},
+				wellKnown: map[string]string{wellKnown: "other"},
+			},
+			wantErr: errVerificationNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &domainVerifier{resolver: &tt.domain, fetch: tt.domain.fetch}
+			method, err := v.check(context.Background(), "example.com", token)
+			if err != tt.wantErr || method != tt.wantMethod {
+				t.Fatalf("check = %q, %v; want %q, %v", method, err, tt.wantMethod, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("both lookups fail", func(t *testing.T) {
+		var f fakeDomain
+		v := &domainVerifier{resolver: &f, fetch: f.fetch}
+		_, err := v.check(context.Background(), "example.com", token)
+		if err == nil || errors.Is(err, errVerificationNotFound) {
+			t.Fatalf("check error = %v, want the lookup errors", err)
+		}
+	})
+}
+
+// verifiedDomainOf returns the badge the listing shows.
+func verifiedDomainOf(t *testing.T, serverID int) string {
+	t.Helper()
+
+	var domain string
+	if err := Database.QueryRow(
+		`SELECT COALESCE(verified_domain, '') FROM servers WHERE id = ?`, serverID,
+	).Scan(&domain); err != nil {
+		t.Fatal(err)
+	}
+	return domain
+}
+
+func TestRunDomainCheckGraceAndRevocation(t *testing.T) {
+	newTestServer(t)
+	ctx := context.Background()
+
+	serverID := insertTestServer(t, "Verified", "https://verified.example", 0)
+	dv, err := ensureDomainVerification(serverID, "verified.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	domains := &fakeDomain{txt: map[string][]string{"verified.example": {dv.TXTRecord}}}
+	v := &domainVerifier{resolver: domains, fetch: domains.fetch}
+
+	if err := runDomainCheck(ctx, v, dv); err != nil {
+		t.Fatal(err)
+	}
+	if got := verifiedDomainOf(t, serverID); got != "verified.example" {
+		t.Fatalf("verified_domain = %q after a passing check", got)
+	}
+
+	// The record disappears: the first failure is forgiven.
+	domains.txt = nil
+	if dv, err = loadDomainVerification(serverID); err != nil {
+		t.Fatal(err)
+	}
+	if err := runDomainCheck(ctx, v, dv); err == nil {
+		t.Fatal("check passed without a record")
+	}
+	if got := verifiedDomainOf(t, serverID); got != "verified.example" {
+		t.Fatalf("verified_domain = %q after one failure, want it kept", got)
+	}
+
+	// A second failure in a row revokes the badge.
+	if dv, err = loadDomainVerification(serverID); err != nil {
+		t.Fatal(err)
+	}
+	if err := runDomainCheck(ctx, v, dv); err == nil {
+		t.Fatal("check passed without a record")
+	}
+	if got := verifiedDomainOf(t, serverID); got != "" {
+		t.Fatalf("verified_domain = %q after two failures, want it revoked", got)
+	}
+	if dv, err = loadDomainVerification(serverID); err != nil {
+		t.Fatal(err)
+	}
+	if dv.VerifiedAt != "" || dv.LastError == "" {
+		t.Fatalf("verification = %+v, want unverified with the last error", dv)
+	}
+
+	// Publishing the record again restores it and resets the count.
+	domains.txt = map[string][]string{"verified.example": {dv.TXTRecord}}
+	if err := runDomainCheck(ctx, v, dv); err != nil {
+		t.Fatal(err)
+	}
+	if got := verifiedDomainOf(t, serverID); got != "verified.example" {
+		t.Fatalf("verified_domain = %q after the record came back", got)
+	}
+}
+
+func TestUnverifiedDomainFailsStraightAway(t *testing.T) {
+	newTestServer(t)
+
+	serverID := insertTestServer(t, "New", "https://new.example", 0)
+	dv, err := ensureDomainVerification(serverID, "new.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var f fakeDomain
+	if err := runDomainCheck(context.Background(), &domainVerifier{resolver: &f, fetch: f.fetch}, dv); err == nil {
+		t.Fatal("check passed without a record")
+	}
+	if dv, err = loadDomainVerification(serverID); err != nil {
+		t.Fatal(err)
+	}
+	if dv.VerifiedAt != "" || dv.LastCheckedAt == "" {
+		t.Fatalf("verification = %+v", dv)
+	}
+}
+
+func TestDomainChangeDropsVerification(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+
+	serverID := insertTestServer(t, "Moving", "https://old.example", 0)
+	dv, err := ensureDomainVerification(serverID, "old.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The old domain keeps publishing the token after the listing moves.
+	domains := &fakeDomain{txt: map[string][]string{"old.example": {dv.TXTRecord}}}
+	v := &domainVerifier{resolver: domains, fetch: domains.fetch}
+	if err := runDomainCheck(ctx, v, dv); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := Database.Exec(`
+		INSERT INTO server_requests (server_name, url, owner_name, owner_discord, created_at, kind, server_id)
+		VALUES ('Moving', 'https://new.example', 'owner', '100000000000000020', datetime('now'), 'edit', ?)
+	`, serverID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requestID, _ := res.LastInsertId()
+
+	status, body := testRequest(t, srv, http.MethodPost, "/admin/requests/"+strconv.FormatInt(requestID, 10)+"/approve",
+		testSessionToken(t, testAdminID), nil)
+	if status != http.StatusOK {
+		t.Fatalf("approve: %d %s", status, body)
+	}
+
+	if got := verifiedDomainOf(t, serverID); got != "" {
+		t.Fatalf("verified_domain = %q after the move", got)
+	}
+	if _, err := loadDomainVerification(serverID); err == nil {
+		t.Fatal("the old domain's verification survived the move")
+	}
+
+	// A check already in flight for the old domain doesn't badge the
+	// listing either.
+	if err := runDomainCheck(ctx, v, dv); err != errVerificationMoved {
+		t.Fatalf("stale check error = %v, want errVerificationMoved", err)
+	}
+	if got := verifiedDomainOf(t, serverID); got != "" {
+		t.Fatalf("verified_domain = %q after a stale check", got)
+	}
+}